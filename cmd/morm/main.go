@@ -0,0 +1,85 @@
+// morm 命令行工具
+//
+//	morm [-config config.toml] migrate create <name>
+//	morm [-config config.toml] gen [-dir ./models] [-tables a,b] models
+//	morm gen fields [-type User] [dir]
+//
+// 本命令没有编译项目的迁移与模型 migrate 只支持 create
+// migrate up down status 需要在项目自己的命令中导入迁移目录后调用 migrate.RunCommand
+// plan 需要在项目自己的命令中调用 morm.RegisterModels 后调用 morm.RunPlanCommand
+//
+//	import _ "example.com/app/migrations"
+//
+//	func main() {
+//		morm.InitORMConfig("config.toml")
+//		err := migrate.RunCommand(ctx, os.Args[1:], os.Stdout, func() (morm.ORM, error) {
+//			return morm.InitWithError()
+//		})
+//	}
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/lfhy/morm"
+	"github.com/lfhy/morm/gen"
+	"github.com/lfhy/morm/migrate"
+	"github.com/lfhy/morm/types"
+)
+
+const usage = `usage: morm [-config config.toml] <command> [args]
+
+commands:
+  migrate   生成迁移文件 migrate create <name>
+  gen       代码生成 gen models 从数据库生成模型 gen fields 生成字段描述
+`
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("morm", flag.ContinueOnError)
+	config := fs.String("config", "config.toml", "配置文件路径")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("missing command")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// 配置文件不存在时 create 等不需要连接数据库的命令仍然可以执行
+	if _, err := os.Stat(*config); err == nil {
+		if err := morm.InitORMConfig(*config); err != nil {
+			return err
+		}
+	}
+	connect := func() (types.ORM, error) {
+		return morm.InitWithError(*config)
+	}
+
+	switch cmd := fs.Arg(0); cmd {
+	case "migrate":
+		// 没有编译迁移 只支持 create
+		return migrate.RunCommand(ctx, fs.Args()[1:], os.Stdout, nil)
+	case "gen":
+		return gen.RunCommand(ctx, fs.Args()[1:], os.Stdout, connect)
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown command %q", cmd)
+	}
+}
@@ -54,3 +54,8 @@ func ReadConfigToTimeDuration(title, key string) time.Duration {
 func ReadConfigToBool(title, key string) bool {
 	return config.GetBool(fmt.Sprintf("%v.%v", title, key))
 }
+
+// 读取配置文件中的字符串数组
+func ReadConfigToStringSlice(title, key string) []string {
+	return config.GetStringSlice(fmt.Sprintf("%v.%v", title, key))
+}
@@ -1,6 +1,8 @@
 package conf
 
 import (
+	"time"
+
 	"github.com/lfhy/morm/types"
 	"github.com/spf13/viper"
 )
@@ -9,6 +11,8 @@ import (
 type DBConfig struct {
 	// 数据库类型 mysql mongodb sqlite
 	Type string `mapstructure:"db.type"`
+	// 乐观锁版本冲突时事务的重试次数
+	StaleRetry int `mapstructure:"db.stale_retry"`
 	// 日志配置
 	*LogConfig
 	// MySQL配置
@@ -24,6 +28,14 @@ type LogConfig struct {
 	Log string `mapstructure:"db.log"`
 	// 日志等级
 	LogLevel types.LogLevel `mapstructure:"db.loglevel"`
+	// 慢查询阈值 默认200ms
+	SlowThreshold time.Duration `mapstructure:"db.slow_threshold"`
+	// 不记录未查到数据的错误
+	IgnoreRecordNotFound bool `mapstructure:"db.ignore_record_not_found"`
+	// 日志中脱敏的字段名
+	RedactFields []string `mapstructure:"db.redact_fields"`
+	// 日志中脱敏的内容正则
+	RedactPatterns []string `mapstructure:"db.redact_patterns"`
 }
 
 func (l *LogConfig) Init() {
@@ -36,6 +48,18 @@ func (l *LogConfig) Init() {
 	if l.LogLevel != 0 {
 		config.Set("db.loglevel", l.LogLevel)
 	}
+	if l.SlowThreshold != 0 {
+		config.Set("db.slow_threshold", l.SlowThreshold)
+	}
+	if l.IgnoreRecordNotFound {
+		config.Set("db.ignore_record_not_found", l.IgnoreRecordNotFound)
+	}
+	if len(l.RedactFields) > 0 {
+		config.Set("db.redact_fields", l.RedactFields)
+	}
+	if len(l.RedactPatterns) > 0 {
+		config.Set("db.redact_patterns", l.RedactPatterns)
+	}
 }
 
 // Init 将总配置设置到config单例上，并调用各数据库配置的Init方法
@@ -51,6 +75,9 @@ func (d *DBConfig) Init() {
 
 	// 设置基本配置
 	config.Set("db.type", d.Type)
+	if d.StaleRetry != 0 {
+		config.Set("db.stale_retry", d.StaleRetry)
+	}
 
 	// 日志初始化
 	if d.LogConfig != nil {
@@ -1,11 +1,17 @@
 package conf
 
-import "github.com/spf13/viper"
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
 
 // MongoDBConfig 是MongoDB数据库的配置结构体
 type MongoDBConfig struct {
 	// 日志配置
 	*LogConfig
+	// 自动同步模型声明的索引
+	AutoCreateTable bool `mapstructure:"db.auto_create_table"`
 	// mongodb连接的数据库
 	Database string `mapstructure:"mongodb.database"`
 	// 连接池大小
@@ -18,6 +24,12 @@ type MongoDBConfig struct {
 	W string `mapstructure:"mongodb.w"`
 	// ReadMode 读模式 master nearest
 	ReadMode string `mapstructure:"mongodb.readmode"`
+	// 慢查询阈值 默认200ms
+	SlowThreshold time.Duration `mapstructure:"mongodb.slow_threshold"`
+	// 文档校验级别 strict moderate off 默认strict
+	ValidationLevel string `mapstructure:"mongodb.validation_level"`
+	// 文档校验失败时的处理 error warn 默认error
+	ValidationAction string `mapstructure:"mongodb.validation_action"`
 }
 
 // Init 将MongoDB配置设置到config单例上
@@ -35,10 +47,16 @@ func (m *MongoDBConfig) Init() {
 		m.LogConfig.Init()
 	}
 
+	config.Set("db.auto_create_table", m.AutoCreateTable)
 	config.Set("mongodb.database", m.Database)
 	config.Set("mongodb.option_pool_size", m.OptionPoolSize)
 	config.Set("mongodb.proxy", m.Proxy)
 	config.Set("mongodb.uri", m.Uri)
 	config.Set("mongodb.w", m.W)
 	config.Set("mongodb.readmode", m.ReadMode)
+	config.Set("mongodb.validation_level", m.ValidationLevel)
+	config.Set("mongodb.validation_action", m.ValidationAction)
+	if m.SlowThreshold != 0 {
+		config.Set("mongodb.slow_threshold", m.SlowThreshold)
+	}
 }
@@ -0,0 +1,99 @@
+package mongodb
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// 处理字段描述生成的条件 conditions 中的每一项都必须是 types.Condition
+// 同一字段的多个条件合并 如 Gt 与 Lt 组成范围
+func (m *Model) whereConditions(conditions ...any) types.ORMModel {
+	if m.WhereList == nil {
+		m.WhereList = bson.M{}
+	}
+	for _, c := range conditions {
+		cond, ok := c.(types.Condition)
+		if !ok {
+			m.err = fmt.Errorf("morm: mixed condition %T in Where", c)
+			return m
+		}
+		if cond.BSON == "" {
+			m.err = fmt.Errorf("%w: no bson name for condition", types.ErrUnknownField)
+			return m
+		}
+		m.saveCondition(cond)
+	}
+	return m
+}
+
+func (m *Model) saveCondition(c types.Condition) {
+	switch c.Op {
+	case types.CondEq:
+		m.saveOplist(types.WhereIs, c.BSON, c.Value)
+	case types.CondNe:
+		m.saveOplist(types.WhereNot, c.BSON, c.Value)
+	case types.CondGt:
+		m.mergeOperator(c.BSON, "$gt", c.Value)
+	case types.CondGte:
+		m.mergeOperator(c.BSON, "$gte", c.Value)
+	case types.CondLt:
+		m.mergeOperator(c.BSON, "$lt", c.Value)
+	case types.CondLte:
+		m.mergeOperator(c.BSON, "$lte", c.Value)
+	case types.CondIn:
+		m.mergeOperator(c.BSON, "$in", m.whereValues(c.BSON, c.Value))
+	case types.CondNotIn:
+		m.mergeOperator(c.BSON, "$nin", m.whereValues(c.BSON, c.Value))
+	case types.CondLike:
+		m.mergeOperator(c.BSON, "$regex", LikeToRegex(fmt.Sprint(c.Value)))
+	}
+}
+
+// 已有同一字段的操作符条件时合并 否则新建
+func (m *Model) mergeOperator(field, op string, value any) {
+	if cond, ok := m.WhereList[field].(bson.M); ok {
+		if _, eq := cond["$eq"]; !eq {
+			cond[op] = value
+			return
+		}
+	}
+	m.WhereList[field] = bson.M{op: value}
+}
+
+// 列表中的每个值都按加密字段处理 _id 的字符串保持为 []string 由 CheckOID 转换
+func (m *Model) whereValues(field string, values any) any {
+	if field == "_id" {
+		if ids, ok := values.([]string); ok {
+			return ids
+		}
+	}
+	v := reflect.ValueOf(values)
+	list := make(bson.A, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		list = append(list, m.whereValue(field, v.Index(i).Interface()))
+	}
+	return list
+}
+
+// SQL LIKE 语法转换为锚定的正则 % 为 .* _ 为 . 其余字符按原样匹配
+func LikeToRegex(pattern string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
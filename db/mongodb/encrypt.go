@@ -0,0 +1,66 @@
+package mongodb
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/lfhy/morm/encrypt"
+	"github.com/lfhy/morm/log"
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// 加密写入数据中带有 morm:"encrypt" 标签的字段
+func (m *Model) encrypt(data bson.M) (bson.M, error) {
+	return encrypt.EncryptMap(m.GetContext(), reflect.TypeOf(m.Data), data)
+}
+
+// 更新语句中写入值且可以加密的操作符
+var encryptOperators = map[string]bool{"$set": true, "$setOnInsert": true}
+
+// 加密更新语句中的加密字段 包含 value 中传入的 $set 与 $setOnInsert
+// 其余操作符写入的值无法加密 修改加密字段时返回错误 $unset 只删除字段不受限制
+func (m *Model) encryptUpdate(update bson.M) error {
+	typ := reflect.TypeOf(m.Data)
+	for op := range update {
+		if op == "$unset" {
+			continue
+		}
+		doc := updateDoc(update, op)
+		if encryptOperators[op] && len(doc) > 0 {
+			values, err := encrypt.EncryptMap(m.GetContext(), typ, doc)
+			if err != nil {
+				return err
+			}
+			update[op] = bson.M(values)
+			continue
+		}
+		for k := range doc {
+			if f, _ := encrypt.Lookup(typ, k); f != nil {
+				return fmt.Errorf("%w: %s on encrypted field %s", types.ErrNotSupported, op, f.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// 解密查询结果
+func (m *Model) decrypt(data any) error {
+	return encrypt.DecryptStruct(m.GetContext(), data)
+}
+
+// 等值查询条件中的确定性加密字段使用密文查询
+// 非确定性加密字段的密文每次不同 无法作为查询条件 与加密出错一样在执行操作时返回错误
+func (m *Model) whereValue(column string, value any) any {
+	if m.Data == nil {
+		return value
+	}
+	v, err := encrypt.WhereValue(m.GetContext(), reflect.TypeOf(m.Data), column, value)
+	if err != nil {
+		log.Errorf("加密查询条件 %s 出错: %v\n", column, err)
+		if m.err == nil {
+			m.err = fmt.Errorf("morm: where %s: %w", column, err)
+		}
+	}
+	return v
+}
@@ -0,0 +1,106 @@
+package mongodb
+
+import (
+	"context"
+
+	"github.com/lfhy/morm/log"
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 最终的查询条件与查询选项 不执行查询
+// 条件已经过 CheckOID 转换 _id 并合并 $or_like
+func (m *Model) ToFilter() (bson.M, *options.FindOptions) {
+	m.CheckOID()
+	return m.WhereList, m.makeAllQuery()
+}
+
+// 按当前的条件执行 explain 命令 返回 queryPlanner 的结果
+// 有连接时解释聚合管道
+func (m *Model) Explain(ctx context.Context) (*types.QueryPlan, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if ctx == nil {
+		ctx = m.GetContext()
+	}
+	command := bson.D{
+		{Key: "explain", Value: m.explainCommand()},
+		{Key: "verbosity", Value: "queryPlanner"},
+	}
+	log.Debugf("执行Mongo命令: %+v", command)
+	var reply bson.M
+	if err := m.Tx.Client.Database(m.Tx.Database).RunCommand(ctx, command).Decode(&reply); err != nil {
+		log.Errorf("Mongo命令出错: %v\n", err)
+		return nil, err
+	}
+	plan := &types.QueryPlan{Statement: extJSON(command), Raw: reply}
+	walkPlan(reply, plan)
+	return plan, nil
+}
+
+// 被解释的 find 或 aggregate 命令
+func (m *Model) explainCommand() bson.D {
+	coll := m.GetCollection(m.Data)
+	if len(m.joins) > 0 {
+		m.CheckOID()
+		return bson.D{
+			{Key: "aggregate", Value: coll},
+			{Key: "pipeline", Value: m.JoinPipeline()},
+			{Key: "cursor", Value: bson.D{}},
+		}
+	}
+	filter, opts := m.ToFilter()
+	command := bson.D{{Key: "find", Value: coll}, {Key: "filter", Value: filter}}
+	if opts.Sort != nil {
+		command = append(command, bson.E{Key: "sort", Value: opts.Sort})
+	}
+	if opts.Skip != nil && *opts.Skip > 0 {
+		command = append(command, bson.E{Key: "skip", Value: *opts.Skip})
+	}
+	if opts.Limit != nil && *opts.Limit > 0 {
+		command = append(command, bson.E{Key: "limit", Value: *opts.Limit})
+	}
+	return command
+}
+
+// 遍历计划中的全部阶段 COLLSCAN 为全表扫描 IXSCAN 记录索引名
+// 聚合与分片集群的结果中计划嵌套在 stages 与 shards 中 这里不区分层级
+func walkPlan(v any, plan *types.QueryPlan) {
+	switch node := v.(type) {
+	case bson.M:
+		switch node["stage"] {
+		case "COLLSCAN":
+			plan.FullScan = true
+		case "IXSCAN", "COUNT_SCAN", "DISTINCT_SCAN":
+			if name, ok := node["indexName"].(string); ok {
+				plan.Indexes = appendIndex(plan.Indexes, name)
+			}
+		}
+		for key, child := range node {
+			// 被淘汰的计划不是实际执行的计划
+			if key == "rejectedPlans" {
+				continue
+			}
+			walkPlan(child, plan)
+		}
+	case bson.D:
+		walkPlan(node.Map(), plan)
+	case bson.A:
+		for _, child := range node {
+			walkPlan(child, plan)
+		}
+	case []any:
+		walkPlan(bson.A(node), plan)
+	}
+}
+
+func appendIndex(list []string, name string) []string {
+	for _, v := range list {
+		if v == name {
+			return list
+		}
+	}
+	return append(list, name)
+}
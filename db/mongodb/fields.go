@@ -0,0 +1,82 @@
+package mongodb
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/lfhy/morm/schema"
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// 按字段更新 只更新传入的字段 零值同样写入
+// 生成 {$set: {字段: 值}} nil 指针写入 null
+func (m *Model) UpdateFields(data any, fields ...string) error {
+	m.CheckOID()
+	op := m.operation(types.OpUpdate)
+	op.Update = data
+	return m.invoke(op, func() error {
+		return m.updateFields(op, data, fields)
+	})
+}
+
+func (m *Model) updateFields(op *types.Operation, data any, fields []string) error {
+	model := m.Data
+	if data != nil {
+		m.Data = data
+	}
+	if err := m.beforeUpdate(m.Data); err != nil {
+		return err
+	}
+	set, err := selectFields(m.Data, fields)
+	if err != nil {
+		return err
+	}
+	return m.updateSet(op, model, set)
+}
+
+// 按字段名 列名或 bson 名取出字段的值
+func selectFields(data any, fields []string) (bson.M, error) {
+	if len(fields) == 0 {
+		return nil, errors.New("morm: no fields to update")
+	}
+	sch := schema.Of(data)
+	if sch == nil {
+		return nil, fmt.Errorf("%w: %s in %T", types.ErrUnknownField, strings.Join(fields, ","), data)
+	}
+	val := reflect.ValueOf(data)
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			val = reflect.New(val.Type().Elem())
+		}
+		val = val.Elem()
+	}
+	set := make(bson.M, len(fields))
+	for _, name := range fields {
+		f := sch.LookUp(name)
+		if f == nil || f.BSON == "" {
+			return nil, fmt.Errorf("%w: %s in %s", types.ErrUnknownField, name, sch.Type)
+		}
+		field := val.FieldByIndex(f.Index)
+		if field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				set[f.BSON] = nil
+				continue
+			}
+			field = field.Elem()
+		}
+		// 嵌套结构体与 ConvertToBSONM 保持一致
+		if field.Kind() == reflect.Struct && field.Type() != timeType {
+			nested, err := ConvertToBSONM(field.Interface())
+			if err != nil {
+				return nil, err
+			}
+			set[f.BSON] = nested
+			continue
+		}
+		set[f.BSON] = field.Interface()
+	}
+	return set, nil
+}
@@ -0,0 +1,79 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// 通过连接池事件统计的连接池状态
+// 主节点与就近读取两个客户端的连接池合并统计
+type poolStats struct {
+	maxOpen   int
+	open      atomic.Int64
+	inUse     atomic.Int64
+	checkouts atomic.Int64
+}
+
+func (s *poolStats) monitor() *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(evt *event.PoolEvent) {
+			switch evt.Type {
+			case event.ConnectionCreated:
+				s.open.Add(1)
+			case event.ConnectionClosed:
+				s.open.Add(-1)
+			case event.GetSucceeded:
+				s.inUse.Add(1)
+				s.checkouts.Add(1)
+			case event.ConnectionReturned:
+				s.inUse.Add(-1)
+			}
+		},
+	}
+}
+
+// 检查数据库连接 两个客户端都会检查
+func (m *DBConn) Ping(ctx context.Context) error {
+	if err := m.Client.Ping(ctx, readpref.Primary()); err != nil {
+		return err
+	}
+	if m.NearestClient != nil && m.NearestClient != m.Client {
+		return m.NearestClient.Ping(ctx, readpref.Nearest())
+	}
+	return nil
+}
+
+// 连接池状态
+func (m *DBConn) Stats() types.PoolStats {
+	stats := types.PoolStats{Backend: types.MongoDB}
+	if m.pool == nil {
+		return stats
+	}
+	stats.MaxOpenConnections = m.pool.maxOpen
+	stats.OpenConnections = int(m.pool.open.Load())
+	stats.InUse = int(m.pool.inUse.Load())
+	stats.Idle = stats.OpenConnections - stats.InUse
+	// 连接池事件无法区分是否等待 只统计获取次数
+	stats.Checkouts = m.pool.checkouts.Load()
+	return stats
+}
+
+// 断开两个客户端的连接 并停止后台任务
+func (m *DBConn) Close(ctx context.Context) error {
+	// 停止后台同步索引
+	m.background()
+	m.bgStop()
+	var errs []error
+	if m.NearestClient != nil && m.NearestClient != m.Client {
+		errs = append(errs, m.NearestClient.Disconnect(ctx))
+	}
+	if m.Client != nil {
+		errs = append(errs, m.Client.Disconnect(ctx))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,66 @@
+package mongodb
+
+import (
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// 绑定到当前连接的新模型 供钩子中继续操作数据库
+func (m *Model) newModel() *Model {
+	return &Model{
+		Tx:         m.Tx,
+		WhereList:  bson.M{},
+		Ctx:        m.Ctx,
+		Collection: m.Collection,
+	}
+}
+
+func (m *Model) beforeCreate(data any) error {
+	return types.CallHooks(data, func(hook types.BeforeCreateHook) error {
+		return hook.BeforeCreate(m.GetContext(), m.newModel())
+	})
+}
+
+func (m *Model) afterCreate(data any) error {
+	return types.CallHooks(data, func(hook types.AfterCreateHook) error {
+		return hook.AfterCreate(m.GetContext(), m.newModel())
+	})
+}
+
+// 数据是否实现了创建钩子
+func hasCreateHook(data any) bool {
+	var found bool
+	types.CallHooks(data, func(types.BeforeCreateHook) error {
+		found = true
+		return nil
+	})
+	types.CallHooks(data, func(types.AfterCreateHook) error {
+		found = true
+		return nil
+	})
+	return found
+}
+
+func (m *Model) beforeUpdate(data any) error {
+	return types.CallHooks(data, func(hook types.BeforeUpdateHook) error {
+		return hook.BeforeUpdate(m.GetContext(), m.newModel())
+	})
+}
+
+func (m *Model) afterUpdate(data any) error {
+	return types.CallHooks(data, func(hook types.AfterUpdateHook) error {
+		return hook.AfterUpdate(m.GetContext(), m.newModel())
+	})
+}
+
+func (m *Model) beforeDelete(data any) error {
+	return types.CallHooks(data, func(hook types.BeforeDeleteHook) error {
+		return hook.BeforeDelete(m.GetContext(), m.newModel())
+	})
+}
+
+func (m *Model) afterFind(data any) error {
+	return types.CallHooks(data, func(hook types.AfterFindHook) error {
+		return hook.AfterFind(m.GetContext(), m.newModel())
+	})
+}
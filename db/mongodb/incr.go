@@ -2,6 +2,7 @@ package mongodb
 
 import (
 	"github.com/lfhy/morm/log"
+	"github.com/lfhy/morm/types"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
@@ -9,14 +10,13 @@ import (
 // 生成 Mongo: {$inc: {field: amount}}
 func (m *Model) Incr(column string, amount int64) error {
 	m.CheckOID()
-	_, err := m.Tx.Client.
-		Database(m.Tx.Database).
-		Collection(m.GetCollection(m.Data)).
-		UpdateMany(m.GetContext(), m.WhereList, bson.M{"$inc": bson.M{column: amount}})
-	if err != nil {
-		log.Error(err)
-	}
-	return err
+	update := bson.M{"$inc": bson.M{column: amount}}
+	fillCurrentDate(m.Data, update)
+	op := m.operation(types.OpIncr)
+	op.Update = update
+	return m.invoke(op, func() error {
+		return m.updateMany(op, update)
+	})
 }
 
 // UpdateColumns 用 bson.M / bson.D 原样更新字段。
@@ -40,7 +40,11 @@ func (m *Model) UpdateColumns(data any) error {
 				update[k] = val
 			}
 		} else {
-			update["$set"] = v
+			set, err := m.encrypt(v)
+			if err != nil {
+				return err
+			}
+			update["$set"] = bson.M(set)
 		}
 	case bson.D:
 		update["$set"] = v
@@ -51,17 +55,31 @@ func (m *Model) UpdateColumns(data any) error {
 			return err
 		}
 		delete(bsonData, "_id")
+		if bsonData, err = m.encrypt(bsonData); err != nil {
+			return err
+		}
 		update["$set"] = bsonData
 	}
 	if len(update) == 0 {
 		return nil
 	}
-	_, err := m.Tx.Client.
+	fillCurrentDate(m.Data, update)
+	op := m.operation(types.OpUpdateColumns)
+	op.Update = update
+	return m.invoke(op, func() error {
+		return m.updateMany(op, update)
+	})
+}
+
+func (m *Model) updateMany(op *types.Operation, update bson.M) error {
+	result, err := m.Tx.Client.
 		Database(m.Tx.Database).
 		Collection(m.GetCollection(m.Data)).
 		UpdateMany(m.GetContext(), m.WhereList, update)
 	if err != nil {
 		log.Error(err)
+		return err
 	}
-	return err
+	op.Rows = result.ModifiedCount
+	return nil
 }
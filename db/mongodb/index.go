@@ -0,0 +1,417 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lfhy/morm/log"
+	"github.com/lfhy/morm/schema"
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 数据库中已有的索引
+type IndexInfo struct {
+	Name               string   `bson:"name"`
+	Key                bson.D   `bson:"key"`
+	Unique             bool     `bson:"unique"`
+	Sparse             bool     `bson:"sparse"`
+	ExpireAfterSeconds *int64   `bson:"expireAfterSeconds"`
+	Weights            bson.M   `bson:"weights"`
+	PartialFilter      bson.Raw `bson:"partialFilterExpression"`
+}
+
+// 模型与数据库的索引差异
+type IndexDiff struct {
+	// 需要新建的索引
+	Missing []types.IndexSpec
+	// 定义不一致的索引
+	Changed []types.IndexSpec
+	// 模型没有声明的索引
+	Extra []string
+}
+
+// 模型声明的索引 包括 morm 标签与 Indexes 方法
+// 同名索引以 Indexes 方法返回的为准 gorm 标签中的索引只用于 SQL
+func ModelIndexes(data any) ([]types.IndexSpec, error) {
+	var specs []types.IndexSpec
+	groups := make(map[string]int)
+	add := func(spec types.IndexSpec) {
+		if spec.Name != "" {
+			if i, ok := groups[spec.Name]; ok {
+				// 同名的字段组成联合索引
+				specs[i].Keys = append(specs[i].Keys, spec.Keys...)
+				specs[i].Unique = specs[i].Unique || spec.Unique
+				return
+			}
+			groups[spec.Name] = len(specs)
+		}
+		specs = append(specs, spec)
+	}
+	sch := schema.Of(data)
+	if sch == nil {
+		return nil, fmt.Errorf("morm: %T is not a struct", data)
+	}
+	text := -1
+	for _, f := range sch.Flat {
+		if f.BSON == "" || f.BSON == "_id" {
+			continue
+		}
+		key := bson.D{{Key: f.BSON, Value: 1}}
+		if v, ok := f.Options["ttl"]; ok {
+			ttl, err := time.ParseDuration(v)
+			if err != nil || ttl <= 0 {
+				return nil, fmt.Errorf("morm: invalid ttl %q on field %s", v, f.Name)
+			}
+			add(types.IndexSpec{Keys: key, TTL: ttl, Unique: f.Has("unique")})
+		} else {
+			switch {
+			case f.Has("unique"):
+				add(types.IndexSpec{Name: f.Options["unique"], Keys: key, Unique: true})
+			case f.Has("index"):
+				add(types.IndexSpec{Name: f.Options["index"], Keys: key})
+			}
+		}
+		if f.Has("text") {
+			// 一个集合只能有一个文本索引
+			if text < 0 {
+				text = len(specs)
+				specs = append(specs, types.IndexSpec{Name: f.Options["text"]})
+			}
+			specs[text].Keys = append(specs[text].Keys, bson.E{Key: f.BSON, Value: "text"})
+		}
+	}
+	if indexer, ok := asIndexer(data); ok {
+		for _, spec := range indexer.Indexes() {
+			if spec.Name == "" {
+				spec.Name = IndexName(spec.Keys)
+			}
+			for i := range specs {
+				if specs[i].Name == spec.Name {
+					specs = append(specs[:i], specs[i+1:]...)
+					break
+				}
+			}
+			specs = append(specs, spec)
+		}
+	}
+	for i := range specs {
+		if specs[i].Name == "" {
+			specs[i].Name = IndexName(specs[i].Keys)
+		}
+	}
+	return specs, nil
+}
+
+// 值接收者与指针接收者的 Indexes 方法都可以识别
+func asIndexer(data any) (types.Indexer, bool) {
+	if indexer, ok := data.(types.Indexer); ok {
+		return indexer, true
+	}
+	typ := reflect.TypeOf(data)
+	if typ == nil {
+		return nil, false
+	}
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	indexer, ok := reflect.New(typ).Interface().(types.Indexer)
+	return indexer, ok
+}
+
+// 与 MongoDB 默认规则一致的索引名 如 name_1_age_-1
+func IndexName(keys bson.D) string {
+	parts := make([]string, 0, len(keys)*2)
+	for _, k := range keys {
+		parts = append(parts, k.Key, fmt.Sprint(k.Value))
+	}
+	return strings.Join(parts, "_")
+}
+
+// 转换为驱动的索引定义
+func IndexModel(spec types.IndexSpec) mongo.IndexModel {
+	opts := options.Index().SetName(spec.Name)
+	if spec.Unique {
+		opts.SetUnique(true)
+	}
+	if spec.Sparse {
+		opts.SetSparse(true)
+	}
+	if spec.TTL > 0 {
+		opts.SetExpireAfterSeconds(int32(spec.TTL / time.Second))
+	}
+	if spec.PartialFilter != nil {
+		opts.SetPartialFilterExpression(spec.PartialFilter)
+	}
+	if spec.Collation != nil {
+		opts.SetCollation(spec.Collation)
+	}
+	return mongo.IndexModel{Keys: spec.Keys, Options: opts}
+}
+
+// 读取集合的索引 集合不存在时返回空
+func ListIndexes(ctx context.Context, coll *mongo.Collection) ([]IndexInfo, error) {
+	cursor, err := coll.Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var list []IndexInfo
+	if err := cursor.All(ctx, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// 对比模型声明的索引与数据库中的索引
+// 按索引名匹配 名称不同但定义相同的索引视为已存在
+// 部分索引条件与排序规则不参与对比
+func DiffIndexes(want []types.IndexSpec, existing []IndexInfo) IndexDiff {
+	var diff IndexDiff
+	used := make(map[string]bool)
+	for _, spec := range want {
+		info := findIndex(existing, func(info IndexInfo) bool { return info.Name == spec.Name })
+		if info == nil {
+			info = findIndex(existing, func(info IndexInfo) bool { return !used[info.Name] && sameIndex(spec, info) })
+		}
+		switch {
+		case info == nil:
+			diff.Missing = append(diff.Missing, spec)
+		case !sameIndex(spec, *info):
+			diff.Changed = append(diff.Changed, spec)
+		}
+		if info != nil {
+			used[info.Name] = true
+		}
+	}
+	for _, info := range existing {
+		if info.Name != "_id_" && !used[info.Name] {
+			diff.Extra = append(diff.Extra, info.Name)
+		}
+	}
+	return diff
+}
+
+func findIndex(list []IndexInfo, match func(IndexInfo) bool) *IndexInfo {
+	for i := range list {
+		if match(list[i]) {
+			return &list[i]
+		}
+	}
+	return nil
+}
+
+func sameIndex(spec types.IndexSpec, info IndexInfo) bool {
+	if spec.Unique != info.Unique || spec.Sparse != info.Sparse {
+		return false
+	}
+	ttl := int64(spec.TTL / time.Second)
+	if (spec.TTL > 0) != (info.ExpireAfterSeconds != nil) || (info.ExpireAfterSeconds != nil && *info.ExpireAfterSeconds != ttl) {
+		return false
+	}
+	if (spec.PartialFilter != nil) != (len(info.PartialFilter) > 0) {
+		return false
+	}
+	// 文本索引的字段保存在 weights 中
+	var textFields []string
+	keys := make(bson.D, 0, len(spec.Keys))
+	for _, k := range spec.Keys {
+		if k.Value == "text" {
+			textFields = append(textFields, k.Key)
+			continue
+		}
+		keys = append(keys, k)
+	}
+	if len(textFields) > 0 {
+		var weights []string
+		for k := range info.Weights {
+			weights = append(weights, k)
+		}
+		sort.Strings(textFields)
+		sort.Strings(weights)
+		if strings.Join(textFields, ",") != strings.Join(weights, ",") {
+			return false
+		}
+		var rest bson.D
+		for _, k := range info.Key {
+			if k.Key != "_fts" && k.Key != "_ftsx" {
+				rest = append(rest, k)
+			}
+		}
+		return sameKeys(keys, rest)
+	}
+	return sameKeys(keys, info.Key)
+}
+
+func sameKeys(a, b bson.D) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Key != b[i].Key || keyValue(a[i].Value) != keyValue(b[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// 数字统一格式 服务端返回的方向可能是 int32 或 double
+func keyValue(v any) string {
+	switch n := v.(type) {
+	case int:
+		return fmt.Sprint(float64(n))
+	case int32:
+		return fmt.Sprint(float64(n))
+	case int64:
+		return fmt.Sprint(float64(n))
+	case float64:
+		return fmt.Sprint(n)
+	}
+	return fmt.Sprint(v)
+}
+
+// 同步模型声明的索引 新建缺少的索引 报告模型没有声明的索引
+func (m *DBConn) SyncIndexes(ctx context.Context, models ...any) ([]types.IndexReport, error) {
+	return m.SyncIndexesWithOptions(ctx, types.SyncIndexOptions{}, models...)
+}
+
+// 同步模型声明的索引
+// opts.Drop 为 true 时删除模型没有声明的索引 并重建定义不一致的索引
+func (m *DBConn) SyncIndexesWithOptions(ctx context.Context, opts types.SyncIndexOptions, models ...any) ([]types.IndexReport, error) {
+	reports := make([]types.IndexReport, 0, len(models))
+	for _, data := range models {
+		report, err := m.syncIndexes(ctx, data, opts)
+		reports = append(reports, report)
+		if err != nil {
+			return reports, err
+		}
+	}
+	return reports, nil
+}
+
+func (m *DBConn) syncIndexes(ctx context.Context, data any, opts types.SyncIndexOptions) (types.IndexReport, error) {
+	report := types.IndexReport{Collection: GetTableName(data)}
+	want, err := ModelIndexes(data)
+	if err != nil {
+		return report, err
+	}
+	coll := m.Client.Database(m.Database).Collection(report.Collection)
+	existing, err := ListIndexes(ctx, coll)
+	if err != nil {
+		return report, err
+	}
+	diff := DiffIndexes(want, existing)
+	report.Extra = diff.Extra
+	create := diff.Missing
+	for _, spec := range diff.Changed {
+		report.Changed = append(report.Changed, spec.Name)
+	}
+	if opts.Drop {
+		drop := append(append([]string(nil), report.Extra...), report.Changed...)
+		for _, name := range drop {
+			if _, err := coll.Indexes().DropOne(ctx, name); err != nil {
+				return report, fmt.Errorf("drop index %s.%s: %w", report.Collection, name, err)
+			}
+			report.Dropped = append(report.Dropped, name)
+		}
+		create = append(create, diff.Changed...)
+	}
+	if len(create) == 0 {
+		return report, nil
+	}
+	indexes := make([]mongo.IndexModel, len(create))
+	for i, spec := range create {
+		indexes[i] = IndexModel(spec)
+	}
+	names, err := coll.Indexes().CreateMany(ctx, indexes)
+	report.Created = names
+	if err != nil {
+		return report, fmt.Errorf("create indexes on %s: %w", report.Collection, err)
+	}
+	return report, nil
+}
+
+// 自动同步索引的超时时间与失败后的重试间隔
+const (
+	autoSyncTimeout    = 30 * time.Second
+	autoSyncBackoff    = time.Second
+	autoSyncMaxBackoff = time.Minute
+)
+
+// 开启自动创表时 每个集合第一次使用时在后台同步一次索引 不阻塞 Model
+// 模型声明了校验规则时同时应用校验规则 失败时按退避间隔重试 直到成功或 Close
+// 需要在启动时确保索引已创建时直接调用 SyncIndexes
+func (m *DBConn) autoSyncIndexes(data any) {
+	if _, ok := data.(string); ok || data == nil {
+		return
+	}
+	done := make(chan struct{})
+	if _, loaded := m.indexSynced.LoadOrStore(GetTableName(data), done); loaded {
+		return
+	}
+	ctx := m.background()
+	go func() {
+		defer close(done)
+		for backoff := autoSyncBackoff; !m.autoSyncOnce(ctx, data); backoff = min(backoff*2, autoSyncMaxBackoff) {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+		}
+	}()
+}
+
+// 同步一次校验规则与索引 返回是否成功
+func (m *DBConn) autoSyncOnce(ctx context.Context, data any) bool {
+	ctx, cancel := context.WithTimeout(ctx, autoSyncTimeout)
+	defer cancel()
+	synced := true
+	if HasValidationRules(data) {
+		if err := m.SyncValidators(ctx, types.ValidatorOptions{}, data); err != nil {
+			synced = false
+			log.Error("同步校验规则失败:", err)
+		}
+	}
+	reports, err := m.SyncIndexes(ctx, data)
+	if err != nil {
+		log.Error("同步索引失败:", err)
+		return false
+	}
+	for _, r := range reports {
+		if len(r.Extra) > 0 || len(r.Changed) > 0 {
+			log.Warnf("索引与模型不一致 collection:%s extra:%v changed:%v", r.Collection, r.Extra, r.Changed)
+		}
+	}
+	return synced
+}
+
+// 等待后台同步索引结束 同步失败时会一直重试到 ctx 结束
+func (m *DBConn) WaitIndexSync(ctx context.Context) error {
+	var pending []chan struct{}
+	m.indexSynced.Range(func(_, v any) bool {
+		pending = append(pending, v.(chan struct{}))
+		return true
+	})
+	for _, done := range pending {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// 后台任务的上下文 Close 时取消
+func (m *DBConn) background() context.Context {
+	m.bgOnce.Do(func() {
+		m.bgCtx, m.bgStop = context.WithCancel(context.Background())
+	})
+	return m.bgCtx
+}
@@ -0,0 +1,223 @@
+package mongodb
+
+import (
+	"strings"
+
+	"github.com/lfhy/morm/log"
+	"github.com/lfhy/morm/schema"
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// 连接的集合 $lookup 的结果使用集合名作为字段名
+type join struct {
+	collection string
+	model      any
+	local      string
+	foreign    string
+	left       bool
+}
+
+// 内连接 没有关联文档的记录不返回
+func (m *Model) Join(model any, on string) types.ORMModel {
+	return m.join(model, on, false)
+}
+
+// 左连接
+func (m *Model) LeftJoin(model any, on string) types.ORMModel {
+	return m.join(model, on, true)
+}
+
+func (m *Model) join(model any, on string, left bool) types.ORMModel {
+	coll := GetTableName(model)
+	joined, other, err := types.ParseJoinOn(on, coll)
+	if err != nil {
+		m.err = err
+		return m
+	}
+	// 连接本集合的字段直接使用 连接已连接的集合时使用展开后的路径
+	local := joinField(m.joinModel(other.Table), other.Field)
+	if other.Table != m.GetCollection(m.Data) {
+		local = other.Table + "." + local
+	}
+	m.joins = append(m.joins, join{
+		collection: coll,
+		model:      model,
+		local:      local,
+		foreign:    joinField(model, joined.Field),
+		left:       left,
+	})
+	return m
+}
+
+// 按集合名找到本集合或已连接的集合对应的模型
+func (m *Model) joinModel(coll string) any {
+	if coll == m.GetCollection(m.Data) {
+		return m.Data
+	}
+	for _, j := range m.joins {
+		if j.collection == coll {
+			return j.model
+		}
+	}
+	return nil
+}
+
+// 连接条件中的字段转换为 bson 名 找不到时按原样使用
+func joinField(model any, name string) string {
+	if _, ok := model.(string); ok {
+		return name
+	}
+	if f := schema.Of(model).LookUp(name); f != nil {
+		return bsonName(f)
+	}
+	return name
+}
+
+// 连接查询使用的聚合管道
+// 依次为每个连接的集合生成 $lookup 与 $unwind 之后是条件 排序与分页 最后去掉连接的字段
+func (m *Model) JoinPipeline() mongo.Pipeline {
+	var pipeline mongo.Pipeline
+	pipeline = append(pipeline, m.lookupStages()...)
+	pipeline = append(pipeline, bson.D{{Key: "$match", Value: m.joinFilter(m.WhereList)}})
+	opts := m.makeAllQuery()
+	if sort, ok := opts.Sort.(bson.D); ok && len(sort) > 0 {
+		stage := make(bson.D, 0, len(sort))
+		for _, e := range sort {
+			stage = append(stage, bson.E{Key: m.joinKey(e.Key), Value: e.Value})
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$sort", Value: stage}})
+	} else if opts.Sort != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$sort", Value: opts.Sort}})
+	}
+	if opts.Skip != nil && *opts.Skip > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$skip", Value: *opts.Skip}})
+	}
+	if opts.Limit != nil && *opts.Limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: *opts.Limit}})
+	}
+	return append(pipeline, m.projectStage())
+}
+
+// 连接计数的聚合管道
+func (m *Model) joinCountPipeline() mongo.Pipeline {
+	pipeline := append(m.lookupStages(), bson.D{{Key: "$match", Value: m.joinFilter(m.WhereList)}})
+	return append(pipeline, bson.D{{Key: "$count", Value: "count"}})
+}
+
+func (m *Model) lookupStages() mongo.Pipeline {
+	var stages mongo.Pipeline
+	for _, j := range m.joins {
+		stages = append(stages,
+			bson.D{{Key: "$lookup", Value: bson.D{
+				{Key: "from", Value: j.collection},
+				{Key: "localField", Value: j.local},
+				{Key: "foreignField", Value: j.foreign},
+				{Key: "as", Value: j.collection},
+			}}},
+			bson.D{{Key: "$unwind", Value: bson.D{
+				{Key: "path", Value: "$" + j.collection},
+				{Key: "preserveNullAndEmptyArrays", Value: j.left},
+			}}},
+		)
+	}
+	return stages
+}
+
+// 查询结果中去掉连接的集合
+func (m *Model) projectStage() bson.D {
+	project := make(bson.D, 0, len(m.joins))
+	for _, j := range m.joins {
+		project = append(project, bson.E{Key: j.collection, Value: 0})
+	}
+	return bson.D{{Key: "$project", Value: project}}
+}
+
+// 连接后的条件 本集合的字段去掉集合名 连接的集合中的字段转换为 bson 名
+func (m *Model) joinFilter(filter bson.M) bson.M {
+	result := make(bson.M, len(filter))
+	for k, v := range filter {
+		switch k {
+		case "$or", "$and", "$nor":
+			if list, ok := v.(bson.A); ok {
+				converted := make(bson.A, 0, len(list))
+				for _, item := range list {
+					if sub, ok := item.(bson.M); ok {
+						item = m.joinFilter(sub)
+					}
+					converted = append(converted, item)
+				}
+				v = converted
+			}
+		}
+		result[m.joinKey(k)] = v
+	}
+	return result
+}
+
+func (m *Model) joinKey(key string) string {
+	coll, field, ok := strings.Cut(key, ".")
+	if !ok {
+		return key
+	}
+	if coll == m.GetCollection(m.Data) {
+		return field
+	}
+	for _, j := range m.joins {
+		if j.collection == coll {
+			name, rest, nested := strings.Cut(field, ".")
+			name = joinField(j.model, name)
+			if nested {
+				name += "." + rest
+			}
+			return coll + "." + name
+		}
+	}
+	return key
+}
+
+// 执行聚合
+func (m *Model) aggregate(pipeline any) (*mongo.Cursor, error) {
+	log.Debugf("聚合集合 %v Mongo聚合管道: %+v", m.GetCollection(m.Data), pipeline)
+	cur, err := m.Tx.Client.Database(m.Tx.Database).Collection(m.GetCollection(m.Data)).Aggregate(m.GetContext(), pipeline)
+	if err != nil {
+		log.Errorf("Mongo聚合出错: %v\n", err)
+	}
+	return cur, err
+}
+
+// 连接查询一条数据 没有数据时与 FindOne 一致返回 mongo.ErrNoDocuments
+func (m *Model) aggregateOne(data any) error {
+	pipeline := m.JoinPipeline()
+	project := pipeline[len(pipeline)-1]
+	pipeline = append(pipeline[:len(pipeline)-1], bson.D{{Key: "$limit", Value: 1}}, project)
+	cur, err := m.aggregate(pipeline)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(m.GetContext())
+	if !cur.Next(m.GetContext()) {
+		if err := cur.Err(); err != nil {
+			return err
+		}
+		return mongo.ErrNoDocuments
+	}
+	return cur.Decode(data)
+}
+
+// 连接查询的计数
+func (m *Model) aggregateCount() (int64, error) {
+	cur, err := m.aggregate(m.joinCountPipeline())
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(m.GetContext())
+	var result []struct {
+		Count int64 `bson:"count"`
+	}
+	if err := cur.All(m.GetContext(), &result); err != nil || len(result) == 0 {
+		return 0, err
+	}
+	return result[0].Count, nil
+}
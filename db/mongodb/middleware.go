@@ -0,0 +1,68 @@
+package mongodb
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/lfhy/morm/log"
+	"github.com/lfhy/morm/types"
+)
+
+// 注册中间件
+func (m *DBConn) Use(middlewares ...types.Middleware) {
+	m.middlewareLock.Lock()
+	defer m.middlewareLock.Unlock()
+	list := make([]types.Middleware, 0, len(m.middlewares)+len(middlewares))
+	list = append(list, m.middlewares...)
+	m.middlewares = append(list, middlewares...)
+}
+
+func (m *DBConn) getMiddlewares() []types.Middleware {
+	m.middlewareLock.RLock()
+	defer m.middlewareLock.RUnlock()
+	return m.middlewares
+}
+
+// 生成操作描述
+func (m *Model) operation(opType types.OpType) *types.Operation {
+	return &types.Operation{
+		Type:    opType,
+		Backend: types.MongoDB,
+		Table:   m.GetCollection(m.Data),
+		Filter:  m.WhereList,
+	}
+}
+
+// 经过中间件执行操作 并记录结构化日志
+// 执行期间模型使用中间件传入的上下文
+func (m *Model) invoke(op *types.Operation, fn func() error) error {
+	ctx := m.GetContext()
+	err := types.Invoke(ctx, op, m.Tx.getMiddlewares(), func(ctx context.Context) error {
+		prev := m.Ctx
+		m.Ctx = ctx
+		defer func() {
+			m.Ctx = prev
+		}()
+		if m.err != nil {
+			return m.err
+		}
+		return fn()
+	})
+	log.Operation(ctx, op, err)
+	return err
+}
+
+// 切片长度 用于填充查询返回的行数
+func sliceLen(data any) int64 {
+	rv := reflect.ValueOf(data)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return 0
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		return int64(rv.Len())
+	}
+	return 1
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"sync"
 
+	"github.com/lfhy/morm/log"
 	"github.com/lfhy/morm/types"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -15,7 +16,24 @@ type CtxKey string
 type DBConn struct {
 	Database      string //连接的数据库
 	NearestClient *mongo.Client
+	// 乐观锁版本冲突时事务的重试次数
+	StaleRetry int
 	*mongo.Client
+	// 中间件
+	middlewares    []types.Middleware
+	middlewareLock sync.RWMutex
+	// 连接池状态
+	pool *poolStats
+	// 自动同步模型声明的索引 在后台执行
+	AutoMigrate bool
+	indexSynced sync.Map
+	// 后台任务 Close 时停止
+	bgCtx  context.Context
+	bgStop context.CancelFunc
+	bgOnce sync.Once
+	// 文档校验的默认级别与处理方式
+	ValidationLevel  string
+	ValidationAction string
 }
 
 var ORMConn *DBConn
@@ -27,9 +45,17 @@ type Model struct {
 	WhereList  bson.M
 	Ctx        context.Context //上下文
 	Collection string
+	err        error // 构造条件时的错误 在执行操作时返回
+	preloads   []preload
+	joins      []join
 }
 
 func (m *DBConn) Model(data any) types.ORMModel {
+	// 记录敏感字段 日志中按字段名脱敏
+	log.RegisterSensitive(data)
+	if m.AutoMigrate {
+		m.autoSyncIndexes(data)
+	}
 	model := &Model{Data: data, Tx: m, WhereList: bson.M{}, OpList: sync.Map{}}
 	model.Collection = model.GetCollection(data)
 	return model
@@ -7,12 +7,12 @@ import (
 	"net/url"
 	"reflect"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/lfhy/morm/conf"
 	"github.com/lfhy/morm/log"
+	"github.com/lfhy/morm/schema"
 	"github.com/lfhy/morm/types"
 	"golang.org/x/net/proxy"
 
@@ -81,13 +81,40 @@ func Init() (types.ORM, error) {
 		})
 	}
 
+	// 命令与连接池监听 内置慢查询日志
+	slowThreshold := conf.ReadConfigToTimeDuration("mongodb", "slow_threshold")
+	if slowThreshold <= 0 {
+		slowThreshold = log.DefaultSlowThreshold
+	}
+	if monitor := commandMonitor(NewSlowMonitor(slowThreshold)); monitor != nil {
+		opts.SetMonitor(monitor)
+	}
+	pool := &poolStats{maxOpen: poolSize * 2}
+	if readMode == "master" {
+		pool.maxOpen = poolSize
+	}
+	opts.SetPoolMonitor(poolMonitor(pool.monitor()))
+
+	// 支持 morm 标签
+	opts.SetRegistry(NewRegistry())
+
 	// 连接mongodb
 	client, err := mongo.Connect(ctx, opts)
 
 	if err != nil {
 		return nil, err
 	}
-	conn := DBConn{Database: conf.ReadConfigToString("mongodb", "database"), Client: client, NearestClient: client}
+	conn := DBConn{
+		Database:      conf.ReadConfigToString("mongodb", "database"),
+		Client:        client,
+		NearestClient: client,
+		StaleRetry:    conf.ReadConfigToInt("db", "stale_retry"),
+		AutoMigrate:   conf.ReadConfigToBool("db", "auto_create_table"),
+		pool:          pool,
+
+		ValidationLevel:  conf.ReadConfigToString("mongodb", "validation_level"),
+		ValidationAction: conf.ReadConfigToString("mongodb", "validation_action"),
+	}
 	ORMConn = &conn
 	if readMode == "master" {
 		return ORMConn, nil
@@ -133,6 +160,13 @@ func GetTableName(dest any) string {
 
 // 启动事务做函数调用
 func (m *Model) Session(transactionFunc func(session types.Session) error) error {
+	op := m.operation(types.OpSession)
+	return m.invoke(op, func() error {
+		return m.session(transactionFunc)
+	})
+}
+
+func (m *Model) session(transactionFunc func(session types.Session) error) error {
 	// 创建会话
 	session, err := m.Tx.Client.StartSession()
 	if err != nil {
@@ -142,23 +176,35 @@ func (m *Model) Session(transactionFunc func(session types.Session) error) error
 	sctx := m.GetContext()
 	defer session.EndSession(sctx)
 
-	sessionModel := &SessionModel{session: session, Model: m}
+	for i := 0; ; i++ {
+		sessionModel := &SessionModel{session: session, Model: m}
 
-	adaptedFunc := func(ctx mongo.SessionContext) (any, error) {
-		return nil, transactionFunc(sessionModel)
-	}
+		// 回调中的操作使用会话上下文 在事务中执行 重试前的写入会随事务回滚
+		adaptedFunc := func(ctx mongo.SessionContext) (any, error) {
+			prev := m.Ctx
+			m.Ctx = ctx
+			defer func() { m.Ctx = prev }()
+			return nil, transactionFunc(sessionModel)
+		}
 
-	_, err = session.WithTransaction(sctx, adaptedFunc)
-	// 需要排除是否用户主动操作事务
-	if err == nil && sessionModel.userControlTranslator {
+		_, err = session.WithTransaction(sctx, adaptedFunc)
+		// 需要排除是否用户主动操作事务
+		if err == nil && sessionModel.userControlTranslator {
+			return nil
+		}
+		if err != nil {
+			session.AbortTransaction(sctx)
+			// 乐观锁版本冲突时按 StaleRetry 配置重新执行整个事务
+			if errors.Is(err, types.ErrStaleVersion) && i < m.Tx.StaleRetry {
+				log.Debugf("乐观锁版本冲突 重试事务: %d\n", i+1)
+				continue
+			}
+			log.Error(err)
+			return err
+		}
+		session.CommitTransaction(sctx)
 		return nil
 	}
-	if err != nil {
-		session.AbortTransaction(sctx)
-		return log.Error(err)
-	}
-	session.CommitTransaction(sctx)
-	return nil
 }
 
 type SessionModel struct {
@@ -172,6 +218,7 @@ func (s *SessionModel) SwitchModel(data any) types.ORMModel {
 	m := &Model{
 		Data:       data,
 		Tx:         s.Tx,
+		Ctx:        s.Ctx,
 		WhereList:  bson.M{},
 		OpList:     sync.Map{},
 		Collection: "",
@@ -231,31 +278,20 @@ func ConvertToBSONM(data any) (bson.M, error) {
 		return nil, fmt.Errorf("input must be a struct or pointer to struct")
 	}
 
-	typ := val.Type()
+	sch := schema.Parse(val.Type())
 
 	for i := 0; i < val.NumField(); i++ {
 		field := val.Field(i)
-		structField := typ.Field(i)
+		fieldInfo := sch.Fields[i]
 
-		// 解析 bson 标签（处理 `bson:"fieldName,omitempty"` 格式）
-		tag, ok := structField.Tag.Lookup("bson")
-		if !ok {
-			continue
-		}
-		parts := strings.Split(tag, ",")
-		fieldName := parts[0]
+		// 使用 morm 标签的 column 或 bson 标签的字段名
+		fieldName := fieldInfo.BSON
 		if fieldName == "" {
-			fieldName = strings.ToLower(structField.Name) // 默认字段名
+			continue
 		}
 
 		// 是否设置零值（根据 must 标志）
-		must := false
-		for _, part := range parts[1:] {
-			if part == "must" {
-				must = true
-				break
-			}
-		}
+		must := fieldInfo.Must
 
 		// 检查零值并跳过（若需要）
 		if !must && isZero(field) {
@@ -271,7 +307,7 @@ func ConvertToBSONM(data any) (bson.M, error) {
 		}
 
 		// time.Time 直接写入，避免被递归转换成空对象
-		if field.Type() == reflect.TypeOf(time.Time{}) {
+		if field.Type() == timeType {
 			bsonData[fieldName] = field.Interface()
 			continue
 		}
@@ -319,29 +355,43 @@ func ConvertToBSONM(data any) (bson.M, error) {
 
 // 辅助函数：判断零值
 func isZero(v reflect.Value) bool {
-	switch v.Kind() {
-	case reflect.Ptr, reflect.Interface:
-		return v.IsNil()
-	default:
-		return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
-	}
+	return v.IsZero()
 }
 
 func (m *Model) Create(data any) (id string, err error) {
 	m.CheckOID()
+	op := m.operation(types.OpCreate)
+	op.Update = data
+	err = m.invoke(op, func() error {
+		id, err = m.create(op, data)
+		return err
+	})
+	return
+}
+
+func (m *Model) create(op *types.Operation, data any) (id string, err error) {
 	if data != nil {
 		m.Data = data
 	}
+	if err = m.beforeCreate(m.Data); err != nil {
+		return "", err
+	}
 	bsonData, err := ConvertToBSONM(m.Data)
 	if err != nil {
 		return "", err
 	}
-	log.Debugf("创建MongoDB数据: %+v\n", bsonData)
+	if bsonData, err = m.encrypt(bsonData); err != nil {
+		return "", err
+	}
+	fillCreateTime(m.Data, bsonData)
+	fillCreateVersion(m.Data, bsonData)
+	log.Debugf("创建MongoDB数据: %+v\n", log.Table(m.GetCollection(m.Data), bsonData))
 	result, err := m.Tx.Client.Database(m.Tx.Database).Collection(m.GetCollection(m.Data)).InsertOne(m.GetContext(), bsonData)
 	if err != nil {
 		log.Error(err)
 		return "", err
 	}
+	op.Rows = 1
 	if result.InsertedID == nil {
 		if m.WhereList["_id"] != nil {
 			id = fmt.Sprint(m.WhereList["_id"])
@@ -358,6 +408,7 @@ func (m *Model) Create(data any) (id string, err error) {
 	}
 	setIDField(m.Data, id)
 	// log.Debugf("写入后的Date数据: %+v\n", m.Data)
+	err = m.afterCreate(m.Data)
 	return
 }
 
@@ -367,16 +418,37 @@ func (m *Model) Insert(data any) error {
 }
 
 // 更新或插入数据
-func (m *Model) Save(data any, value ...any) (err error) {
+func (m *Model) Save(data any, value ...any) error {
 	m.CheckOID()
+	op := m.operation(types.OpSave)
+	op.Update = data
+	return m.invoke(op, func() error {
+		return m.save(op, data, value...)
+	})
+}
+
+func (m *Model) save(op *types.Operation, data any, value ...any) (err error) {
 	if data != nil {
 		m.Data = data
 	}
+	// 插入数据时调用创建钩子 更新数据时调用更新钩子
+	insert, err := m.saveInserts()
+	if err != nil {
+		return err
+	}
+	if insert {
+		err = m.beforeCreate(m.Data)
+	} else {
+		err = m.beforeUpdate(m.Data)
+	}
+	if err != nil {
+		return err
+	}
 	bsonData, err := ConvertToBSONM(data)
 	if err != nil {
 		return err
 	}
-	log.Debugf("MongoDB保存Where条件: %+v\n", m.WhereList)
+	log.Debugf("MongoDB保存Where条件: %+v\n", log.Table(m.GetCollection(m.Data), m.WhereList))
 	update := make(bson.M)
 	if len(bsonData) != 0 {
 		update["$set"] = bsonData
@@ -398,14 +470,56 @@ func (m *Model) Save(data any, value ...any) (err error) {
 			}
 		}
 	}
-	log.Debugf("MongoDB保存条件: %+v\n", update)
+	if err := m.encryptUpdate(update); err != nil {
+		return err
+	}
+	fillUpdateTime(m.Data, update, true)
+	filter := m.WhereList
+	vf := getVersionField(m.Data)
+	if vf != nil {
+		filter = saveFilter(bsonData, m.WhereList)
+		versioned, current := applyVersion(vf, m.Data, filter, update)
+		if current != 0 {
+			// 带版本号保存时先按版本更新 未匹配且数据存在时说明版本已过期
+			coll := m.Tx.Client.Database(m.Tx.Database).Collection(m.GetCollection(m.Data))
+			result, err := coll.UpdateOne(m.GetContext(), versioned, update)
+			if err != nil {
+				log.Error(err)
+				return err
+			}
+			op.Rows = result.ModifiedCount
+			if result.MatchedCount > 0 {
+				if err := checkVersion(vf, m.Data, current, result.MatchedCount); err != nil {
+					return err
+				}
+				return m.afterUpdate(m.Data)
+			}
+			// 没有条件可以定位数据时视为不存在
+			if exists := existsFilter(filter); exists != nil {
+				count, err := coll.CountDocuments(m.GetContext(), exists)
+				if err != nil {
+					log.Error(err)
+					return err
+				}
+				if count > 0 {
+					return types.ErrStaleVersion
+				}
+			}
+		}
+	}
+	log.Debugf("MongoDB保存条件: %+v\n", log.Table(m.GetCollection(m.Data), update))
 
 	opts := options.Update().SetUpsert(true)
-	result, err := m.Tx.Client.Database(m.Tx.Database).Collection(m.GetCollection(m.Data)).UpdateOne(m.GetContext(), m.WhereList, update, opts)
+	result, err := m.Tx.Client.Database(m.Tx.Database).Collection(m.GetCollection(m.Data)).UpdateOne(m.GetContext(), filter, update, opts)
 	if err != nil {
 		log.Error(err)
 		return err
 	}
+	op.Rows = result.ModifiedCount + result.UpsertedCount
+	if vf != nil && result.UpsertedCount > 0 {
+		// 数据不存在时插入 版本号由 $inc 初始化为 1 与 Create 一致
+		vf.set(m.Data, 1)
+	}
 	var id string
 	if result.UpsertedID == nil {
 		if m.WhereList["_id"] != nil {
@@ -422,7 +536,32 @@ func (m *Model) Save(data any, value ...any) (err error) {
 		}
 	}
 	setIDField(m.Data, id)
-	return
+	if result.UpsertedCount > 0 {
+		return m.afterCreate(m.Data)
+	}
+	return m.afterUpdate(m.Data)
+}
+
+// 保存前判断是否会插入数据 与保存使用相同的条件
+// 只在数据实现了创建钩子时查询 没有创建钩子时按更新处理
+func (m *Model) saveInserts() (bool, error) {
+	if !hasCreateHook(m.Data) {
+		return false, nil
+	}
+	filter := m.WhereList
+	if getVersionField(m.Data) != nil {
+		doc, err := ConvertToBSONM(m.Data)
+		if err != nil {
+			return false, err
+		}
+		filter = saveFilter(doc, m.WhereList)
+	}
+	count, err := m.Tx.Client.Database(m.Tx.Database).Collection(m.GetCollection(m.Data)).CountDocuments(m.GetContext(), filter, options.Count().SetLimit(1))
+	if err != nil {
+		log.Error(err)
+		return false, err
+	}
+	return count == 0, nil
 }
 
 func (m *Model) Upsert(data any, value ...any) error {
@@ -440,17 +579,34 @@ func (m *Model) Delete(data ...any) error {
 // 修改
 func (m *Model) Update(data any, value ...any) error {
 	m.CheckOID()
+	op := m.operation(types.OpUpdate)
+	op.Update = data
+	return m.invoke(op, func() error {
+		return m.update(op, data, value...)
+	})
+}
+
+func (m *Model) update(op *types.Operation, data any, value ...any) error {
+	model := m.Data
 	if data != nil {
 		m.Data = data
 	}
+	if err := m.beforeUpdate(m.Data); err != nil {
+		return err
+	}
 	bsonData, err := ConvertToBSONM(m.Data)
 	if err != nil {
 		return err
 	}
 	delete(bsonData, "_id")
-	log.Debugf("MongoDB更新bsonData: %+v\n", bsonData)
+	return m.updateSet(op, model, bsonData, value...)
+}
+
+// 使用 $set 更新 value 中的 bson.M 与 bson.D 合并到更新语句中
+func (m *Model) updateSet(op *types.Operation, model any, bsonData bson.M, value ...any) (err error) {
+	log.Debugf("MongoDB更新bsonData: %+v\n", log.Table(m.GetCollection(m.Data), bsonData))
 	opts := options.Update().SetUpsert(false)
-	log.Debugf("MongoDB更新Where条件: %v\n", m.WhereList)
+	log.Debugf("MongoDB更新Where条件: %v\n", log.Table(m.GetCollection(m.Data), m.WhereList))
 	update := make(bson.M)
 	if len(bsonData) != 0 {
 		update["$set"] = bsonData
@@ -472,18 +628,35 @@ func (m *Model) Update(data any, value ...any) error {
 			}
 		}
 	}
-	log.Debugf("MongoDB更新条件: %+v\n", update)
-
 	if len(update) == 0 {
 		log.Error("MongoDB更新条件为空")
 		return nil
 	}
+	if err := m.encryptUpdate(update); err != nil {
+		return err
+	}
+	fillUpdateTime(timeSource(m.Data, model), update, false)
+	filter := m.WhereList
+	vf := getVersionField(m.Data)
+	var current int64
+	if vf != nil {
+		filter, current = applyVersion(vf, m.Data, filter, update)
+	}
+	log.Debugf("MongoDB更新条件: %+v\n", log.Table(m.GetCollection(m.Data), update))
 
-	_, err = m.Tx.Client.Database(m.Tx.Database).Collection(m.GetCollection(m.Data)).UpdateMany(m.GetContext(), m.WhereList, update, opts)
+	op.Filter = filter
+	result, err := m.Tx.Client.Database(m.Tx.Database).Collection(m.GetCollection(m.Data)).UpdateMany(m.GetContext(), filter, update, opts)
 	if err != nil {
 		log.Error(err)
+		return err
 	}
-	return err
+	op.Rows = result.ModifiedCount
+	if vf != nil {
+		if err := checkVersion(vf, m.Data, current, result.MatchedCount); err != nil {
+			return err
+		}
+	}
+	return m.afterUpdate(m.Data)
 }
 
 // 查询数据
@@ -520,7 +693,14 @@ func (m *Model) BulkWrite(datas any, order bool) error {
 	m.CheckOID()
 
 	// 执行批量写入操作
-	bulkWriteOpts := options.BulkWrite().SetOrdered(order) // 设置为无序时 提高性能
-	_, err := m.Tx.Client.Database(m.Tx.Database).Collection(m.GetCollection(m.Data)).BulkWrite(context.TODO(), models, bulkWriteOpts)
-	return err
+	op := m.operation(types.OpBulk)
+	op.Update = models
+	return m.invoke(op, func() error {
+		bulkWriteOpts := options.BulkWrite().SetOrdered(order) // 设置为无序时 提高性能
+		result, err := m.Tx.Client.Database(m.Tx.Database).Collection(m.GetCollection(m.Data)).BulkWrite(m.GetContext(), models, bulkWriteOpts)
+		if result != nil {
+			op.Rows = result.InsertedCount + result.ModifiedCount + result.DeletedCount + result.UpsertedCount
+		}
+		return err
+	})
 }
@@ -0,0 +1,81 @@
+package mongodb
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+var (
+	monitorLock     sync.RWMutex
+	commandMonitors []*event.CommandMonitor
+	poolMonitors    []*event.PoolMonitor
+)
+
+// 注册命令监听 需在 Init 前调用
+// 可以注册多个 按注册顺序依次调用
+func AddCommandMonitor(monitors ...*event.CommandMonitor) {
+	monitorLock.Lock()
+	defer monitorLock.Unlock()
+	commandMonitors = append(commandMonitors, monitors...)
+}
+
+// 注册连接池监听 需在 Init 前调用
+func AddPoolMonitor(monitors ...*event.PoolMonitor) {
+	monitorLock.Lock()
+	defer monitorLock.Unlock()
+	poolMonitors = append(poolMonitors, monitors...)
+}
+
+// 合并内置与已注册的命令监听 没有监听时返回 nil
+func commandMonitor(builtin ...*event.CommandMonitor) *event.CommandMonitor {
+	monitorLock.RLock()
+	monitors := append(builtin, commandMonitors...)
+	monitorLock.RUnlock()
+	if len(monitors) == 0 {
+		return nil
+	}
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			for _, m := range monitors {
+				if m.Started != nil {
+					m.Started(ctx, evt)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			for _, m := range monitors {
+				if m.Succeeded != nil {
+					m.Succeeded(ctx, evt)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			for _, m := range monitors {
+				if m.Failed != nil {
+					m.Failed(ctx, evt)
+				}
+			}
+		},
+	}
+}
+
+// 合并内置与已注册的连接池监听 没有监听时返回 nil
+func poolMonitor(builtin ...*event.PoolMonitor) *event.PoolMonitor {
+	monitorLock.RLock()
+	monitors := append(builtin, poolMonitors...)
+	monitorLock.RUnlock()
+	if len(monitors) == 0 {
+		return nil
+	}
+	return &event.PoolMonitor{
+		Event: func(evt *event.PoolEvent) {
+			for _, m := range monitors {
+				if m.Event != nil {
+					m.Event(evt)
+				}
+			}
+		},
+	}
+}
@@ -0,0 +1,108 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// 对比模型与数据库的索引与校验规则 返回差异与同步需要执行的命令 不修改数据库
+// 模型没有声明的索引只报告 不会删除
+// 模型声明了校验规则或集合已有校验规则时对比校验规则
+func (m *DBConn) Plan(ctx context.Context, models ...any) (*types.SchemaPlan, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	plan := &types.SchemaPlan{}
+	opts := m.validatorOptions(types.ValidatorOptions{})
+	db := m.Client.Database(m.Database)
+	for _, data := range models {
+		name := GetTableName(data)
+		want, err := ModelIndexes(data)
+		if err != nil {
+			return nil, err
+		}
+		existing, err := ListIndexes(ctx, db.Collection(name))
+		if err != nil {
+			return nil, err
+		}
+		PlanIndexes(plan, name, DiffIndexes(want, existing))
+
+		current, err := GetValidator(ctx, db, name)
+		if err != nil {
+			return nil, err
+		}
+		if current.Validator == nil && !HasValidationRules(data) {
+			continue
+		}
+		validator, err := Validator(data)
+		if err != nil {
+			return nil, err
+		}
+		PlanValidator(plan, name, validator, current, opts)
+	}
+	return plan, nil
+}
+
+// 将索引差异写入计划 定义不一致的索引需要先删除再重建
+func PlanIndexes(plan *types.SchemaPlan, collection string, diff IndexDiff) {
+	for _, spec := range diff.Missing {
+		plan.Changes = append(plan.Changes, types.SchemaChange{
+			Table:  collection,
+			Kind:   types.ChangeCreateIndex,
+			Name:   spec.Name,
+			Detail: IndexName(spec.Keys),
+		})
+		plan.Statements = append(plan.Statements, createIndexCommand(collection, spec))
+	}
+	for _, spec := range diff.Changed {
+		plan.Changes = append(plan.Changes, types.SchemaChange{
+			Table:  collection,
+			Kind:   types.ChangeAlterIndex,
+			Name:   spec.Name,
+			Detail: IndexName(spec.Keys),
+		})
+		plan.Statements = append(plan.Statements,
+			fmt.Sprintf("db.%s.dropIndex(%q)", collection, spec.Name),
+			createIndexCommand(collection, spec),
+		)
+	}
+	for _, name := range diff.Extra {
+		plan.Changes = append(plan.Changes, types.SchemaChange{
+			Table: collection,
+			Kind:  types.ChangeExtraIndex,
+			Name:  name,
+		})
+	}
+}
+
+// mongo shell 格式的建索引命令
+func createIndexCommand(collection string, spec types.IndexSpec) string {
+	opts := bson.D{{Key: "name", Value: spec.Name}}
+	if spec.Unique {
+		opts = append(opts, bson.E{Key: "unique", Value: true})
+	}
+	if spec.Sparse {
+		opts = append(opts, bson.E{Key: "sparse", Value: true})
+	}
+	if spec.TTL > 0 {
+		opts = append(opts, bson.E{Key: "expireAfterSeconds", Value: int64(spec.TTL.Seconds())})
+	}
+	if spec.PartialFilter != nil {
+		opts = append(opts, bson.E{Key: "partialFilterExpression", Value: spec.PartialFilter})
+	}
+	if spec.Collation != nil {
+		opts = append(opts, bson.E{Key: "collation", Value: spec.Collation})
+	}
+	return fmt.Sprintf("db.%s.createIndex(%s, %s)", collection, extJSON(spec.Keys), extJSON(opts))
+}
+
+func extJSON(v any) string {
+	data, err := bson.MarshalExtJSON(v, false, false)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,493 @@
+package mongodb
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/lfhy/morm/log"
+	"github.com/lfhy/morm/schema"
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	gschema "gorm.io/gorm/schema"
+)
+
+// 关联类型 与 gorm 的取值一致
+const (
+	RelationHasOne    = "has_one"
+	RelationHasMany   = "has_many"
+	RelationBelongsTo = "belongs_to"
+	RelationMany2Many = "many_to_many"
+)
+
+// 模型间的关联 使用 gorm 的关联标签声明 推断规则与 gorm 一致
+// 关联字段通常标记 bson:"-" 不写入文档
+//
+//	type User struct {
+//		ID        primitive.ObjectID `bson:"_id"`
+//		CompanyID primitive.ObjectID
+//		Company   *Company   `bson:"-"`                          // belongs to 按 User.CompanyID
+//		Profile   *Profile   `bson:"-"`                          // has one 按 Profile.UserID
+//		Orders    []Order    `bson:"-" gorm:"foreignKey:OwnerID"` // has many 按 Order.OwnerID
+//		Languages []Language `bson:"-" gorm:"many2many:user_languages"`
+//	}
+//
+// many2many 的关联集合中每个文档记录一对关联 字段名默认为 user_id 与 language_id
+type Relation struct {
+	Type string
+	// 关联字段
+	Field *schema.Field
+	// 关联模型
+	Model reflect.Type
+	// 本模型中用于匹配的字段 has one has many 与 many2many 为主键 belongs to 为外键
+	OwnKey *schema.Field
+	// 关联模型中用于匹配的字段 has one has many 为外键 belongs to 与 many2many 为主键
+	RelKey *schema.Field
+	// many2many 的关联集合
+	JoinCollection string
+	// 关联集合中对应本模型与关联模型的字段名
+	JoinOwnKey string
+	JoinRelKey string
+}
+
+// 解析模型中字段声明的关联
+func ParseRelation(model any, field string) (*Relation, error) {
+	var typ reflect.Type
+	if t, ok := model.(reflect.Type); ok {
+		typ = t
+	} else {
+		typ = reflect.TypeOf(model)
+	}
+	owner := schema.Parse(elemType(typ))
+	if owner == nil {
+		return nil, fmt.Errorf("%w: relation %s in %v", types.ErrUnknownField, field, typ)
+	}
+	var f *schema.Field
+	for _, flat := range owner.Flat {
+		if flat.Name == field {
+			f = flat
+			break
+		}
+	}
+	if f == nil {
+		return nil, fmt.Errorf("%w: relation %s in %s", types.ErrUnknownField, field, owner.Type)
+	}
+	related := schema.Parse(elemType(f.Type))
+	if related == nil {
+		return nil, fmt.Errorf("%w: %s.%s is not a relation", types.ErrUnknownField, owner.Type, field)
+	}
+	rel := &Relation{Field: f, Model: related.Type}
+	opts := schema.ParseTag(f.Tag.Get("gorm"))
+	for k, v := range schema.ParseTag(f.Tag.Get("morm")) {
+		opts[k] = v
+	}
+	lookUp := func(s *schema.Schema, name string) *schema.Field {
+		if name == "" {
+			return primaryField(s)
+		}
+		return fieldByName(s, name)
+	}
+	missing := func(s *schema.Schema, name string) error {
+		if name == "" {
+			name = "primary key"
+		}
+		return fmt.Errorf("%w: %s for relation %s.%s", types.ErrUnknownField, name, owner.Type, field)
+	}
+
+	kind := f.Type.Kind()
+	if kind == reflect.Ptr {
+		kind = f.Type.Elem().Kind()
+	}
+	switch {
+	case opts["many2many"] != "":
+		rel.Type = RelationMany2Many
+		rel.JoinCollection = opts["many2many"]
+		if rel.OwnKey = lookUp(owner, opts["foreignkey"]); rel.OwnKey == nil {
+			return nil, missing(owner, opts["foreignkey"])
+		}
+		if rel.RelKey = lookUp(related, opts["references"]); rel.RelKey == nil {
+			return nil, missing(related, opts["references"])
+		}
+		rel.JoinOwnKey = joinKey(opts["joinforeignkey"], owner.Type.Name()+rel.OwnKey.Name)
+		rel.JoinRelKey = joinKey(opts["joinreferences"], related.Type.Name()+rel.RelKey.Name)
+	case kind == reflect.Slice || kind == reflect.Array:
+		rel.Type = RelationHasMany
+		if err := rel.has(owner, related, opts, lookUp, missing); err != nil {
+			return nil, err
+		}
+	default:
+		// 与 gorm 一致 先按 belongs to 推断 本模型中没有外键时按 has one 推断
+		fk := opts["foreignkey"]
+		if fk == "" {
+			if pk := lookUp(related, opts["references"]); pk != nil {
+				fk = f.Name + pk.Name
+			}
+		}
+		if own := fieldByName(owner, fk); own != nil {
+			rel.Type = RelationBelongsTo
+			rel.OwnKey = own
+			if rel.RelKey = lookUp(related, opts["references"]); rel.RelKey == nil {
+				return nil, missing(related, opts["references"])
+			}
+			break
+		}
+		rel.Type = RelationHasOne
+		if err := rel.has(owner, related, opts, lookUp, missing); err != nil {
+			return nil, err
+		}
+	}
+	return rel, nil
+}
+
+// has one 与 has many 外键在关联模型中 默认为本模型名加主键字段名
+func (r *Relation) has(owner, related *schema.Schema, opts map[string]string, lookUp func(*schema.Schema, string) *schema.Field, missing func(*schema.Schema, string) error) error {
+	if r.OwnKey = lookUp(owner, opts["references"]); r.OwnKey == nil {
+		return missing(owner, opts["references"])
+	}
+	fk := opts["foreignkey"]
+	if fk == "" {
+		// 与 gorm 一致 未导出的模型名首字母大写
+		name := owner.Type.Name()
+		if name != "" {
+			name = strings.ToUpper(name[:1]) + name[1:]
+		}
+		fk = name + r.OwnKey.Name
+	}
+	if r.RelKey = fieldByName(related, fk); r.RelKey == nil {
+		return missing(related, fk)
+	}
+	return nil
+}
+
+// 本模型中用于匹配的值 去重并跳过零值
+func (r *Relation) Keys(data any) []any {
+	var keys []any
+	seen := make(map[string]bool)
+	eachStruct(data, func(v reflect.Value) {
+		key := reflect.Indirect(v.FieldByIndex(r.OwnKey.Index))
+		if !key.IsValid() || key.IsZero() {
+			return
+		}
+		if k := keyOf(key.Interface()); !seen[k] {
+			seen[k] = true
+			keys = append(keys, key.Interface())
+		}
+	})
+	return keys
+}
+
+// 将查询到的关联数据填充到 data 的关联字段中
+// related 为关联模型的切片 many2many 时 joins 为关联集合中的文档
+func (r *Relation) Assign(data, related any, joins []bson.M) error {
+	byKey := make(map[string][]reflect.Value)
+	list := reflect.Indirect(reflect.ValueOf(related))
+	if list.Kind() != reflect.Slice {
+		return fmt.Errorf("morm: related data must be a slice, got %T", related)
+	}
+	for i := 0; i < list.Len(); i++ {
+		item := list.Index(i)
+		key := reflect.Indirect(reflect.Indirect(item).FieldByIndex(r.RelKey.Index))
+		if key.IsValid() {
+			k := keyOf(key.Interface())
+			byKey[k] = append(byKey[k], item)
+		}
+	}
+	// many2many 先通过关联集合找到关联模型的主键
+	links := make(map[string][]string)
+	for _, doc := range joins {
+		own, rel := keyOf(doc[r.JoinOwnKey]), keyOf(doc[r.JoinRelKey])
+		links[own] = append(links[own], rel)
+	}
+	eachStruct(data, func(v reflect.Value) {
+		key := reflect.Indirect(v.FieldByIndex(r.OwnKey.Index))
+		var matches []reflect.Value
+		if key.IsValid() && !key.IsZero() {
+			k := keyOf(key.Interface())
+			if r.Type == RelationMany2Many {
+				for _, rel := range links[k] {
+					matches = append(matches, byKey[rel]...)
+				}
+			} else {
+				matches = byKey[k]
+			}
+		}
+		setRelated(v.FieldByIndex(r.Field.Index), matches)
+	})
+	return nil
+}
+
+// 预加载的关联
+type preload struct {
+	field  string
+	scopes []types.PreloadScope
+}
+
+// 预加载关联数据 查询后按关联字段批量使用 $in 查询并填充
+func (m *Model) Preload(field string, scopes ...types.PreloadScope) types.ORMModel {
+	if m.Data != nil {
+		if _, ok := m.Data.(string); !ok {
+			typ := reflect.TypeOf(m.Data)
+			for _, name := range strings.Split(field, ".") {
+				rel, err := ParseRelation(typ, name)
+				if err != nil {
+					m.err = err
+					return m
+				}
+				typ = rel.Model
+			}
+		}
+	}
+	m.preloads = append(m.preloads, preload{field: field, scopes: scopes})
+	return m
+}
+
+// 同一个关联字段的预加载 嵌套的关联交给关联模型继续处理
+type preloadGroup struct {
+	scopes []types.PreloadScope
+	nested []preload
+}
+
+// 按预加载的顺序填充 data 中的关联字段
+func (m *Model) loadPreloads(data any) error {
+	if len(m.preloads) == 0 {
+		return nil
+	}
+	// Preload("Orders") 与 Preload("Orders.Items") 共用一次查询
+	var fields []string
+	groups := make(map[string]*preloadGroup)
+	for _, p := range m.preloads {
+		name, rest, nested := strings.Cut(p.field, ".")
+		g := groups[name]
+		if g == nil {
+			g = &preloadGroup{}
+			groups[name] = g
+			fields = append(fields, name)
+		}
+		if nested {
+			g.nested = append(g.nested, preload{field: rest, scopes: p.scopes})
+		} else {
+			g.scopes = append(g.scopes, p.scopes...)
+		}
+	}
+	owner := reflect.TypeOf(data)
+	for _, name := range fields {
+		rel, err := ParseRelation(owner, name)
+		if err != nil {
+			return err
+		}
+		if err := m.loadRelation(data, rel, groups[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Model) loadRelation(data any, rel *Relation, g *preloadGroup) error {
+	values := rel.Keys(data)
+	var joins []bson.M
+	if len(values) > 0 && rel.Type == RelationMany2Many {
+		coll := m.Tx.Client.Database(m.Tx.Database).Collection(rel.JoinCollection)
+		filter := bson.M{rel.JoinOwnKey: bson.M{"$in": inValues(rel.JoinOwnKey, values)}}
+		log.Debugf("预加载关联集合 %v Mongo查询条件: %+v", rel.JoinCollection, log.Table(rel.JoinCollection, filter))
+		cur, err := coll.Find(m.GetContext(), filter)
+		if err != nil {
+			log.Errorf("Mongo预加载出错: %v\n", err)
+			return err
+		}
+		if err := cur.All(m.GetContext(), &joins); err != nil {
+			return err
+		}
+		values = values[:0]
+		seen := make(map[string]bool)
+		for _, doc := range joins {
+			if k := keyOf(doc[rel.JoinRelKey]); !seen[k] {
+				seen[k] = true
+				values = append(values, doc[rel.JoinRelKey])
+			}
+		}
+	}
+	related := reflect.New(reflect.SliceOf(rel.Model))
+	if len(values) > 0 {
+		sub := &Model{Tx: m.Tx, Data: reflect.New(rel.Model).Interface(), WhereList: bson.M{}, Ctx: m.Ctx}
+		sub.Collection = sub.GetCollection(sub.Data)
+		for _, scope := range g.scopes {
+			scope(sub)
+		}
+		if sub.err != nil {
+			return sub.err
+		}
+		sub.preloads = append(sub.preloads, g.nested...)
+		key := bsonName(rel.RelKey)
+		in := bson.M{"$in": inValues(key, values)}
+		// 条件中已有同一字段时同时满足
+		if old, ok := sub.WhereList[key]; ok {
+			and, _ := sub.WhereList["$and"].(bson.A)
+			sub.WhereList["$and"] = append(and, bson.M{key: old}, bson.M{key: in})
+			delete(sub.WhereList, key)
+		} else {
+			sub.WhereList[key] = in
+		}
+		sub.CheckOID()
+		if err := (&Query{m: sub, Where: sub.WhereList}).all(related.Interface(), sub.makeAllQuery()); err != nil {
+			return err
+		}
+		if err := sub.loadPreloads(related.Interface()); err != nil {
+			return err
+		}
+	}
+	return rel.Assign(data, related.Interface(), joins)
+}
+
+// _id 的值都是字符串时保持为 []string 由 CheckOID 转换为 ObjectID
+// 其余字段中 ObjectID 可能以十六进制字符串保存 反之亦然 两种形式都参与匹配 与 keyOf 一致
+func inValues(key string, values []any) any {
+	if key == "_id" {
+		ids := make([]string, 0, len(values))
+		for _, v := range values {
+			s, ok := v.(string)
+			if !ok {
+				return values
+			}
+			ids = append(ids, s)
+		}
+		return ids
+	}
+	list := make([]any, 0, len(values))
+	for _, v := range values {
+		list = append(list, v)
+		switch id := v.(type) {
+		case primitive.ObjectID:
+			list = append(list, id.Hex())
+		case string:
+			if oid, err := primitive.ObjectIDFromHex(id); err == nil {
+				list = append(list, oid)
+			}
+		}
+	}
+	return list
+}
+
+// 关联集合中的字段名 标签中为结构体字段名时转换为列名
+func joinKey(name, def string) string {
+	if name == "" {
+		name = def
+	}
+	return gschema.NamingStrategy{}.ColumnName("", name)
+}
+
+// 填充单个关联字段 切片为全部匹配项 结构体与指针为第一个匹配项
+func setRelated(field reflect.Value, matches []reflect.Value) {
+	convert := func(item reflect.Value, typ reflect.Type) reflect.Value {
+		if typ.Kind() == reflect.Ptr && item.Kind() != reflect.Ptr {
+			ptr := reflect.New(item.Type())
+			ptr.Elem().Set(item)
+			return ptr
+		}
+		if typ.Kind() != reflect.Ptr && item.Kind() == reflect.Ptr {
+			return item.Elem()
+		}
+		return item
+	}
+	typ := field.Type()
+	if typ.Kind() == reflect.Ptr && typ.Elem().Kind() == reflect.Slice {
+		if field.IsNil() {
+			field.Set(reflect.New(typ.Elem()))
+		}
+		field, typ = field.Elem(), typ.Elem()
+	}
+	switch typ.Kind() {
+	case reflect.Slice:
+		list := reflect.MakeSlice(typ, 0, len(matches))
+		for _, item := range matches {
+			list = reflect.Append(list, convert(item, typ.Elem()))
+		}
+		field.Set(list)
+	default:
+		if len(matches) == 0 {
+			field.Set(reflect.Zero(typ))
+			return
+		}
+		field.Set(convert(matches[0], typ))
+	}
+}
+
+// 依次处理 data 中的结构体 data 可以是结构体指针或结构体切片的指针
+func eachStruct(data any, fn func(v reflect.Value)) {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		fn(v)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			item := v.Index(i)
+			for item.Kind() == reflect.Ptr || item.Kind() == reflect.Interface {
+				if item.IsNil() {
+					break
+				}
+				item = item.Elem()
+			}
+			if item.Kind() == reflect.Struct {
+				fn(item)
+			}
+		}
+	}
+}
+
+// 用于匹配的键 ObjectID 与其十六进制字符串视为相同
+func keyOf(v any) string {
+	switch id := v.(type) {
+	case primitive.ObjectID:
+		return id.Hex()
+	case *primitive.ObjectID:
+		if id != nil {
+			return id.Hex()
+		}
+	}
+	return fmt.Sprint(v)
+}
+
+// 去掉指针 切片与数组后的类型
+func elemType(typ reflect.Type) reflect.Type {
+	for typ != nil && (typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) {
+		typ = typ.Elem()
+	}
+	return typ
+}
+
+// 模型的主键 优先使用 _id 字段
+func primaryField(s *schema.Schema) *schema.Field {
+	if s.IDField != nil {
+		return s.IDField
+	}
+	if len(s.PrimaryFields) > 0 {
+		return s.PrimaryFields[0]
+	}
+	return fieldByName(s, "ID")
+}
+
+func fieldByName(s *schema.Schema, name string) *schema.Field {
+	if name == "" {
+		return nil
+	}
+	for _, f := range s.Flat {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// 字段在文档中的名字 没有标签时与 mongo-driver 一致使用小写字段名
+func bsonName(f *schema.Field) string {
+	if f.BSON != "" {
+		return f.BSON
+	}
+	return strings.ToLower(f.Name)
+}
@@ -17,24 +17,60 @@ type Query struct {
 
 func (q *Query) One(data any) error {
 	opts := q.m.makeOneQuery()
-	log.Debugf("查询集合 %v ,Mongo查询条件: %+v %+v", q.m.GetCollection(q.m.Data), q.m.WhereList, opts)
-	result := q.m.Tx.Client.Database(q.m.Tx.Database).Collection(q.m.GetCollection(q.m.Data)).FindOne(q.m.GetContext(), q.m.WhereList, &opts)
-	err := result.Decode(data)
-	if err != nil {
-		log.Errorf("查询集合 %v ,Mongo查询条件: %+v 错误: %v\n", q.m.GetCollection(q.m.Data), q.m.WhereList, err)
+	op := q.m.operation(types.OpFindOne)
+	op.Options = &opts
+	return q.m.invoke(op, func() error {
+		if err := q.one(data, opts); err != nil {
+			return err
+		}
+		op.Rows = 1
+		return q.m.loadPreloads(data)
+	})
+}
+
+func (q *Query) one(data any, opts options.FindOneOptions) error {
+	log.Debugf("查询集合 %v ,Mongo查询条件: %+v %+v", q.m.GetCollection(q.m.Data), log.Table(q.m.GetCollection(q.m.Data), q.m.WhereList), opts)
+	var err error
+	if len(q.m.joins) > 0 {
+		err = q.m.aggregateOne(data)
 	} else {
-		log.Debugf("Mongo查询结果: %+v\n", data)
+		err = q.m.Tx.Client.Database(q.m.Tx.Database).Collection(q.m.GetCollection(q.m.Data)).FindOne(q.m.GetContext(), q.m.WhereList, &opts).Decode(data)
 	}
-
-	return err
+	if err != nil {
+		log.Errorf("查询集合 %v ,Mongo查询条件: %+v 错误: %v\n", q.m.GetCollection(q.m.Data), log.Table(q.m.GetCollection(q.m.Data), q.m.WhereList), err)
+		return err
+	}
+	log.Debugf("Mongo查询结果: %+v\n", data)
+	if err := q.m.decrypt(data); err != nil {
+		return err
+	}
+	return q.m.afterFind(data)
 }
 
 // 查询全部
 func (q *Query) All(data any) error {
 	opts := q.m.makeAllQuery()
-	log.Debugf("查询集合 %v Mongo查询条件: %v %v", q.m.GetCollection(q.m.Data), q.m.WhereList, opts)
+	op := q.m.operation(types.OpFindAll)
+	op.Options = opts
+	return q.m.invoke(op, func() error {
+		if err := q.all(data, opts); err != nil {
+			return err
+		}
+		op.Rows = sliceLen(data)
+		return q.m.loadPreloads(data)
+	})
+}
+
+func (q *Query) all(data any, opts *options.FindOptions) error {
+	log.Debugf("查询集合 %v Mongo查询条件: %v %v", q.m.GetCollection(q.m.Data), log.Table(q.m.GetCollection(q.m.Data), q.m.WhereList), opts)
 	log.Debugf("Mongo查询限制: %+v\n", opts)
-	result, err := q.m.Tx.Client.Database(q.m.Tx.Database).Collection(q.m.GetCollection(q.m.Data)).Find(q.m.GetContext(), q.m.WhereList, opts)
+	var result *mongo.Cursor
+	var err error
+	if len(q.m.joins) > 0 {
+		result, err = q.m.aggregate(q.m.JoinPipeline())
+	} else {
+		result, err = q.m.Tx.Client.Database(q.m.Tx.Database).Collection(q.m.GetCollection(q.m.Data)).Find(q.m.GetContext(), q.m.WhereList, opts)
+	}
 
 	// log.Debugf("Mongo查询结果: %+v\n", result)
 	if err != nil {
@@ -44,16 +80,31 @@ func (q *Query) All(data any) error {
 	err = result.All(context.Background(), data)
 	if err != nil {
 		log.Errorf("mongdob查询数据ALL Decode失败: %v\n", err)
+		return err
 	}
-	return err
+	if err := q.m.decrypt(data); err != nil {
+		return err
+	}
+	return q.m.afterFind(data)
 }
 
 func (q *Query) Count() int64 {
-	log.Debugf("查询集合 %v ,Mongo查询条件: %+v", q.m.GetCollection(q.m.Data), q.m.WhereList)
-	i, err := q.m.Tx.Client.Database(q.m.Tx.Database).Collection(q.m.GetCollection(q.m.Data)).CountDocuments(q.m.GetContext(), q.m.WhereList)
-	if err != nil {
-		log.Errorf("Mongo查出错: %v\n", err)
-	}
+	var i int64
+	op := q.m.operation(types.OpCount)
+	q.m.invoke(op, func() error {
+		log.Debugf("查询集合 %v ,Mongo查询条件: %+v", q.m.GetCollection(q.m.Data), log.Table(q.m.GetCollection(q.m.Data), q.m.WhereList))
+		var err error
+		if len(q.m.joins) > 0 {
+			i, err = q.m.aggregateCount()
+		} else {
+			i, err = q.m.Tx.Client.Database(q.m.Tx.Database).Collection(q.m.GetCollection(q.m.Data)).CountDocuments(q.m.GetContext(), q.m.WhereList)
+		}
+		if err != nil {
+			log.Errorf("Mongo查出错: %v\n", err)
+		}
+		op.Rows = i
+		return err
+	})
 	return i
 }
 
@@ -63,8 +114,18 @@ type IDModel struct {
 
 // 删除查询结果
 func (q *Query) Delete() error {
+	op := q.m.operation(types.OpDelete)
+	return q.m.invoke(op, func() error {
+		return q.delete(op)
+	})
+}
+
+func (q *Query) delete(op *types.Operation) error {
+	if err := q.m.beforeDelete(q.m.Data); err != nil {
+		return err
+	}
 	var deleteIDs []*IDModel
-	err := q.All(&deleteIDs)
+	err := q.all(&deleteIDs, q.m.makeAllQuery())
 	if err != nil {
 		return err
 	}
@@ -73,7 +134,10 @@ func (q *Query) Delete() error {
 		return nil
 	}
 	if len(deleteIDs) == 1 {
-		_, err := q.m.Tx.Client.Database(q.m.Tx.Database).Collection(q.m.GetCollection(q.m.Data)).DeleteOne(q.m.GetContext(), deleteIDs[0])
+		result, err := q.m.Tx.Client.Database(q.m.Tx.Database).Collection(q.m.GetCollection(q.m.Data)).DeleteOne(q.m.GetContext(), deleteIDs[0])
+		if result != nil {
+			op.Rows = result.DeletedCount
+		}
 		return err
 	}
 	// 批量删除
@@ -83,28 +147,45 @@ func (q *Query) Delete() error {
 	}
 	// 执行批量写入操作
 	bulkWriteOpts := options.BulkWrite().SetOrdered(false) // 设置为无序以提高性能
-	_, err = q.m.Tx.Client.Database(q.m.Tx.Database).Collection(q.m.GetCollection(q.m.Data)).BulkWrite(q.m.GetContext(), models, bulkWriteOpts)
+	result, err := q.m.Tx.Client.Database(q.m.Tx.Database).Collection(q.m.GetCollection(q.m.Data)).BulkWrite(q.m.GetContext(), models, bulkWriteOpts)
+	if result != nil {
+		op.Rows = result.DeletedCount
+	}
 	return err
 }
 
 // 游标
 func (q *Query) Cursor() (types.Cursor, error) {
 	opts := q.m.makeAllQuery()
-	log.Debugf("查询集合 %v Mongo查询条件: %v %v", q.m.GetCollection(q.m.Data), q.m.WhereList, opts)
-	log.Debugf("Mongo查询限制: %+v\n", opts)
-	result, err := q.m.Tx.Client.Database(q.m.Tx.Database).Collection(q.m.GetCollection(q.m.Data)).Find(q.m.GetContext(), q.m.WhereList, opts)
+	op := q.m.operation(types.OpCursor)
+	op.Options = opts
+	var result *mongo.Cursor
+	err := q.m.invoke(op, func() (err error) {
+		log.Debugf("查询集合 %v Mongo查询条件: %v %v", q.m.GetCollection(q.m.Data), log.Table(q.m.GetCollection(q.m.Data), q.m.WhereList), opts)
+		log.Debugf("Mongo查询限制: %+v\n", opts)
+		if len(q.m.joins) > 0 {
+			result, err = q.m.aggregate(q.m.JoinPipeline())
+			return err
+		}
+		result, err = q.m.Tx.Client.Database(q.m.Tx.Database).Collection(q.m.GetCollection(q.m.Data)).Find(q.m.GetContext(), q.m.WhereList, opts)
+		if err != nil {
+			log.Errorf("Mongo查出错: %v\n", err)
+		}
+		return err
+	})
 	if err != nil {
-		log.Errorf("Mongo查出错: %v\n", err)
 		return nil, err
 	}
 	return &Cursor{
 		ctx:    q.m.GetContext(),
+		m:      q.m,
 		Cursor: result,
 	}, err
 }
 
 type Cursor struct {
 	ctx context.Context
+	m   *Model
 	*mongo.Cursor
 }
 
@@ -124,6 +205,13 @@ func (c *Cursor) Decode(v any) error {
 	err := c.Cursor.Decode(v)
 	if err != nil {
 		log.Errorf("Mongo游标解码出错: %v\n", err)
+		return err
 	}
-	return err
+	if err := c.m.decrypt(v); err != nil {
+		return err
+	}
+	if err := c.m.afterFind(v); err != nil {
+		return err
+	}
+	return c.m.loadPreloads(v)
 }
@@ -0,0 +1,192 @@
+package mongodb
+
+import (
+	"fmt"
+
+	"github.com/lfhy/morm/log"
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// 原始命令或聚合管道
+type RawQuery struct {
+	m        *Model
+	command  bson.D
+	pipeline any
+	err      error
+}
+
+// 返回游标的命令 其余命令的结果为单个文档
+var cursorCommands = map[string]bool{
+	"find":            true,
+	"aggregate":       true,
+	"listCollections": true,
+	"listIndexes":     true,
+}
+
+// 原始数据库命令 query 为 Extended JSON 格式 不支持参数
+func (m *Model) Raw(query string, args ...any) types.RawQuery {
+	q := &RawQuery{m: m}
+	if len(args) > 0 {
+		q.err = fmt.Errorf("%w: args in mongodb command", types.ErrNotSupported)
+		return q
+	}
+	if err := bson.UnmarshalExtJSON([]byte(query), false, &q.command); err != nil {
+		q.err = fmt.Errorf("morm: parse mongodb command: %w", err)
+	}
+	return q
+}
+
+// 在当前集合上执行聚合管道
+func (m *Model) Pipeline(stages any) types.RawQuery {
+	return &RawQuery{m: m, pipeline: stages}
+}
+
+// 原始命令的操作描述 条件为命令或聚合管道
+func (q *RawQuery) operation(opType types.OpType) *types.Operation {
+	op := q.m.operation(opType)
+	if q.pipeline != nil {
+		op.Filter = q.pipeline
+	} else {
+		op.Filter = q.command
+	}
+	return op
+}
+
+// 原始查询不使用当前条件 也不返回构造条件时的错误
+func (q *RawQuery) invoke(op *types.Operation, fn func() error) error {
+	prev := q.m.err
+	q.m.err = nil
+	defer func() { q.m.err = prev }()
+	return q.m.invoke(op, func() error {
+		if q.err != nil {
+			return q.err
+		}
+		return fn()
+	})
+}
+
+// 结果是否为游标
+func (q *RawQuery) isCursor() bool {
+	return q.pipeline != nil || (len(q.command) > 0 && cursorCommands[q.command[0].Key])
+}
+
+func (q *RawQuery) cursor() (*mongo.Cursor, error) {
+	if q.pipeline != nil {
+		return q.m.aggregate(q.pipeline)
+	}
+	log.Debugf("执行Mongo命令: %+v", q.command)
+	cur, err := q.m.Tx.Client.Database(q.m.Tx.Database).RunCommandCursor(q.m.GetContext(), q.command)
+	if err != nil {
+		log.Errorf("Mongo命令出错: %v\n", err)
+	}
+	return cur, err
+}
+
+// 查询一条数据 游标没有数据时返回 mongo.ErrNoDocuments
+// 不返回游标的命令解码命令的结果
+func (q *RawQuery) One(data any) error {
+	op := q.operation(types.OpFindOne)
+	return q.invoke(op, func() error {
+		if q.isCursor() {
+			cur, err := q.cursor()
+			if err != nil {
+				return err
+			}
+			defer cur.Close(q.m.GetContext())
+			if !cur.Next(q.m.GetContext()) {
+				if err := cur.Err(); err != nil {
+					return err
+				}
+				return mongo.ErrNoDocuments
+			}
+			if err := cur.Decode(data); err != nil {
+				return err
+			}
+		} else {
+			log.Debugf("执行Mongo命令: %+v", q.command)
+			if err := q.m.Tx.Client.Database(q.m.Tx.Database).RunCommand(q.m.GetContext(), q.command).Decode(data); err != nil {
+				log.Errorf("Mongo命令出错: %v\n", err)
+				return err
+			}
+		}
+		op.Rows = 1
+		if err := q.m.decrypt(data); err != nil {
+			return err
+		}
+		return q.m.afterFind(data)
+	})
+}
+
+func (q *RawQuery) All(data any) error {
+	op := q.operation(types.OpFindAll)
+	return q.invoke(op, func() error {
+		cur, err := q.cursor()
+		if err != nil {
+			return err
+		}
+		if err := cur.All(q.m.GetContext(), data); err != nil {
+			log.Errorf("mongdob查询数据ALL Decode失败: %v\n", err)
+			return err
+		}
+		op.Rows = sliceLen(data)
+		if err := q.m.decrypt(data); err != nil {
+			return err
+		}
+		return q.m.afterFind(data)
+	})
+}
+
+func (q *RawQuery) Cursor() (types.Cursor, error) {
+	var result *mongo.Cursor
+	op := q.operation(types.OpCursor)
+	err := q.invoke(op, func() (err error) {
+		result, err = q.cursor()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Cursor{ctx: q.m.GetContext(), m: q.m, Cursor: result}, nil
+}
+
+// 执行写入 返回命令结果中的 n
+// 聚合管道用于 $out 与 $merge 返回 0
+func (q *RawQuery) Exec() (int64, error) {
+	op := q.operation(types.OpExec)
+	err := q.invoke(op, func() error {
+		if q.pipeline != nil {
+			cur, err := q.cursor()
+			if err != nil {
+				return err
+			}
+			return cur.Close(q.m.GetContext())
+		}
+		log.Debugf("执行Mongo命令: %+v", q.command)
+		var reply bson.M
+		if err := q.m.Tx.Client.Database(q.m.Tx.Database).RunCommand(q.m.GetContext(), q.command).Decode(&reply); err != nil {
+			log.Errorf("Mongo命令出错: %v\n", err)
+			return err
+		}
+		if errs, ok := reply["writeErrors"]; ok {
+			return fmt.Errorf("morm: mongodb write errors: %v", errs)
+		}
+		op.Rows = toInt64(reply["n"])
+		return nil
+	})
+	return op.Rows, err
+}
+
+// 命令结果中的数字可能是 int32 int64 或 float64
+func toInt64(v any) int64 {
+	switch n := v.(type) {
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
@@ -0,0 +1,38 @@
+package mongodb
+
+import (
+	"reflect"
+
+	"github.com/lfhy/morm/schema"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+)
+
+// 识别 morm 标签的结构体标签解析
+// 声明了 morm 字段名或选项时使用 morm 标签 否则使用 bson 标签
+var StructTagParser bsoncodec.StructTagParserFunc = func(sf reflect.StructField) (bsoncodec.StructTags, error) {
+	field := schema.ParseField(sf)
+	if !field.Declared {
+		return bsoncodec.DefaultStructTagParser(sf)
+	}
+	tags, err := bsoncodec.DefaultStructTagParser(sf)
+	if err != nil {
+		return tags, err
+	}
+	tags.Name = field.BSON
+	tags.OmitEmpty = field.OmitEmpty
+	tags.Skip = false
+	return tags, nil
+}
+
+// 使用 StructTagParser 的 bson 编解码注册表 Init 中会自动设置到客户端
+func NewRegistry() *bsoncodec.Registry {
+	reg := bson.NewRegistry()
+	codec, err := bsoncodec.NewStructCodec(StructTagParser)
+	if err != nil {
+		return reg
+	}
+	reg.RegisterKindEncoder(reflect.Struct, codec)
+	reg.RegisterKindDecoder(reflect.Struct, codec)
+	return reg
+}
@@ -0,0 +1,98 @@
+package mongodb
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// 脱敏后原样保留的命令参数
+var sanitizeKeep = map[string]bool{
+	"$db":         true,
+	"limit":       true,
+	"skip":        true,
+	"sort":        true,
+	"batchSize":   true,
+	"ordered":     true,
+	"projection":  true,
+	"singleBatch": true,
+}
+
+// 与语句无关的命令参数 脱敏时丢弃
+var sanitizeDrop = map[string]bool{
+	"lsid":             true,
+	"$clusterTime":     true,
+	"txnNumber":        true,
+	"autocommit":       true,
+	"startTransaction": true,
+	"readConcern":      true,
+	"writeConcern":     true,
+	"$readPreference":  true,
+	"apiVersion":       true,
+	"apiStrict":        true,
+	"maxTimeMS":        true,
+}
+
+// 命令脱敏
+// 保留命令名与集合名以及 limit sort 等参数 其余字段的值替换为 ?
+// 返回 Extended JSON 字符串 用于日志与链路追踪
+func SanitizeCommand(cmd bson.Raw) string {
+	elems, err := cmd.Elements()
+	if err != nil {
+		return ""
+	}
+	doc := make(bson.D, 0, len(elems))
+	for i, elem := range elems {
+		key := elem.Key()
+		switch {
+		case i == 0 || sanitizeKeep[key]:
+			doc = append(doc, bson.E{Key: key, Value: elem.Value()})
+		case sanitizeDrop[key]:
+		default:
+			doc = append(doc, bson.E{Key: key, Value: sanitizeValue(elem.Value())})
+		}
+	}
+	b, err := bson.MarshalExtJSON(doc, false, false)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// 脱敏过滤条件 保留字段名与操作符 值替换为 ?
+func SanitizeFilter(filter any) string {
+	raw, err := bson.Marshal(filter)
+	if err != nil {
+		return ""
+	}
+	b, err := bson.MarshalExtJSON(sanitizeValue(bson.RawValue{Type: bson.TypeEmbeddedDocument, Value: raw}), false, false)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func sanitizeValue(v bson.RawValue) any {
+	switch v.Type {
+	case bson.TypeEmbeddedDocument:
+		elems, err := v.Document().Elements()
+		if err != nil {
+			return "?"
+		}
+		doc := make(bson.D, 0, len(elems))
+		for _, elem := range elems {
+			doc = append(doc, bson.E{Key: elem.Key(), Value: sanitizeValue(elem.Value())})
+		}
+		return doc
+	case bson.TypeArray:
+		values, err := v.Array().Values()
+		if err != nil {
+			return "?"
+		}
+		arr := make(bson.A, 0, len(values))
+		for _, value := range values {
+			arr = append(arr, sanitizeValue(value))
+		}
+		return arr
+	default:
+		return "?"
+	}
+}
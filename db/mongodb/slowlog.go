@@ -0,0 +1,121 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lfhy/morm/log"
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// 各命令中过滤条件所在的字段
+var filterKeys = map[string]string{
+	"find":          "filter",
+	"count":         "query",
+	"distinct":      "query",
+	"findAndModify": "query",
+	"aggregate":     "pipeline",
+	"update":        "updates",
+	"delete":        "deletes",
+}
+
+// 慢命令日志需要的信息 在命令开始时从命令中复制
+type slowCommand struct {
+	ctx        context.Context
+	name       string
+	collection string
+	filter     bson.RawValue
+	sort       bson.RawValue
+	skip       bson.RawValue
+	limit      bson.RawValue
+	raw        bson.Raw
+}
+
+// 慢查询日志 记录超过阈值的命令
+// Init 中会自动添加 阈值读取 [mongodb] 的 slow_threshold
+func NewSlowMonitor(threshold time.Duration) *event.CommandMonitor {
+	var commands sync.Map
+
+	finish := func(requestID int64, elapsed time.Duration) {
+		value, ok := commands.LoadAndDelete(requestID)
+		if !ok || elapsed < threshold {
+			return
+		}
+		cmd := value.(*slowCommand)
+		log.Slow(cmd.ctx, types.MongoDB, cmd.collection, cmd.statement(), elapsed, threshold, -1)
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			cmd := &slowCommand{ctx: ctx, name: evt.CommandName}
+			cmd.collection, _ = evt.Command.Lookup(evt.CommandName).StringValueOK()
+			if key, ok := filterKeys[evt.CommandName]; ok {
+				// 驱动会复用命令的内存 需要复制
+				cmd.filter = copyRawValue(evt.Command.Lookup(key))
+				cmd.sort = copyRawValue(evt.Command.Lookup("sort"))
+				cmd.skip = copyRawValue(evt.Command.Lookup("skip"))
+				cmd.limit = copyRawValue(evt.Command.Lookup("limit"))
+			} else if evt.CommandName != "insert" {
+				cmd.raw = append(bson.Raw(nil), evt.Command...)
+			}
+			commands.Store(evt.RequestID, cmd)
+		},
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			finish(evt.RequestID, evt.Duration)
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			finish(evt.RequestID, evt.Duration)
+		},
+	}
+}
+
+// 生成 mongo shell 形式的语句 过滤条件中的值已脱敏
+// 如 users.find({"name": "?"}).sort({"age": -1}).limit(10)
+func (c *slowCommand) statement() string {
+	var b strings.Builder
+	if c.collection != "" {
+		b.WriteString(c.collection)
+		b.WriteString(".")
+	}
+	b.WriteString(c.name)
+	b.WriteString("(")
+	switch {
+	case c.raw != nil:
+		b.WriteString(SanitizeCommand(c.raw))
+	case c.filter.Type != 0:
+		b.WriteString(toJSON(sanitizeValue(c.filter)))
+	}
+	b.WriteString(")")
+	if c.sort.Type != 0 {
+		fmt.Fprintf(&b, ".sort(%s)", toJSON(c.sort))
+	}
+	if c.skip.Type != 0 {
+		fmt.Fprintf(&b, ".skip(%s)", toJSON(c.skip))
+	}
+	if c.limit.Type != 0 {
+		fmt.Fprintf(&b, ".limit(%s)", toJSON(c.limit))
+	}
+	return b.String()
+}
+
+func copyRawValue(v bson.RawValue) bson.RawValue {
+	v.Value = append([]byte(nil), v.Value...)
+	return v
+}
+
+// 任意值转为 Extended JSON
+func toJSON(v any) string {
+	b, err := bson.MarshalExtJSON(bson.D{{Key: "v", Value: v}}, false, false)
+	if err != nil {
+		return ""
+	}
+	// 去掉外层的 {"v": ...}
+	s := strings.TrimSpace(string(b))
+	s = strings.TrimPrefix(s, `{"v":`)
+	return strings.TrimSuffix(s, "}")
+}
@@ -0,0 +1,265 @@
+package mongodb
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lfhy/morm/schema"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// 自动时间戳字段
+// 与 gorm 保持一致：字段名为 CreatedAt / UpdatedAt，
+// 或 gorm / morm 标签中带有 autoCreateTime / autoUpdateTime 的字段
+type timeField struct {
+	name  string // bson 字段名
+	index []int  // 字段在结构体中的索引路径（含匿名嵌套）
+	typ   reflect.Type
+	unit  string // 整数时间戳精度 milli nano 为空时为秒
+}
+
+type timeFields struct {
+	create []timeField
+	update []timeField
+}
+
+var (
+	timeFieldCache sync.Map // reflect.Type -> *timeFields
+	timeType       = reflect.TypeOf(time.Time{})
+)
+
+// 获取结构体的时间戳字段
+func getTimeFields(data any) *timeFields {
+	typ := reflect.TypeOf(data)
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil
+	}
+	if v, ok := timeFieldCache.Load(typ); ok {
+		return v.(*timeFields)
+	}
+	fields := &timeFields{}
+	parseTimeFields(typ, fields)
+	timeFieldCache.Store(typ, fields)
+	return fields
+}
+
+func parseTimeFields(typ reflect.Type, fields *timeFields) {
+	for _, f := range schema.Parse(typ).Flat {
+		if !isExported(f.Name) || !isTimeFieldType(f.Type) {
+			continue
+		}
+		tags := f.Tag.Get("gorm") + ";" + f.Tag.Get("morm")
+		if f.Tag.Get("bson") == "-" {
+			continue
+		}
+		name := f.BSON
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		tf := timeField{name: name, index: f.Index, typ: f.Type}
+		switch {
+		case f.Name == "CreatedAt" || strings.Contains(tags, "autoCreateTime"):
+			tf.unit = timeUnit(tags, "autoCreateTime")
+			fields.create = append(fields.create, tf)
+		case f.Name == "UpdatedAt" || strings.Contains(tags, "autoUpdateTime"):
+			tf.unit = timeUnit(tags, "autoUpdateTime")
+			fields.update = append(fields.update, tf)
+		}
+	}
+}
+
+func isExported(name string) bool {
+	return name != "" && name[0] >= 'A' && name[0] <= 'Z'
+}
+
+// 支持 time.Time *time.Time 以及整数时间戳
+func isTimeFieldType(typ reflect.Type) bool {
+	if typ == timeType || (typ.Kind() == reflect.Ptr && typ.Elem() == timeType) {
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// 解析 autoCreateTime:milli 这种精度写法
+func timeUnit(tags, key string) string {
+	for _, part := range strings.Split(tags, ";") {
+		if strings.HasPrefix(part, key+":") {
+			return strings.ToLower(strings.TrimPrefix(part, key+":"))
+		}
+	}
+	return ""
+}
+
+// 按字段类型生成写入值
+func (tf timeField) value(now time.Time) any {
+	if tf.typ == timeType || tf.typ.Kind() == reflect.Ptr {
+		return now
+	}
+	switch tf.unit {
+	case "milli":
+		return now.UnixMilli()
+	case "nano":
+		return now.UnixNano()
+	}
+	return now.Unix()
+}
+
+// 是否可以使用 $currentDate
+func (tf timeField) isTime() bool {
+	return tf.typ == timeType || tf.typ.Kind() == reflect.Ptr
+}
+
+// 回写到结构体 使调用方能拿到写入的时间
+func (tf timeField) set(data any, now time.Time, onlyZero bool) {
+	val := reflect.ValueOf(data)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return
+	}
+	val = val.Elem()
+	if val.Kind() != reflect.Struct {
+		return
+	}
+	field := val.FieldByIndex(tf.index)
+	if !field.CanSet() || (onlyZero && !field.IsZero()) {
+		return
+	}
+	switch {
+	case tf.typ == timeType:
+		field.Set(reflect.ValueOf(now))
+	case tf.typ.Kind() == reflect.Ptr:
+		field.Set(reflect.ValueOf(&now))
+	case field.CanInt():
+		field.SetInt(reflect.ValueOf(tf.value(now)).Int())
+	case field.CanUint():
+		field.SetUint(uint64(reflect.ValueOf(tf.value(now)).Int()))
+	}
+}
+
+// 获取当前时间 Mongo 只保存到毫秒
+func nowTime() time.Time {
+	return time.Now().Truncate(time.Millisecond)
+}
+
+// 插入时补全 CreatedAt UpdatedAt
+func fillCreateTime(data any, doc bson.M) {
+	fields := getTimeFields(data)
+	if fields == nil {
+		return
+	}
+	now := nowTime()
+	for _, list := range [][]timeField{fields.create, fields.update} {
+		for _, tf := range list {
+			if _, ok := doc[tf.name]; ok {
+				continue
+			}
+			doc[tf.name] = tf.value(now)
+			tf.set(data, now, true)
+		}
+	}
+}
+
+// 更新时补全 UpdatedAt
+// upsert 为 true 时 CreatedAt 通过 $setOnInsert 写入
+func fillUpdateTime(data any, update bson.M, upsert bool) {
+	fields := getTimeFields(data)
+	if fields == nil {
+		return
+	}
+	now := nowTime()
+	set := updateDoc(update, "$set")
+	for _, tf := range fields.update {
+		if _, ok := set[tf.name]; ok {
+			continue
+		}
+		set[tf.name] = tf.value(now)
+		tf.set(data, now, false)
+	}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if !upsert {
+		return
+	}
+	setOnInsert := updateDoc(update, "$setOnInsert")
+	for _, tf := range fields.create {
+		// 同一路径不能同时出现在 $set 和 $setOnInsert 中
+		if _, ok := set[tf.name]; ok {
+			continue
+		}
+		if _, ok := setOnInsert[tf.name]; ok {
+			continue
+		}
+		setOnInsert[tf.name] = tf.value(now)
+	}
+	if len(setOnInsert) > 0 {
+		update["$setOnInsert"] = setOnInsert
+	}
+}
+
+// 按列更新时补全 UpdatedAt
+// 时间类型使用 $currentDate 由服务端写入 整数时间戳使用 $set
+func fillCurrentDate(data any, update bson.M) {
+	fields := getTimeFields(data)
+	if fields == nil {
+		return
+	}
+	now := nowTime()
+	set := updateDoc(update, "$set")
+	currentDate := updateDoc(update, "$currentDate")
+	for _, tf := range fields.update {
+		if _, ok := set[tf.name]; ok {
+			continue
+		}
+		if _, ok := currentDate[tf.name]; ok {
+			continue
+		}
+		if tf.isTime() {
+			currentDate[tf.name] = true
+		} else {
+			set[tf.name] = tf.value(now)
+		}
+	}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(currentDate) > 0 {
+		update["$currentDate"] = currentDate
+	}
+}
+
+// 取出更新文档中的操作符子文档 统一复制为 bson.M 避免修改调用方传入的数据
+func updateDoc(update bson.M, op string) bson.M {
+	doc := bson.M{}
+	switch v := update[op].(type) {
+	case bson.M:
+		for k, val := range v {
+			doc[k] = val
+		}
+	case map[string]any:
+		for k, val := range v {
+			doc[k] = val
+		}
+	case bson.D:
+		for _, e := range v {
+			doc[e.Key] = e.Value
+		}
+	}
+	return doc
+}
+
+// 优先使用写入数据的结构体 写入数据不是结构体时使用模型本身
+func timeSource(data, model any) any {
+	if getTimeFields(data) != nil {
+		return data
+	}
+	return model
+}
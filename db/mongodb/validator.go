@@ -0,0 +1,451 @@
+package mongodb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lfhy/morm/schema"
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 文档校验规则的标签选项
+var validationOptions = []string{"required", "enum", "min", "max", "pattern"}
+
+var (
+	objectIDType   = reflect.TypeOf(primitive.ObjectID{})
+	decimalType    = reflect.TypeOf(primitive.Decimal128{})
+	dateTimeType   = reflect.TypeOf(primitive.DateTime(0))
+	emptyIfaceType = reflect.TypeOf((*any)(nil)).Elem()
+)
+
+// 模型生成的 $jsonSchema 文档 字段类型来自结构体 规则来自 morm 标签
+// 没有声明 required 的字段允许缺失 指针 切片与 map 允许为 null
+func JSONSchema(data any) (bson.D, error) {
+	sch := schema.Of(data)
+	if sch == nil {
+		return nil, fmt.Errorf("morm: %T is not a struct", data)
+	}
+	return objectSchema(sch, map[reflect.Type]bool{sch.Type: true})
+}
+
+// 集合的校验规则 即 {$jsonSchema: JSONSchema(data)}
+func Validator(data any) (bson.D, error) {
+	js, err := JSONSchema(data)
+	if err != nil {
+		return nil, err
+	}
+	return bson.D{{Key: "$jsonSchema", Value: js}}, nil
+}
+
+// 模型是否声明了文档校验规则
+func HasValidationRules(data any) bool {
+	sch := schema.Of(data)
+	return sch != nil && hasRules(sch, map[reflect.Type]bool{sch.Type: true})
+}
+
+func hasRules(sch *schema.Schema, seen map[reflect.Type]bool) bool {
+	for _, f := range sch.Flat {
+		if f.BSON == "" {
+			continue
+		}
+		for _, opt := range validationOptions {
+			if f.Has(opt) {
+				return true
+			}
+		}
+		if nested := nestedSchema(f.Type); nested != nil && !seen[nested.Type] {
+			seen[nested.Type] = true
+			if hasRules(nested, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 字段为结构体或结构体指针时返回结构体的解析结果
+func nestedSchema(typ reflect.Type) *schema.Schema {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || typ == timeType || typ == objectIDType || typ == decimalType {
+		return nil
+	}
+	return schema.Parse(typ)
+}
+
+func objectSchema(sch *schema.Schema, seen map[reflect.Type]bool) (bson.D, error) {
+	var required bson.A
+	properties := bson.D{}
+	for _, f := range sch.Flat {
+		if f.BSON == "" {
+			continue
+		}
+		prop, err := fieldSchema(f, seen)
+		if err != nil {
+			return nil, err
+		}
+		properties = append(properties, bson.E{Key: f.BSON, Value: prop})
+		if f.Has("required") {
+			required = append(required, f.BSON)
+		}
+	}
+	doc := bson.D{{Key: "bsonType", Value: "object"}}
+	if len(required) > 0 {
+		doc = append(doc, bson.E{Key: "required", Value: required})
+	}
+	return append(doc, bson.E{Key: "properties", Value: properties}), nil
+}
+
+func fieldSchema(f *schema.Field, seen map[reflect.Type]bool) (bson.D, error) {
+	doc, err := typeSchema(f.Type, seen)
+	if err != nil {
+		return nil, err
+	}
+	typ := f.Type
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	// 字符串主键按十六进制写入时转换为 ObjectID 为空时驱动生成 ObjectID
+	if f.BSON == "_id" && typ.Kind() == reflect.String {
+		return bson.D{{Key: "bsonType", Value: bson.A{"objectId", "string"}}}, nil
+	}
+	// 加密字段保存的是密文 只校验类型
+	if f.Has("encrypt") {
+		return doc, nil
+	}
+	invalid := func(opt string) error {
+		return fmt.Errorf("morm: invalid %s %q on field %s", opt, f.Options[opt], f.Name)
+	}
+	if f.Has("enum") {
+		var values bson.A
+		for _, s := range strings.Split(f.Options["enum"], "|") {
+			v, err := parseValue(typ, s)
+			if err != nil {
+				return nil, invalid("enum")
+			}
+			values = append(values, v)
+		}
+		doc = append(doc, bson.E{Key: "enum", Value: values})
+	}
+	for _, opt := range []string{"min", "max"} {
+		if !f.Has(opt) {
+			continue
+		}
+		key, ok := boundKey(typ, opt)
+		if !ok {
+			return nil, invalid(opt)
+		}
+		n, err := strconv.ParseFloat(f.Options[opt], 64)
+		if err != nil {
+			return nil, invalid(opt)
+		}
+		if key == "minimum" || key == "maximum" {
+			doc = append(doc, bson.E{Key: key, Value: number(n)})
+			continue
+		}
+		// 长度只能是非负整数
+		if n < 0 || n != float64(int64(n)) {
+			return nil, invalid(opt)
+		}
+		doc = append(doc, bson.E{Key: key, Value: int64(n)})
+	}
+	if f.Has("pattern") {
+		if typ.Kind() != reflect.String || f.Options["pattern"] == "" {
+			return nil, invalid("pattern")
+		}
+		// 提前发现无法编译的正则 避免同步到数据库时才报错
+		if _, err := regexp.Compile(f.Options["pattern"]); err != nil {
+			return nil, invalid("pattern")
+		}
+		doc = append(doc, bson.E{Key: "pattern", Value: f.Options["pattern"]})
+	}
+	return doc, nil
+}
+
+// min max 对应的 $jsonSchema 关键字 字符串限制长度 数组限制元素个数 数值限制大小
+func boundKey(typ reflect.Type, opt string) (string, bool) {
+	switch typ.Kind() {
+	case reflect.String:
+		return opt + "Length", true
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return "", false
+		}
+		return opt + "Items", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if typ == dateTimeType {
+			return "", false
+		}
+		if opt == "min" {
+			return "minimum", true
+		}
+		return "maximum", true
+	}
+	return "", false
+}
+
+// 整数保存为 int64 否则为 double
+func number(n float64) any {
+	if n == float64(int64(n)) {
+		return int64(n)
+	}
+	return n
+}
+
+// 按字段类型解析枚举值
+func parseValue(typ reflect.Type, s string) (any, error) {
+	s = strings.TrimSpace(s)
+	switch typ.Kind() {
+	case reflect.String:
+		return s, nil
+	case reflect.Bool:
+		return strconv.ParseBool(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseInt(s, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(s, 64)
+	}
+	return nil, fmt.Errorf("morm: enum is not supported on %s", typ)
+}
+
+// 类型对应的 bsonType 与嵌套规则
+func typeSchema(typ reflect.Type, seen map[reflect.Type]bool) (bson.D, error) {
+	nullable := false
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+		nullable = true
+	}
+	var bsonType bson.A
+	var doc bson.D
+	switch {
+	case typ == timeType || typ == dateTimeType:
+		bsonType = bson.A{"date"}
+	case typ == objectIDType:
+		bsonType = bson.A{"objectId"}
+	case typ == decimalType:
+		bsonType = bson.A{"decimal"}
+	case typ == emptyIfaceType || typ.Kind() == reflect.Interface:
+		// 任意类型不限制
+		return bson.D{}, nil
+	case (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) && typ.Elem().Kind() == reflect.Uint8:
+		bsonType = bson.A{"binData"}
+		nullable = nullable || typ.Kind() == reflect.Slice
+	default:
+		switch typ.Kind() {
+		case reflect.String:
+			bsonType = bson.A{"string"}
+		case reflect.Bool:
+			bsonType = bson.A{"bool"}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			// 驱动按数值大小写入 int 或 long
+			bsonType = bson.A{"int", "long"}
+		case reflect.Float32, reflect.Float64:
+			bsonType = bson.A{"number"}
+		case reflect.Slice, reflect.Array:
+			bsonType = bson.A{"array"}
+			nullable = nullable || typ.Kind() == reflect.Slice
+			items, err := typeSchema(typ.Elem(), seen)
+			if err != nil {
+				return nil, err
+			}
+			if len(items) > 0 {
+				doc = append(doc, bson.E{Key: "items", Value: items})
+			}
+		case reflect.Map:
+			bsonType = bson.A{"object"}
+			nullable = true
+		case reflect.Struct:
+			if seen[typ] {
+				// 递归引用的结构体只校验类型
+				bsonType = bson.A{"object"}
+				break
+			}
+			seen[typ] = true
+			obj, err := objectSchema(schema.Parse(typ), seen)
+			delete(seen, typ)
+			if err != nil {
+				return nil, err
+			}
+			// objectSchema 的第一项为 bsonType
+			bsonType = bson.A{"object"}
+			doc = append(doc, obj[1:]...)
+		default:
+			return bson.D{}, nil
+		}
+	}
+	if nullable {
+		bsonType = append(bsonType, "null")
+	}
+	var t any = bsonType
+	if len(bsonType) == 1 {
+		t = bsonType[0]
+	}
+	return append(bson.D{{Key: "bsonType", Value: t}}, doc...), nil
+}
+
+// 补全校验级别与处理方式 为空时使用连接的配置
+func (m *DBConn) validatorOptions(opts types.ValidatorOptions) types.ValidatorOptions {
+	if opts.Level == "" {
+		opts.Level = m.ValidationLevel
+	}
+	if opts.Action == "" {
+		opts.Action = m.ValidationAction
+	}
+	return opts
+}
+
+// 集合当前的校验规则
+type CollectionValidator struct {
+	// 集合是否存在
+	Exists    bool
+	Validator bson.Raw
+	Level     string
+	Action    string
+}
+
+// 读取集合当前的校验规则
+func GetValidator(ctx context.Context, db *mongo.Database, collection string) (CollectionValidator, error) {
+	var current CollectionValidator
+	specs, err := db.ListCollectionSpecifications(ctx, bson.M{"name": collection})
+	if err != nil || len(specs) == 0 {
+		return current, err
+	}
+	current.Exists = true
+	opts := specs[0].Options
+	if v, ok := opts.Lookup("validator").DocumentOK(); ok {
+		current.Validator = v
+	}
+	current.Level, _ = opts.Lookup("validationLevel").StringValueOK()
+	current.Action, _ = opts.Lookup("validationAction").StringValueOK()
+	return current, nil
+}
+
+// 当前规则与模型生成的规则是否一致 未设置的级别与处理方式按服务端默认值比较
+func (c CollectionValidator) Same(validator bson.D, opts types.ValidatorOptions) bool {
+	level, action := c.Level, c.Action
+	if level == "" {
+		level = types.ValidationLevelStrict
+	}
+	if action == "" {
+		action = types.ValidationActionError
+	}
+	if opts.Level != "" && opts.Level != level || opts.Action != "" && opts.Action != action {
+		return false
+	}
+	return c.Validator != nil && canonicalJSON(c.Validator) == canonicalJSON(validator)
+}
+
+// 键排序后的 JSON 用于比较 数值类型不同但值相同时视为一致
+func canonicalJSON(v any) string {
+	data, err := bson.MarshalExtJSON(v, false, false)
+	if err != nil {
+		return ""
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return ""
+	}
+	data, _ = json.Marshal(doc)
+	return string(data)
+}
+
+// 应用模型生成的校验规则 集合不存在时创建集合 否则通过 collMod 修改
+func (m *DBConn) SyncValidators(ctx context.Context, opts types.ValidatorOptions, models ...any) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	opts = m.validatorOptions(opts)
+	db := m.Client.Database(m.Database)
+	for _, data := range models {
+		name := GetTableName(data)
+		validator, err := Validator(data)
+		if err != nil {
+			return err
+		}
+		current, err := GetValidator(ctx, db, name)
+		if err != nil {
+			return err
+		}
+		if current.Same(validator, opts) {
+			continue
+		}
+		if !current.Exists {
+			create := options.CreateCollection().SetValidator(validator)
+			if opts.Level != "" {
+				create.SetValidationLevel(opts.Level)
+			}
+			if opts.Action != "" {
+				create.SetValidationAction(opts.Action)
+			}
+			if err := db.CreateCollection(ctx, name, create); err != nil {
+				return fmt.Errorf("create collection %s: %w", name, err)
+			}
+			continue
+		}
+		if err := db.RunCommand(ctx, collModCommand(name, validator, opts)).Err(); err != nil {
+			return fmt.Errorf("set validator on %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func collModCommand(collection string, validator bson.D, opts types.ValidatorOptions) bson.D {
+	cmd := bson.D{{Key: "collMod", Value: collection}, {Key: "validator", Value: validator}}
+	return append(cmd, validationOptionsDoc(opts)...)
+}
+
+func validationOptionsDoc(opts types.ValidatorOptions) bson.D {
+	var doc bson.D
+	if opts.Level != "" {
+		doc = append(doc, bson.E{Key: "validationLevel", Value: opts.Level})
+	}
+	if opts.Action != "" {
+		doc = append(doc, bson.E{Key: "validationAction", Value: opts.Action})
+	}
+	return doc
+}
+
+// 将校验规则差异写入计划
+func PlanValidator(plan *types.SchemaPlan, collection string, validator bson.D, current CollectionValidator, opts types.ValidatorOptions) {
+	if current.Same(validator, opts) {
+		return
+	}
+	change := types.SchemaChange{Table: collection, Kind: types.ChangeSetValidator}
+	switch {
+	case !current.Exists:
+		change.Detail = "create collection"
+		createOpts := append(bson.D{{Key: "validator", Value: validator}}, validationOptionsDoc(opts)...)
+		plan.Statements = append(plan.Statements, fmt.Sprintf("db.createCollection(%q, %s)", collection, extJSON(createOpts)))
+	case current.Validator == nil:
+		change.Detail = "no validator"
+		plan.Statements = append(plan.Statements, fmt.Sprintf("db.runCommand(%s)", extJSON(collModCommand(collection, validator, opts))))
+	default:
+		change.Detail = "validator differs"
+		if canonicalJSON(current.Validator) == canonicalJSON(validator) {
+			change.Detail = fmt.Sprintf("level %s action %s", orDefault(current.Level, types.ValidationLevelStrict), orDefault(current.Action, types.ValidationActionError))
+		}
+		plan.Statements = append(plan.Statements, fmt.Sprintf("db.runCommand(%s)", extJSON(collModCommand(collection, validator, opts))))
+	}
+	plan.Changes = append(plan.Changes, change)
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
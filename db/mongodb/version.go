@@ -0,0 +1,170 @@
+package mongodb
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/lfhy/morm/schema"
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// 乐观锁版本字段
+// 结构体字段带有 morm:"version" 标签时 Update / Save 会校验并自增版本号
+type versionField struct {
+	name  string // bson 字段名
+	index []int  // 字段在结构体中的索引路径（含匿名嵌套）
+}
+
+var versionFieldCache sync.Map // reflect.Type -> *versionField
+
+// 获取结构体的版本字段 没有时返回 nil
+func getVersionField(data any) *versionField {
+	typ := reflect.TypeOf(data)
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil
+	}
+	if v, ok := versionFieldCache.Load(typ); ok {
+		return v.(*versionField)
+	}
+	vf := parseVersionField(typ)
+	versionFieldCache.Store(typ, vf)
+	return vf
+}
+
+func parseVersionField(typ reflect.Type) *versionField {
+	for _, f := range schema.Parse(typ).Flat {
+		if !f.Has("version") {
+			continue
+		}
+		name := f.BSON
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		return &versionField{name: name, index: f.Index}
+	}
+	return nil
+}
+
+func (vf *versionField) field(data any) (reflect.Value, bool) {
+	val := reflect.ValueOf(data)
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return reflect.Value{}, false
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	return val.FieldByIndex(vf.index), true
+}
+
+// 读取版本号
+func (vf *versionField) get(data any) int64 {
+	field, ok := vf.field(data)
+	if !ok {
+		return 0
+	}
+	switch {
+	case field.CanInt():
+		return field.Int()
+	case field.CanUint():
+		return int64(field.Uint())
+	}
+	return 0
+}
+
+// 回写版本号
+func (vf *versionField) set(data any, version int64) {
+	field, ok := vf.field(data)
+	if !ok || !field.CanSet() {
+		return
+	}
+	switch {
+	case field.CanInt():
+		field.SetInt(version)
+	case field.CanUint():
+		field.SetUint(uint64(version))
+	}
+}
+
+// 插入时初始化版本号为 1
+func fillCreateVersion(data any, doc bson.M) {
+	vf := getVersionField(data)
+	if vf == nil || vf.get(data) != 0 {
+		return
+	}
+	doc[vf.name] = int64(1)
+	vf.set(data, 1)
+}
+
+// 将版本号校验加入过滤条件 并在更新文档中使用 $inc 自增版本号
+// 返回新的过滤条件与当前版本号 版本号为零值时不做校验
+func applyVersion(vf *versionField, data any, filter, update bson.M) (bson.M, int64) {
+	set := updateDoc(update, "$set")
+	delete(set, vf.name)
+	if len(set) > 0 {
+		update["$set"] = set
+	} else {
+		delete(update, "$set")
+	}
+	inc := updateDoc(update, "$inc")
+	inc[vf.name] = 1
+	update["$inc"] = inc
+
+	current := vf.get(data)
+	if current == 0 {
+		return filter, 0
+	}
+	versionFilter := bson.M{}
+	for k, v := range filter {
+		versionFilter[k] = v
+	}
+	versionFilter[vf.name] = current
+	return versionFilter, current
+}
+
+// 带版本号保存时定位数据的条件
+// 条件中没有 _id 时加入数据中的 _id 避免空条件匹配集合中的任意数据
+func saveFilter(doc, where bson.M) bson.M {
+	if _, ok := where["_id"]; ok {
+		return where
+	}
+	id, ok := doc["_id"]
+	if !ok || id == nil || reflect.ValueOf(id).IsZero() {
+		return where
+	}
+	filter := bson.M{"_id": id}
+	for k, v := range where {
+		filter[k] = v
+	}
+	return filter
+}
+
+// 判断数据是否存在的条件 优先只按 _id 判断 没有任何条件时返回 nil
+func existsFilter(filter bson.M) bson.M {
+	if id, ok := filter["_id"]; ok {
+		return bson.M{"_id": id}
+	}
+	if len(filter) == 0 {
+		return nil
+	}
+	return filter
+}
+
+// 根据匹配数量判断版本是否冲突 成功时回写新的版本号
+func checkVersion(vf *versionField, data any, current, matched int64) error {
+	if current == 0 {
+		return nil
+	}
+	if matched == 0 {
+		return types.ErrStaleVersion
+	}
+	vf.set(data, current+1)
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"github.com/lfhy/morm/log"
+	"github.com/lfhy/morm/schema"
 	"github.com/lfhy/morm/types"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -16,16 +17,53 @@ import (
 
 // 限制条件
 func (m *Model) Where(condition any, value ...any) types.ORMModel {
+	if _, ok := condition.(types.Condition); ok {
+		return m.whereConditions(append([]any{condition}, value...)...)
+	}
 	if len(value) > 0 {
 		key, ok := condition.(string)
 		if ok {
-			m.WhereList[key] = bson.M{"$eq": value[0]}
+			m.WhereList[key] = bson.M{"$eq": m.whereValue(key, value[0])}
 			return m
 		}
 	}
 	return m.whereMode(condition, types.WhereIs)
 }
 
+// 按字段名选择条件 零值同样作为条件
+// nil 指针匹配 null
+func (m *Model) WhereFields(data any, fields ...string) types.ORMModel {
+	sch := schema.Of(data)
+	if sch == nil {
+		m.err = fmt.Errorf("%w: %s in %T", types.ErrUnknownField, strings.Join(fields, ","), data)
+		return m
+	}
+	t := reflect.ValueOf(data)
+	if t.Kind() == reflect.Ptr {
+		if t.IsNil() {
+			t = reflect.New(t.Type().Elem())
+		}
+		t = t.Elem()
+	}
+	if m.WhereList == nil {
+		m.WhereList = bson.M{}
+	}
+	for _, name := range fields {
+		f := sch.LookUp(name)
+		if f == nil || f.BSON == "" {
+			m.err = fmt.Errorf("%w: %s in %s", types.ErrUnknownField, name, t.Type())
+			return m
+		}
+		field := t.FieldByIndex(f.Index)
+		var value any
+		if field.Kind() != reflect.Ptr || !field.IsNil() {
+			value = reflect.Indirect(field).Interface()
+		}
+		m.saveOplist(types.WhereIs, f.BSON, value)
+	}
+	return m
+}
+
 func (m *Model) WhereIs(key string, value any) types.ORMModel {
 	m.WhereList[key] = value
 	return m
@@ -59,7 +97,7 @@ func (m *Model) WhereNot(condition any, value ...any) types.ORMModel {
 	if len(value) > 0 {
 		key, ok := condition.(string)
 		if ok {
-			m.WhereList[key] = bson.M{"$ne": value[0]}
+			m.WhereList[key] = bson.M{"$ne": m.whereValue(key, value[0])}
 			return m
 		}
 	}
@@ -185,33 +223,23 @@ func (m *Model) whereMode(condition any, mode types.WhereMode) types.ORMModel {
 
 	switch t.Kind() {
 	case reflect.Struct:
-		// 定义一个内部函数来递归处理结构体字段（包括嵌套的匿名结构体）
-		var processStructFields func(reflect.Value, reflect.Type)
-		processStructFields = func(val reflect.Value, typ reflect.Type) {
-			for i := 0; i < val.NumField(); i++ {
-				field := val.Field(i)
-				fieldType := typ.Field(i)
-
-				// 如果是嵌套的匿名结构体，递归处理
-				if field.Kind() == reflect.Struct && fieldType.Anonymous {
-					processStructFields(field, fieldType.Type)
-					continue
-				}
-
-				if field.IsZero() {
-					continue
-				}
-				v, ok := fieldType.Tag.Lookup("bson")
-				if ok {
-					value := field.Interface()
-					m.saveOplist(mode, v, value)
-					continue
+		// 按缓存的字段信息处理 包括嵌套的匿名结构体
+		for _, f := range schema.Parse(t.Type()).Flat {
+			if f.BSON == "" {
+				continue
+			}
+			field := t.FieldByIndex(f.Index)
+			if mode == types.OrderAsc || mode == types.OrderDesc {
+				// 排序只看有值的字段
+				if !field.IsZero() {
+					m.saveOplist(mode, f.BSON, "")
 				}
+				continue
+			}
+			if value, ok := f.Condition(field); ok {
+				m.saveOplist(mode, f.BSON, value)
 			}
 		}
-
-		// 处理当前结构体的字段
-		processStructFields(t, t.Type())
 	case reflect.Map:
 		// 遍历map
 		for _, k := range t.MapKeys() {
@@ -245,9 +273,14 @@ func (m *Model) CheckOID() {
 			m.WhereList["_id"] = mp
 		case bson.M:
 			for key, value := range m.WhereList["_id"].(bson.M) {
-				if key == "$in" {
+				// 只转换字符串 已经是 ObjectID 的保持不变
+				if key == "$in" || key == "$nin" {
+					strs, ok := value.([]string)
+					if !ok {
+						continue
+					}
 					mp := make([]primitive.ObjectID, 0)
-					for _, v := range value.([]string) {
+					for _, v := range strs {
 						ids, err := primitive.ObjectIDFromHex(v)
 						if err != nil {
 							log.Error("转换失败:", err, "原始ID:", v)
@@ -255,8 +288,8 @@ func (m *Model) CheckOID() {
 						mp = append(mp, ids)
 					}
 					m.WhereList["_id"].(bson.M)[key] = mp
-				} else {
-					ids, err := primitive.ObjectIDFromHex(fmt.Sprint(value))
+				} else if str, ok := value.(string); ok {
+					ids, err := primitive.ObjectIDFromHex(str)
 					if err != nil {
 						log.Error("转换失败:", err, "原始ID:", value)
 					}
@@ -353,42 +386,24 @@ func handleMap(m map[string]interface{}, value string) {
 	}
 }
 
-// 处理 struct 类型
+// 处理 struct 类型 设置缓存的 _id 字段
 func handleStruct(val reflect.Value, value string) {
-	typ := val.Type()
-	for i := 0; i < typ.NumField(); i++ {
-		field := typ.Field(i)
-
-		// 如果是嵌套结构体(匿名字段)，递归处理
-		if field.Type.Kind() == reflect.Struct && field.Anonymous {
-			nestedVal := val.Field(i)
-			if nestedVal.CanAddr() && nestedVal.Kind() == reflect.Struct {
-				handleStruct(nestedVal, value)
-			}
-			continue
-		}
-
-		// 解析 bson 标签
-		bsonTag := field.Tag.Get("bson")
-		if bsonTag == "" {
-			continue
-		}
-		// 分割标签（可能包含逗号分隔的选项）
-		tagParts := strings.Split(bsonTag, ",")
-		for _, part := range tagParts {
-			if part == "id" || part == "_id" {
-				fieldVal := val.Field(i)
-				// 检查字段是否可设置且类型匹配
-				if fieldVal.CanSet() && fieldVal.Kind() == reflect.String {
-					fieldVal.SetString(value)
-				}
-				return // 找到即停止
-			}
-		}
+	f := schema.Parse(val.Type()).IDField
+	if f == nil {
+		return
+	}
+	fieldVal := val.FieldByIndex(f.Index)
+	// 检查字段是否可设置且类型匹配
+	if fieldVal.CanSet() && fieldVal.Kind() == reflect.String {
+		fieldVal.SetString(value)
 	}
 }
 
 func (m *Model) saveOplist(mode types.WhereMode, column string, value any) {
+	switch mode {
+	case types.WhereIs, types.WhereNot:
+		value = m.whereValue(column, value)
+	}
 	switch mode {
 	case types.WhereIs:
 		if column == "_id" {
@@ -456,6 +471,9 @@ func (m *Model) ResetFilter() types.ORMModel {
 	m.WhereList = bson.M{}
 	m.OpList = sync.Map{}
 	m.Data = nil
+	m.err = nil
+	m.preloads = nil
+	m.joins = nil
 	return m
 }
 
@@ -132,7 +132,11 @@ func Init(log logger.Interface) (types.ORM, error) {
 		return nil, err
 	}
 
-	sqlorm.ORMConn = &sqlorm.DBConn{DB: conn, AutoMigrate: conf.ReadConfigToBool("db", "auto_create_table")}
+	sqlorm.ORMConn = &sqlorm.DBConn{
+		DB:          conn,
+		AutoMigrate: conf.ReadConfigToBool("db", "auto_create_table"),
+		StaleRetry:  conf.ReadConfigToInt("db", "stale_retry"),
+	}
 	// 校验数据库
 	// sqlorm.ORMConn.CheckDB()
 	return sqlorm.ORMConn, nil
@@ -84,6 +84,10 @@ func Init(log logger.Interface) (types.ORM, error) {
 		return nil, err
 	}
 
-	sqlorm.ORMConn = &sqlorm.DBConn{DB: conn, AutoMigrate: conf.ReadConfigToBool("db", "auto_create_table")}
+	sqlorm.ORMConn = &sqlorm.DBConn{
+		DB:          conn,
+		AutoMigrate: conf.ReadConfigToBool("db", "auto_create_table"),
+		StaleRetry:  conf.ReadConfigToInt("db", "stale_retry"),
+	}
 	return sqlorm.ORMConn, nil
 }
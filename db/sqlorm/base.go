@@ -3,6 +3,9 @@ package sqlorm
 import (
 	"errors"
 	"fmt"
+	"reflect"
+
+	"github.com/lfhy/morm/encrypt"
 
 	"github.com/lfhy/morm/types"
 )
@@ -12,7 +15,17 @@ func (m *Model) Create(data any) (id string, err error) {
 	if data != nil {
 		m.Data = data
 	}
-	err = m.getDB().Create(m.Data).Scan(m.Data).Error
+	op := m.operation(types.OpCreate)
+	op.Update = m.Data
+	err = m.invoke(op, func() error {
+		m.initVersion(m.Data)
+		tx := m.getDB().Create(m.Data)
+		op.Rows = tx.RowsAffected
+		if err := tx.Scan(m.Data).Error; err != nil {
+			return err
+		}
+		return m.decrypt(m.Data)
+	})
 	id = m.getID(m.Data)
 	return
 }
@@ -24,6 +37,14 @@ func (m *Model) Insert(data any) (err error) {
 
 // 更新或插入数据
 func (m *Model) Save(data any, value ...any) (err error) {
+	op := m.operation(types.OpSave)
+	op.Update = data
+	return m.invoke(op, func() error {
+		return m.save(op, data, value...)
+	})
+}
+
+func (m *Model) save(op *types.Operation, data any, value ...any) (err error) {
 	q := m.makeQuery()
 	if len(value) > 0 {
 		if col, ok := data.(string); ok {
@@ -37,7 +58,7 @@ func (m *Model) Save(data any, value ...any) (err error) {
 	var i int64
 	q.Count(&i)
 	if i > 0 {
-		return m.Update(data, value...)
+		return m.update(op, data, value...)
 	} else {
 		// 组合新数据
 		m.whereMode(data, types.WhereIs)
@@ -46,15 +67,29 @@ func (m *Model) Save(data any, value ...any) (err error) {
 			newData[key.(string)] = value
 			return true
 		})
+		// 新插入的数据版本号从 1 开始
+		if field, _ := m.versionField(data); field != nil {
+			if _, ok := newData[field.DBName]; !ok {
+				newData[field.DBName] = 1
+			}
+		}
 		table := m.Table
 		if table == "" {
 			table = GetTableName(data)
 		}
+		// 按表名插入 map 时不经过加密回调
+		if newData, err = encrypt.EncryptMap(m.GetContext(), reflect.TypeOf(data), newData); err != nil {
+			return err
+		}
 		tx := m.getDB().Table(table).Create(newData)
+		op.Rows = tx.RowsAffected
 		if _, ok := data.(string); ok {
 			return tx.Error
 		}
-		return tx.Scan(data).Error
+		if err := tx.Scan(data).Error; err != nil {
+			return err
+		}
+		return m.decrypt(data)
 	}
 }
 
@@ -67,21 +102,37 @@ func (m *Model) Delete(data ...any) error {
 	if len(data) > 0 && data[0] != nil {
 		m.Data = data[0]
 	}
-	return m.makeQuery().Delete(m.Data).Error
+	return m.Find().Delete()
 }
 
 // 修改
 func (m *Model) Update(data any, value ...any) error {
+	op := m.operation(types.OpUpdate)
+	op.Update = data
+	return m.invoke(op, func() error {
+		return m.update(op, data, value...)
+	})
+}
+
+func (m *Model) update(op *types.Operation, data any, value ...any) error {
 	if len(value) > 0 {
 		col, ok := data.(string)
 		if ok {
-			return m.makeQuery().Update(col, value[0]).Error
+			tx := m.makeQuery().Update(col, value[0])
+			op.Rows = tx.RowsAffected
+			return tx.Error
 		}
 	}
 	if data != nil {
 		m.Data = data
 	}
-	return m.makeQuery().Updates(m.Data).Error
+	// 带有版本字段时使用乐观锁更新
+	if field, sch := m.versionField(m.Data); field != nil {
+		return m.updateWithVersion(op, field, sch)
+	}
+	tx := m.makeQuery().Updates(m.Data)
+	op.Rows = tx.RowsAffected
+	return tx.Error
 }
 
 // 查询数据
@@ -131,6 +182,14 @@ err := model.BulkWrite(operations, true)
 **
 */
 func (m *Model) BulkWrite(datas any, order bool) error {
+	op := m.operation(types.OpBulk)
+	op.Update = datas
+	return m.invoke(op, func() error {
+		return m.bulkWrite(op, datas, order)
+	})
+}
+
+func (m *Model) bulkWrite(op *types.Operation, datas any, order bool) error {
 	operations, ok := datas.([]types.BulkWriteOperation)
 	if !ok {
 		return errors.New("datas must be []orm.BulkWriteOperation")
@@ -145,44 +204,49 @@ func (m *Model) BulkWrite(datas any, order bool) error {
 		return tx.Error
 	}
 
-	for _, op := range operations {
-		switch op.Type {
+	for _, bulk := range operations {
+		switch bulk.Type {
 		case "insert":
-			if err := tx.Create(op.Data).Error; err != nil {
+			if err := tx.Create(bulk.Data).Error; err != nil {
 				if order {
 					tx.Rollback()
 					return err
 				}
 				continue
 			}
+			op.Rows++
 		case "update":
-			q := tx.Model(op.Data)
-			if len(op.Where) > 0 {
-				q = q.Where(op.Where)
+			q := tx.Model(bulk.Data)
+			if len(bulk.Where) > 0 {
+				q = q.Where(bulk.Where)
 			}
-			if err := q.Updates(op.Values).Error; err != nil {
+			result := q.Updates(bulk.Values)
+			if err := result.Error; err != nil {
 				if order {
 					tx.Rollback()
 					return err
 				}
 				continue
 			}
+			op.Rows += result.RowsAffected
 		case "delete":
-			q := tx.Model(op.Data)
-			if len(op.Where) > 0 {
-				q = q.Where(op.Where)
+			q := tx.Model(bulk.Data)
+			if len(bulk.Where) > 0 {
+				q = q.Where(bulk.Where)
 			}
-			if err := q.Delete(op.Data).Error; err != nil {
+			result := q.Delete(bulk.Data)
+			if err := result.Error; err != nil {
 				if order {
 					tx.Rollback()
 					return err
 				}
 				continue
 			}
+			op.Rows += result.RowsAffected
 		default:
 			if order {
 				tx.Rollback()
-				return fmt.Errorf("unsupported operation type: %s", op.Type)
+				return fmt.Errorf("unsupported operation type: %s", bulk.Type)
 			}
 			continue
 		}
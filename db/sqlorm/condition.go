@@ -0,0 +1,59 @@
+package sqlorm
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/lfhy/morm/types"
+)
+
+// 处理字段描述生成的条件 conditions 中的每一项都必须是 types.Condition
+func (m *Model) whereConditions(conditions ...any) types.ORMModel {
+	for _, c := range conditions {
+		cond, ok := c.(types.Condition)
+		if !ok {
+			m.err = fmt.Errorf("morm: mixed condition %T in Where", c)
+			return m
+		}
+		if cond.Column == "" {
+			m.err = fmt.Errorf("%w: no column for condition", types.ErrUnknownField)
+			return m
+		}
+		m.saveCondition(cond)
+	}
+	return m
+}
+
+func (m *Model) saveCondition(c types.Condition) {
+	switch c.Op {
+	case types.CondEq:
+		m.saveOplist(types.WhereIs, c.Column, c.Value)
+	case types.CondNe:
+		m.saveOplist(types.WhereNot, c.Column, c.Value)
+	case types.CondGt:
+		m.saveOplist(types.WhereGt, c.Column, c.Value)
+	case types.CondGte:
+		m.saveOplist(types.WhereGte, c.Column, c.Value)
+	case types.CondLt:
+		m.saveOplist(types.WhereLt, c.Column, c.Value)
+	case types.CondLte:
+		m.saveOplist(types.WhereLte, c.Column, c.Value)
+	case types.CondIn:
+		m.OpList.Store(fmt.Sprintf("where `%s` IN ?", c.Column), m.whereValues(c.Column, c.Value))
+	case types.CondNotIn:
+		m.OpList.Store(fmt.Sprintf("where `%s` NOT IN ?", c.Column), m.whereValues(c.Column, c.Value))
+	case types.CondLike:
+		// 与 WhereLike("name", pattern) 一致 不再追加 %
+		m.OpList.Store(fmt.Sprintf("where `%s` like ?", c.Column), c.Value)
+	}
+}
+
+// 列表中的每个值都按加密列处理
+func (m *Model) whereValues(column string, values any) []any {
+	v := reflect.ValueOf(values)
+	list := make([]any, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		list = append(list, m.whereValue(column, v.Index(i).Interface()))
+	}
+	return list
+}
@@ -0,0 +1,87 @@
+package sqlorm
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/lfhy/morm/encrypt"
+	"github.com/lfhy/morm/log"
+	"gorm.io/gorm"
+)
+
+// 保存恢复明文函数的键
+const encryptRestoreKey = "morm:encrypt_restore"
+
+// 注册字段加密回调
+// 写入前加密 写入后恢复明文 查询后解密 均在 morm 钩子之内执行 钩子中看到的始终是明文
+func registerEncryptCallbacks(db *gorm.DB) {
+	cb := db.Callback()
+	if cb.Create().Get("morm:encrypt") != nil {
+		return
+	}
+	cb.Create().After("morm:before_create").Before("gorm:create").Register("morm:encrypt", encryptCallback)
+	cb.Create().After("gorm:create").Before("morm:after_create").Register("morm:encrypt_restore", restoreCallback)
+	cb.Update().After("morm:before_update").Before("gorm:update").Register("morm:encrypt", encryptCallback)
+	cb.Update().After("gorm:update").Before("morm:after_update").Register("morm:encrypt_restore", restoreCallback)
+	cb.Query().After("gorm:after_query").Before("morm:after_find").Register("morm:decrypt", func(db *gorm.DB) {
+		if db.Error != nil || !db.Statement.ReflectValue.IsValid() {
+			return
+		}
+		if err := encrypt.DecryptStruct(db.Statement.Context, db.Statement.ReflectValue); err != nil {
+			db.AddError(err)
+		}
+	})
+}
+
+func encryptCallback(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+	ctx := db.Statement.Context
+	// Update(column, value) 与 Updates(map) 加密 map 中的值
+	if dest, ok := db.Statement.Dest.(map[string]any); ok && db.Statement.Schema != nil {
+		values, err := encrypt.EncryptMap(ctx, db.Statement.Schema.ModelType, dest)
+		if err != nil {
+			db.AddError(err)
+			return
+		}
+		db.Statement.Dest = values
+		return
+	}
+	if !db.Statement.ReflectValue.IsValid() {
+		return
+	}
+	restore, err := encrypt.EncryptStruct(ctx, db.Statement.ReflectValue)
+	if err != nil {
+		db.AddError(err)
+		return
+	}
+	db.InstanceSet(encryptRestoreKey, restore)
+}
+
+func restoreCallback(db *gorm.DB) {
+	if v, ok := db.InstanceGet(encryptRestoreKey); ok {
+		v.(func())()
+	}
+}
+
+// 解密 Scan 等不经过查询回调读取的数据
+func (m *Model) decrypt(data any) error {
+	return encrypt.DecryptStruct(m.GetContext(), data)
+}
+
+// 等值查询条件中的确定性加密字段使用密文查询
+// 非确定性加密字段的密文每次不同 无法作为查询条件 与加密出错一样在执行操作时返回错误
+func (m *Model) whereValue(column string, value any) any {
+	if m.Data == nil {
+		return value
+	}
+	v, err := encrypt.WhereValue(m.GetContext(), reflect.TypeOf(m.Data), column, value)
+	if err != nil {
+		log.Errorf("加密查询条件 %s 出错: %v\n", column, err)
+		if m.err == nil {
+			m.err = fmt.Errorf("morm: where %s: %w", column, err)
+		}
+	}
+	return v
+}
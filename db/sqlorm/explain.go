@@ -0,0 +1,133 @@
+package sqlorm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/lfhy/morm/types"
+	"gorm.io/gorm"
+)
+
+// 按当前的条件生成查询语句 不执行查询
+// 参数直接写入语句中 只用于调试与日志
+func (m *Model) ToSQL() string {
+	stmt := m.dryRun().Statement
+	return m.getDB().Dialector.Explain(stmt.SQL.String(), stmt.Vars...)
+}
+
+// 以 DryRun 模式构造 All 对应的查询
+// 结果写入新建的切片 不会修改模型中的数据
+func (m *Model) dryRun() *gorm.DB {
+	return m.withJoins(m.applyOpList(m.getDB().Session(&gorm.Session{DryRun: true}).Model(m.Data))).Find(m.findDest())
+}
+
+// 与模型类型一致的空切片
+func (m *Model) findDest() any {
+	t := reflect.TypeOf(m.Data)
+	if t == nil || t.Kind() == reflect.String {
+		return &[]map[string]any{}
+	}
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	return reflect.New(reflect.SliceOf(t)).Interface()
+}
+
+// 按当前的条件返回查询计划
+// SQLite 使用 EXPLAIN QUERY PLAN MySQL 使用 EXPLAIN
+func (m *Model) Explain(ctx context.Context) (*types.QueryPlan, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if ctx != nil {
+		prev := m.Ctx
+		m.Ctx = ctx
+		defer func() {
+			m.Ctx = prev
+		}()
+	}
+	var prefix string
+	backend := m.tx.backend()
+	switch backend {
+	case types.SQLite:
+		prefix = "EXPLAIN QUERY PLAN "
+	case types.MySQL:
+		prefix = "EXPLAIN "
+	default:
+		return nil, fmt.Errorf("%w: Explain on %s", types.ErrNotSupported, backend)
+	}
+	query := m.dryRun()
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	stmt := query.Statement
+	var rows []map[string]any
+	if err := m.getDB().Raw(prefix+stmt.SQL.String(), stmt.Vars...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	plan := &types.QueryPlan{
+		Statement: m.getDB().Dialector.Explain(stmt.SQL.String(), stmt.Vars...),
+		Raw:       rows,
+	}
+	for _, row := range rows {
+		if backend == types.SQLite {
+			sqlitePlanRow(plan, fmt.Sprint(row["detail"]))
+		} else {
+			mysqlPlanRow(plan, row)
+		}
+	}
+	return plan, nil
+}
+
+// SQLite 的 detail 如 SCAN users 或 SEARCH users USING INDEX idx_name (name=?)
+func sqlitePlanRow(plan *types.QueryPlan, detail string) {
+	if !strings.HasPrefix(detail, "SCAN ") && !strings.HasPrefix(detail, "SEARCH ") {
+		return
+	}
+	_, using, ok := strings.Cut(detail, " USING ")
+	if !ok {
+		// 没有使用索引的 SCAN 为全表扫描
+		if strings.HasPrefix(detail, "SCAN ") {
+			plan.FullScan = true
+		}
+		return
+	}
+	if strings.HasPrefix(using, "INTEGER PRIMARY KEY") || strings.HasPrefix(using, "ROWID") {
+		plan.Indexes = appendIndex(plan.Indexes, "PRIMARY")
+		return
+	}
+	using = strings.TrimPrefix(using, "COVERING ")
+	if name, ok := strings.CutPrefix(using, "INDEX "); ok {
+		name, _, _ = strings.Cut(name, " ")
+		plan.Indexes = appendIndex(plan.Indexes, name)
+	}
+}
+
+// MySQL 的 type 为 ALL 时为全表扫描 key 为实际使用的索引
+func mysqlPlanRow(plan *types.QueryPlan, row map[string]any) {
+	if fmt.Sprint(mysqlValue(row["type"])) == "ALL" {
+		plan.FullScan = true
+	}
+	if key := mysqlValue(row["key"]); key != nil && key != "" {
+		plan.Indexes = appendIndex(plan.Indexes, fmt.Sprint(key))
+	}
+}
+
+// MySQL 驱动可能将文本列返回为 []byte
+func mysqlValue(v any) any {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return v
+}
+
+func appendIndex(list []string, name string) []string {
+	for _, v := range list {
+		if v == name {
+			return list
+		}
+	}
+	return append(list, name)
+}
@@ -0,0 +1,64 @@
+package sqlorm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lfhy/morm/schema"
+	"github.com/lfhy/morm/types"
+)
+
+// 按字段更新 只更新传入的字段 零值同样写入
+// 生成 UPDATE ... SET 字段 = 值 等价 gorm Select(fields).Updates(data)
+func (m *Model) UpdateFields(data any, fields ...string) error {
+	op := m.operation(types.OpUpdate)
+	op.Update = data
+	return m.invoke(op, func() error {
+		return m.updateFields(op, data, fields)
+	})
+}
+
+func (m *Model) updateFields(op *types.Operation, data any, fields []string) error {
+	columns, err := selectColumns(data, fields)
+	if err != nil {
+		return err
+	}
+	m.Data = data
+	// 带有版本字段时使用乐观锁更新
+	if field, sch := m.versionField(m.Data); field != nil {
+		return m.updateWithVersion(op, field, sch, columns...)
+	}
+	tx := m.makeQuery().Select(columns).Updates(m.Data)
+	op.Rows = tx.RowsAffected
+	return tx.Error
+}
+
+// 将字段名 列名或 bson 名转换为列名
+func selectColumns(data any, fields []string) ([]string, error) {
+	if len(fields) == 0 {
+		return nil, errors.New("morm: no fields to update")
+	}
+	sch := schema.Of(data)
+	if sch == nil {
+		return nil, fmt.Errorf("%w: %s in %T", types.ErrUnknownField, strings.Join(fields, ","), data)
+	}
+	columns := make([]string, 0, len(fields))
+	for _, name := range fields {
+		f := sch.LookUp(name)
+		if f == nil || f.Column == "" {
+			return nil, fmt.Errorf("%w: %s in %s", types.ErrUnknownField, name, sch.Type)
+		}
+		columns = append(columns, f.Column)
+	}
+	return columns, nil
+}
+
+func containsColumn(columns []string, column string) bool {
+	for _, c := range columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
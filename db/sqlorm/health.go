@@ -0,0 +1,42 @@
+package sqlorm
+
+import (
+	"context"
+
+	"github.com/lfhy/morm/types"
+)
+
+// 检查数据库连接
+func (m *DBConn) Ping(ctx context.Context) error {
+	sqlDB, err := m.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// 连接池状态
+func (m *DBConn) Stats() types.PoolStats {
+	stats := types.PoolStats{Backend: m.backend()}
+	sqlDB, err := m.DB.DB()
+	if err != nil {
+		return stats
+	}
+	s := sqlDB.Stats()
+	stats.MaxOpenConnections = s.MaxOpenConnections
+	stats.OpenConnections = s.OpenConnections
+	stats.InUse = s.InUse
+	stats.Idle = s.Idle
+	stats.WaitCount = s.WaitCount
+	stats.WaitDuration = s.WaitDuration
+	return stats
+}
+
+// 关闭连接池
+func (m *DBConn) Close(ctx context.Context) error {
+	sqlDB, err := m.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
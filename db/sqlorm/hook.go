@@ -0,0 +1,164 @@
+package sqlorm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/lfhy/morm/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	gschema "gorm.io/gorm/schema"
+)
+
+// 上下文中保存发起操作的连接 供 gorm 回调创建钩子使用的模型
+type modelCtxKey struct{}
+
+// 注册 gorm 回调 将 types 中定义的钩子桥接到 gorm 的生命周期
+func registerHookCallbacks(db *gorm.DB) {
+	cb := db.Callback()
+	// 同一个 gorm.DB 被多个连接复用时只注册一次
+	if cb.Create().Get("morm:before_create") != nil {
+		return
+	}
+	cb.Create().Before("gorm:create").Register("morm:before_create", hookCallback(func(h types.BeforeCreateHook, ctx context.Context, m types.ORMModel) error {
+		return h.BeforeCreate(ctx, m)
+	}))
+	cb.Create().After("gorm:create").Register("morm:after_create", hookCallback(func(h types.AfterCreateHook, ctx context.Context, m types.ORMModel) error {
+		return h.AfterCreate(ctx, m)
+	}))
+	cb.Update().Before("gorm:update").Register("morm:before_update", hookCallback(func(h types.BeforeUpdateHook, ctx context.Context, m types.ORMModel) error {
+		return h.BeforeUpdate(ctx, m)
+	}))
+	cb.Update().After("gorm:update").Register("morm:after_update", hookCallback(func(h types.AfterUpdateHook, ctx context.Context, m types.ORMModel) error {
+		return h.AfterUpdate(ctx, m)
+	}))
+	cb.Delete().Before("gorm:delete").Register("morm:before_delete", hookCallback(func(h types.BeforeDeleteHook, ctx context.Context, m types.ORMModel) error {
+		return h.BeforeDelete(ctx, m)
+	}))
+	cb.Query().After("gorm:after_query").Register("morm:after_find", func(db *gorm.DB) {
+		// 没有查到数据时不调用
+		if db.RowsAffected == 0 {
+			return
+		}
+		hookCallback(func(h types.AfterFindHook, ctx context.Context, m types.ORMModel) error {
+			return h.AfterFind(ctx, m)
+		})(db)
+	})
+}
+
+// 生成调用钩子的 gorm 回调
+func hookCallback[T any](call func(hook T, ctx context.Context, m types.ORMModel) error) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Error != nil || db.Statement.SkipHooks || !db.Statement.ReflectValue.IsValid() {
+			return
+		}
+		ctx := db.Statement.Context
+		model := newHookModel(db)
+		// 使用指针 钩子中对模型的修改才能写回
+		value := db.Statement.ReflectValue
+		if value.CanAddr() {
+			value = value.Addr()
+		}
+		err := types.CallHooks(value.Interface(), func(hook T) error {
+			return call(hook, ctx, model)
+		})
+		if err != nil {
+			db.AddError(err)
+		}
+	}
+}
+
+// 钩子中使用的模型 与当前语句共用同一个连接或事务
+func newHookModel(db *gorm.DB) *Model {
+	conn, _ := db.Statement.Context.Value(modelCtxKey{}).(*DBConn)
+	if conn == nil {
+		conn = &DBConn{DB: db}
+	}
+	return &Model{
+		tx:           conn,
+		translatorDB: db.Session(&gorm.Session{NewDB: true}),
+		OpList:       types.NewOrderedMap(),
+		Ctx:          db.Statement.Context,
+	}
+}
+
+// 对查询结果调用 AfterFind 钩子（游标等不经过 gorm 查询回调的场景）
+func (m *Model) afterFind(data any) error {
+	return types.CallHooks(data, func(hook types.AfterFindHook) error {
+		return hook.AfterFind(m.GetContext(), m.newModel())
+	})
+}
+
+// 绑定到当前连接或事务的新模型
+func (m *Model) newModel() *Model {
+	return &Model{
+		tx:           m.tx,
+		translatorDB: m.translatorDB,
+		OpList:       types.NewOrderedMap(),
+		Ctx:          m.Ctx,
+	}
+}
+
+// gorm 解析模型时发现与 gorm 钩子同名但签名不同的方法会打印警告
+// morm 的钩子与 gorm 钩子同名 morm 创建的连接的日志只过滤掉 morm 钩子引起的警告
+// 解析模型的警告由 gorm 直接写入 logger.Default 不经过连接的日志
+// 需要同时过滤时由应用主动调用 会替换进程内的 logger.Default 影响同一进程中所有的 gorm 连接
+func FilterHookWarnings() {
+	logger.Default = filterHookWarn(logger.Default)
+}
+
+// 替换连接使用的 gorm 日志
+func (m *DBConn) SetLogger(l logger.Interface) {
+	m.DB.Logger = filterHookWarn(l)
+}
+
+func filterHookWarn(l logger.Interface) logger.Interface {
+	if _, ok := l.(hookWarnFilter); ok || l == nil {
+		return l
+	}
+	return hookWarnFilter{Interface: l}
+}
+
+type hookWarnFilter struct {
+	logger.Interface
+}
+
+func (l hookWarnFilter) LogMode(level logger.LogLevel) logger.Interface {
+	return hookWarnFilter{Interface: l.Interface.LogMode(level)}
+}
+
+// 保留被包装日志的参数过滤
+func (l hookWarnFilter) ParamsFilter(ctx context.Context, sql string, params ...any) (string, []any) {
+	if filter, ok := l.Interface.(gorm.ParamsFilter); ok {
+		return filter.ParamsFilter(ctx, sql, params...)
+	}
+	return sql, params
+}
+
+func (l hookWarnFilter) Warn(ctx context.Context, msg string, data ...any) {
+	if len(data) == 3 {
+		if sch, ok := data[0].(*gschema.Schema); ok && isMormHook(sch.ModelType, fmt.Sprint(data[1])) {
+			return
+		}
+	}
+	l.Interface.Warn(ctx, msg, data...)
+}
+
+var (
+	contextType  = reflect.TypeOf((*context.Context)(nil)).Elem()
+	ormModelType = reflect.TypeOf((*types.ORMModel)(nil)).Elem()
+)
+
+// 判断方法是否为 morm 钩子签名 func(context.Context, types.ORMModel) error
+func isMormHook(typ reflect.Type, name string) bool {
+	if typ == nil {
+		return false
+	}
+	method, ok := reflect.PointerTo(typ).MethodByName(name)
+	if !ok {
+		return false
+	}
+	mt := method.Type
+	return mt.NumIn() == 3 && mt.In(1) == contextType && mt.In(2) == ormModelType
+}
@@ -0,0 +1,115 @@
+package sqlorm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lfhy/morm/schema"
+	"github.com/lfhy/morm/types"
+	"gorm.io/gorm"
+)
+
+// 连接的表
+type join struct {
+	table string
+	model any
+	// 完整的 JOIN 语句
+	clause string
+}
+
+// 内连接
+func (m *Model) Join(model any, on string) types.ORMModel {
+	return m.join("JOIN", model, on)
+}
+
+// 左连接
+func (m *Model) LeftJoin(model any, on string) types.ORMModel {
+	return m.join("LEFT JOIN", model, on)
+}
+
+func (m *Model) join(kind string, model any, on string) types.ORMModel {
+	if _, ok := model.(string); !ok {
+		applySchema(m.tx.DB, model)
+	}
+	table := m.tableOf(model)
+	joined, other, err := types.ParseJoinOn(on, table)
+	if err != nil {
+		m.err = err
+		return m
+	}
+	m.joins = append(m.joins, join{
+		table: table,
+		model: model,
+		clause: fmt.Sprintf("%s `%s` ON `%s`.`%s` = `%s`.`%s`", kind, table,
+			table, joinColumn(model, joined.Field),
+			other.Table, joinColumn(m.joinModel(other.Table), other.Field)),
+	})
+	return m
+}
+
+// 在查询上追加连接
+func (m *Model) withJoins(query *gorm.DB) *gorm.DB {
+	for _, j := range m.joins {
+		query = query.Joins(j.clause)
+	}
+	return query
+}
+
+// 按表名找到本表或已连接的表对应的模型
+func (m *Model) joinModel(table string) any {
+	if table == m.tableName() {
+		return m.Data
+	}
+	for _, j := range m.joins {
+		if j.table == table {
+			return j.model
+		}
+	}
+	return nil
+}
+
+// 连接条件中的字段转换为列名 找不到时按原样使用
+func joinColumn(model any, name string) string {
+	if _, ok := model.(string); ok {
+		return name
+	}
+	if f := schema.Of(model).LookUp(name); f != nil && f.Column != "" {
+		return f.Column
+	}
+	return name
+}
+
+// 连接查询时为本表的列加上表名 避免与连接的表中同名的列产生歧义
+// 只处理本表中存在且没有写表名的列 其余条件保持不变
+func qualifyColumn(key, table string, columns map[string]bool) string {
+	mode, rest, ok := strings.Cut(key, " ")
+	if !ok {
+		return key
+	}
+	column, tail, _ := strings.Cut(rest, " ")
+	name := strings.Trim(column, "`")
+	if strings.Contains(name, ".") || !columns[name] {
+		return key
+	}
+	key = fmt.Sprintf("%s `%s`.`%s`", mode, table, name)
+	if tail != "" {
+		key += " " + tail
+	}
+	return key
+}
+
+// 本表的全部列名
+func (m *Model) tableColumns() map[string]bool {
+	columns := make(map[string]bool)
+	if _, ok := m.Data.(string); ok || m.Data == nil {
+		return columns
+	}
+	stmt := &gorm.Statement{DB: m.tx.getDB()}
+	if err := stmt.Parse(m.Data); err != nil {
+		return columns
+	}
+	for _, name := range stmt.Schema.DBNames {
+		columns[name] = true
+	}
+	return columns
+}
@@ -0,0 +1,130 @@
+package sqlorm
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/lfhy/morm/log"
+	"github.com/lfhy/morm/types"
+	"gorm.io/gorm"
+)
+
+// 注册中间件
+func (m *DBConn) Use(middlewares ...types.Middleware) {
+	m.middlewareLock.Lock()
+	defer m.middlewareLock.Unlock()
+	list := make([]types.Middleware, 0, len(m.middlewares)+len(middlewares))
+	list = append(list, m.middlewares...)
+	m.middlewares = append(list, middlewares...)
+}
+
+func (m *DBConn) getMiddlewares() []types.Middleware {
+	m.middlewareLock.RLock()
+	defer m.middlewareLock.RUnlock()
+	return m.middlewares
+}
+
+// 数据库类型 使用 gorm 方言名称
+func (m *DBConn) backend() types.DBType {
+	if m.DB == nil || m.Dialector == nil {
+		return ""
+	}
+	return types.DBType(m.Dialector.Name())
+}
+
+// 生成操作描述
+func (m *Model) operation(opType types.OpType) *types.Operation {
+	op := &types.Operation{
+		Type:    opType,
+		Backend: m.tx.backend(),
+		Table:   m.tableName(),
+	}
+	filter := make(map[string]any)
+	options := make(map[string]any)
+	m.OpList.Range(func(key string, value any) bool {
+		switch {
+		case strings.HasPrefix(key, "limit "), strings.HasPrefix(key, "offset "):
+			options[strings.TrimSpace(key)] = value
+		case isOptionKey(key):
+			options[key] = value
+		default:
+			filter[key] = value
+		}
+		return true
+	})
+	op.Filter = filter
+	op.Options = options
+	m.filter = filter
+	return op
+}
+
+func isOptionKey(key string) bool {
+	return strings.HasPrefix(key, "limit ") || strings.HasPrefix(key, "offset ") ||
+		strings.HasPrefix(key, "asc ") || strings.HasPrefix(key, "desc ")
+}
+
+// 中间件就地修改的条件写回 OpList 与 MongoDB 直接使用 WhereList 的行为一致
+// 条件的键与 OpList 相同 如 "where tenant_id = ?" 替换整个 Filter 不生效
+func (m *Model) syncFilter(op *types.Operation) {
+	filter, ok := op.Filter.(map[string]any)
+	if !ok || m.filter == nil || reflect.ValueOf(filter).UnsafePointer() != reflect.ValueOf(m.filter).UnsafePointer() {
+		return
+	}
+	var removed []string
+	m.OpList.Range(func(key string, value any) bool {
+		if _, ok := filter[key]; !ok && !isOptionKey(key) {
+			removed = append(removed, key)
+		}
+		return true
+	})
+	for _, key := range removed {
+		m.OpList.Delete(key)
+	}
+	for key, value := range filter {
+		m.OpList.Store(key, value)
+	}
+}
+
+// 经过中间件执行操作 并记录结构化日志
+// 执行期间模型使用中间件传入的上下文
+func (m *Model) invoke(op *types.Operation, fn func() error) error {
+	ctx := m.GetContext()
+	err := types.Invoke(ctx, op, m.tx.getMiddlewares(), func(ctx context.Context) error {
+		prev := m.Ctx
+		m.Ctx = ctx
+		defer func() {
+			m.Ctx = prev
+		}()
+		if m.err != nil {
+			return m.err
+		}
+		m.syncFilter(op)
+		return fn()
+	})
+	log.Operation(ctx, op, err)
+	return err
+}
+
+// 当前操作的表名
+func (m *Model) tableName() string {
+	if m.Table != "" {
+		return m.Table
+	}
+	return m.tableOf(m.Data)
+}
+
+// 模型对应的表名
+func (m *Model) tableOf(data any) string {
+	switch data.(type) {
+	case nil:
+		return ""
+	case string:
+		return data.(string)
+	}
+	stmt := &gorm.Statement{DB: m.tx.getDB()}
+	if err := stmt.Parse(data); err != nil {
+		return ""
+	}
+	return stmt.Schema.Table
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"sync"
 
+	"github.com/lfhy/morm/log"
 	"github.com/lfhy/morm/types"
 	"gorm.io/gorm"
 )
@@ -19,13 +20,18 @@ type Model struct {
 	upsertOp              sync.Map
 	Ctx                   context.Context //上下文
 	Table                 string
+	err                   error // 构造条件时的错误 在执行操作时返回
+	preloads              []preload
+	joins                 []join
+	filter                map[string]any // 最近一次操作描述中的条件 中间件修改后写回 OpList
 }
 
 func (m *Model) getDB() *gorm.DB {
+	db := m.tx.getDB()
 	if m.translatorDB != nil {
-		return m.translatorDB
+		db = m.translatorDB
 	}
-	return m.tx.getDB()
+	return db.WithContext(context.WithValue(m.GetContext(), modelCtxKey{}, m.tx))
 }
 
 var ORMConn *DBConn
@@ -49,9 +55,18 @@ func (m *DBConn) migrate(data any) {
 }
 
 func (m *DBConn) Model(data any) types.ORMModel {
+	m.hookOnce.Do(func() {
+		m.DB.Logger = filterHookWarn(m.DB.Logger)
+		registerHookCallbacks(m.DB)
+		registerEncryptCallbacks(m.DB)
+	})
+	// 应用 morm 标签后再迁移
+	applySchema(m.DB, data)
 	if m.AutoMigrate {
 		m.migrate(data)
 	}
+	// 记录敏感字段 日志中按列名脱敏
+	log.RegisterSensitive(data)
 	return &Model{Data: data, OpList: types.NewOrderedMap(), tx: m, upsertOp: sync.Map{}}
 }
 
@@ -71,6 +86,7 @@ type sqlSessionModel struct {
 
 // SwitchModel 返回绑定到当前事务的新 ORMModel，允许跨表操作。
 func (s *sqlSessionModel) SwitchModel(data any) types.ORMModel {
+	applySchema(s.tx.DB, data)
 	return &Model{
 		Data:         data,
 		OpList:       types.NewOrderedMap(),
@@ -80,7 +96,27 @@ func (s *sqlSessionModel) SwitchModel(data any) types.ORMModel {
 	}
 }
 
+// 事务
+// 事务函数返回 types.ErrStaleVersion 时会按 StaleRetry 配置重新执行整个事务
 func (m *Model) Session(transactionFunc func(types.Session) error) error {
+	op := m.operation(types.OpSession)
+	return m.invoke(op, func() (err error) {
+		for i := 0; ; i++ {
+			err = m.session(transactionFunc)
+			if !errors.Is(err, types.ErrStaleVersion) || i >= m.tx.StaleRetry {
+				return err
+			}
+			log.Debugf("乐观锁版本冲突 重试事务: %d\n", i+1)
+		}
+	})
+}
+
+func (m *Model) session(transactionFunc func(types.Session) error) error {
+	translatorDB := m.translatorDB
+	m.userControlTranslator = false
+	defer func() {
+		m.translatorDB = translatorDB
+	}()
 	err := m.tx.Transaction(func(tx *gorm.DB) error {
 		if m.translatorDB == nil {
 			m.translatorDB = tx
@@ -0,0 +1,167 @@
+package sqlorm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+
+	"github.com/lfhy/morm/types"
+	"gorm.io/gorm"
+	gschema "gorm.io/gorm/schema"
+)
+
+// 记录写语句但不执行的连接 查询语句照常执行
+type recordPool struct {
+	gorm.ConnPool
+	dialector  gorm.Dialector
+	statements []string
+}
+
+func (p *recordPool) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if len(args) > 0 {
+		query = p.dialector.Explain(query, args...)
+	}
+	// 记录连接本身实现了事务 gorm 会改用保存点 保存点语句不需要输出
+	upper := strings.ToUpper(strings.TrimSpace(query))
+	if strings.HasPrefix(upper, "SAVEPOINT") || strings.HasPrefix(upper, "RELEASE SAVEPOINT") || strings.HasPrefix(upper, "ROLLBACK TO SAVEPOINT") {
+		return driver.RowsAffected(0), nil
+	}
+	p.statements = append(p.statements, strings.TrimSpace(query))
+	return driver.RowsAffected(0), nil
+}
+
+// SQLite 修改列时会在事务中重建表 事务同样只记录不执行
+func (p *recordPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	return p, nil
+}
+
+func (p *recordPool) Commit() error { return nil }
+
+func (p *recordPool) Rollback() error { return nil }
+
+// 对比模型与数据库的表结构 返回差异与 AutoMigrate 会执行的 SQL 不修改数据库
+// 数据库中多出的列只报告 不会删除
+func (m *DBConn) Plan(ctx context.Context, models ...any) (*types.SchemaPlan, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	pool := &recordPool{ConnPool: m.DB.ConnPool, dialector: m.DB.Dialector}
+	// 传入 Context 时 Session 会复制 Statement 不影响原连接
+	db := m.DB.Session(&gorm.Session{NewDB: true, Context: ctx})
+	db.Statement.ConnPool = pool
+	migrator := db.Migrator()
+
+	plan := &types.SchemaPlan{}
+	for _, data := range models {
+		applySchema(m.DB, data)
+		stmt := &gorm.Statement{DB: m.DB}
+		if err := stmt.Parse(data); err != nil {
+			return nil, err
+		}
+		sch := stmt.Schema
+		if !migrator.HasTable(data) {
+			plan.Changes = append(plan.Changes, types.SchemaChange{Table: sch.Table, Kind: types.ChangeCreateTable})
+			if err := migrator.CreateTable(data); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		changes, err := planTable(migrator, pool, data, sch)
+		if err != nil {
+			return nil, err
+		}
+		plan.Changes = append(plan.Changes, changes...)
+	}
+	plan.Statements = pool.statements
+	return plan, nil
+}
+
+// 按 gorm AutoMigrate 的顺序对比已存在的表
+func planTable(migrator gorm.Migrator, pool *recordPool, data any, sch *gschema.Schema) ([]types.SchemaChange, error) {
+	columnTypes, err := migrator.ColumnTypes(data)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]gorm.ColumnType, len(columnTypes))
+	for _, ct := range columnTypes {
+		found[ct.Name()] = ct
+	}
+	var changes []types.SchemaChange
+	for _, dbName := range sch.DBNames {
+		field := sch.FieldsByDBName[dbName]
+		ct, ok := found[dbName]
+		if !ok {
+			changes = append(changes, types.SchemaChange{
+				Table:  sch.Table,
+				Kind:   types.ChangeAddColumn,
+				Name:   dbName,
+				Detail: modelType(migrator, field),
+			})
+			if err := migrator.AddColumn(data, dbName); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		// MigrateColumn 产生语句时说明类型 长度或可空性不一致
+		before := len(pool.statements)
+		if err := migrator.MigrateColumn(data, field, ct); err != nil {
+			return nil, err
+		}
+		if len(pool.statements) > before {
+			changes = append(changes, types.SchemaChange{
+				Table:  sch.Table,
+				Kind:   types.ChangeAlterColumn,
+				Name:   dbName,
+				Detail: fmt.Sprintf("%s -> %s", columnType(ct), modelType(migrator, field)),
+			})
+		}
+	}
+	for _, ct := range columnTypes {
+		if _, ok := sch.FieldsByDBName[ct.Name()]; !ok {
+			changes = append(changes, types.SchemaChange{
+				Table:  sch.Table,
+				Kind:   types.ChangeExtraColumn,
+				Name:   ct.Name(),
+				Detail: columnType(ct),
+			})
+		}
+	}
+	for _, idx := range sch.ParseIndexes() {
+		if migrator.HasIndex(data, idx.Name) {
+			continue
+		}
+		fields := make([]string, len(idx.Fields))
+		for i, f := range idx.Fields {
+			fields[i] = f.DBName
+		}
+		changes = append(changes, types.SchemaChange{
+			Table:  sch.Table,
+			Kind:   types.ChangeCreateIndex,
+			Name:   idx.Name,
+			Detail: strings.TrimSpace(idx.Class + " (" + strings.Join(fields, ", ") + ")"),
+		})
+		if err := migrator.CreateIndex(data, idx.Name); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+// 模型字段的完整类型 如 varchar(255) NOT NULL
+func modelType(migrator gorm.Migrator, field *gschema.Field) string {
+	return strings.TrimSpace(migrator.FullDataTypeOf(field).SQL)
+}
+
+// 数据库列的类型与可空性
+func columnType(ct gorm.ColumnType) string {
+	typ, ok := ct.ColumnType()
+	if !ok || typ == "" {
+		typ = ct.DatabaseTypeName()
+	}
+	if nullable, ok := ct.Nullable(); ok && !nullable {
+		typ += " NOT NULL"
+	}
+	return strings.ToLower(typ)
+}
@@ -0,0 +1,106 @@
+package sqlorm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/lfhy/morm/types"
+	"gorm.io/gorm"
+)
+
+// 预加载的关联
+type preload struct {
+	field  string
+	scopes []types.PreloadScope
+}
+
+// 预加载关联数据 由 gorm 按关联标签查询并填充
+func (m *Model) Preload(field string, scopes ...types.PreloadScope) types.ORMModel {
+	if m.Data != nil {
+		if _, ok := m.Data.(string); !ok {
+			related, ok := relatedTypes(reflect.TypeOf(m.Data), field)
+			if !ok {
+				m.err = fmt.Errorf("%w: relation %s in %T", types.ErrUnknownField, field, m.Data)
+				return m
+			}
+			// 关联模型同样需要应用 morm 标签
+			for _, typ := range related {
+				applySchema(m.tx.DB, reflect.New(typ).Interface())
+			}
+		}
+	}
+	m.preloads = append(m.preloads, preload{field: field, scopes: scopes})
+	return m
+}
+
+// 在查询上追加预加载
+func (m *Model) withPreloads(query *gorm.DB) *gorm.DB {
+	for _, p := range m.preloads {
+		if len(p.scopes) == 0 {
+			query = query.Preload(p.field)
+			continue
+		}
+		query = query.Preload(p.field, m.preloadScope(p.scopes))
+	}
+	return query
+}
+
+// 将 scopes 转换为 gorm 的条件函数 scopes 中的条件按关联模型解析
+func (m *Model) preloadScope(scopes []types.PreloadScope) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		sub := &Model{
+			Data:         relatedData(db.Statement.Model),
+			OpList:       types.NewOrderedMap(),
+			tx:           m.tx,
+			translatorDB: m.translatorDB,
+			Ctx:          m.Ctx,
+		}
+		for _, scope := range scopes {
+			scope(sub)
+		}
+		if sub.err != nil {
+			db.AddError(sub.err)
+			return db
+		}
+		return sub.withPreloads(sub.applyOpList(db))
+	}
+}
+
+// gorm 预加载时的模型为关联结构体的切片 取出单个结构体
+func relatedData(model any) any {
+	typ := reflect.TypeOf(model)
+	for typ != nil && (typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice) {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return model
+	}
+	return reflect.New(typ).Interface()
+}
+
+// 按字段路径依次找到关联的结构体类型 字段不存在或不是结构体时返回 false
+func relatedTypes(typ reflect.Type, path string) ([]reflect.Type, bool) {
+	var result []reflect.Type
+	for _, name := range strings.Split(path, ".") {
+		for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct {
+			return nil, false
+		}
+		f, ok := typ.FieldByName(name)
+		if !ok {
+			return nil, false
+		}
+		typ = f.Type
+		for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct {
+			return nil, false
+		}
+		result = append(result, typ)
+	}
+	return result, true
+}
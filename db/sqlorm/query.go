@@ -2,6 +2,7 @@ package sqlorm
 
 import (
 	"database/sql"
+	"reflect"
 
 	"github.com/lfhy/morm/log"
 
@@ -15,35 +16,61 @@ type Query struct {
 }
 
 func (q *Query) One(data any) error {
-	return q.m.makeQuery().First(data).Error
+	op := q.m.operation(types.OpFindOne)
+	return q.m.invoke(op, func() error {
+		tx := q.m.withPreloads(q.m.withJoins(q.m.makeQuery())).First(data)
+		op.Rows = tx.RowsAffected
+		return tx.Error
+	})
 }
 
 func (q *Query) All(data any) error {
-	return q.m.makeQuery().Find(data).Error
+	op := q.m.operation(types.OpFindAll)
+	return q.m.invoke(op, func() error {
+		tx := q.m.withPreloads(q.m.withJoins(q.m.makeQuery())).Find(data)
+		op.Rows = tx.RowsAffected
+		return tx.Error
+	})
 }
 
 func (q *Query) Count() int64 {
 	var i int64
-	q.m.makeQuery().Count(&i)
+	op := q.m.operation(types.OpCount)
+	q.m.invoke(op, func() error {
+		tx := q.m.withJoins(q.m.makeQuery()).Count(&i)
+		op.Rows = i
+		return tx.Error
+	})
 	return i
 }
 
 func (q *Query) Delete() error {
-	return q.m.makeQuery().Delete(q.m.Data).Error
+	op := q.m.operation(types.OpDelete)
+	return q.m.invoke(op, func() error {
+		tx := q.m.makeQuery().Delete(q.m.Data)
+		op.Rows = tx.RowsAffected
+		return tx.Error
+	})
 }
 
 // gorm不支持游标，使用原始SQL实现
 func (q *Query) Cursor() (types.Cursor, error) {
-	rows, err := q.m.makeQuery().Rows()
+	var rows *sql.Rows
+	op := q.m.operation(types.OpCursor)
+	err := q.m.invoke(op, func() (err error) {
+		rows, err = q.m.withJoins(q.m.makeQuery()).Rows()
+		return err
+	})
 	if err != nil {
 		log.Errorf("Mysql查出错: %v\n", err)
 		return nil, err
 	}
-	return &Cursor{Rows: rows, db: q.m.getDB()}, nil
+	return &Cursor{Rows: rows, db: q.m.getDB(), m: q.m}, nil
 }
 
 type Cursor struct {
 	db *gorm.DB
+	m  *Model
 	*sql.Rows
 }
 
@@ -51,6 +78,20 @@ func (c *Cursor) Decode(v any) error {
 	err := c.db.ScanRows(c.Rows, v)
 	if err != nil {
 		log.Errorf("Mysql游标解码出错: %v\n", err)
+		return err
 	}
-	return err
+	// 需要预加载时按主键重新查询 由 gorm 填充关联并经过查询回调
+	// 游标占用一个连接 预加载需要连接池中的另一个连接
+	if len(c.m.preloads) > 0 && reflect.Indirect(reflect.ValueOf(v)).Kind() == reflect.Struct {
+		err = c.m.withPreloads(c.db).Take(v).Error
+		if err != nil {
+			log.Errorf("Mysql游标预加载出错: %v\n", err)
+		}
+		return err
+	}
+	// ScanRows 不会经过 gorm 的查询回调 需要手动解密并调用 AfterFind
+	if err := c.m.decrypt(v); err != nil {
+		return err
+	}
+	return c.m.afterFind(v)
 }
@@ -0,0 +1,92 @@
+package sqlorm
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lfhy/morm/log"
+	"github.com/lfhy/morm/types"
+)
+
+// 原始 SQL 查询
+type RawQuery struct {
+	m     *Model
+	query string
+	args  []any
+	err   error
+}
+
+// 原始查询 结果经过 gorm 的查询回调 与普通查询一样解密并调用 AfterFind
+func (m *Model) Raw(query string, args ...any) types.RawQuery {
+	return &RawQuery{m: m, query: query, args: args}
+}
+
+// SQL 后端不支持聚合管道
+func (m *Model) Pipeline(stages any) types.RawQuery {
+	return &RawQuery{m: m, err: fmt.Errorf("%w: Pipeline on %s", types.ErrNotSupported, m.tx.backend())}
+}
+
+// 原始查询的操作描述 条件为语句与参数
+func (q *RawQuery) operation(opType types.OpType) *types.Operation {
+	op := q.m.operation(opType)
+	op.Filter = map[string]any{q.query: q.args}
+	op.Options = nil
+	return op
+}
+
+// 原始查询不使用当前条件 也不返回构造条件时的错误
+func (q *RawQuery) invoke(op *types.Operation, fn func() error) error {
+	prev := q.m.err
+	q.m.err = nil
+	defer func() { q.m.err = prev }()
+	return q.m.invoke(op, func() error {
+		if q.err != nil {
+			return q.err
+		}
+		return fn()
+	})
+}
+
+// 查询一条数据 没有数据时返回 gorm.ErrRecordNotFound
+func (q *RawQuery) One(data any) error {
+	op := q.operation(types.OpFindOne)
+	return q.invoke(op, func() error {
+		tx := q.m.getDB().Raw(q.query, q.args...).Take(data)
+		op.Rows = tx.RowsAffected
+		return tx.Error
+	})
+}
+
+func (q *RawQuery) All(data any) error {
+	op := q.operation(types.OpFindAll)
+	return q.invoke(op, func() error {
+		tx := q.m.getDB().Raw(q.query, q.args...).Find(data)
+		op.Rows = tx.RowsAffected
+		return tx.Error
+	})
+}
+
+func (q *RawQuery) Cursor() (types.Cursor, error) {
+	var rows *sql.Rows
+	op := q.operation(types.OpCursor)
+	err := q.invoke(op, func() (err error) {
+		rows, err = q.m.getDB().Raw(q.query, q.args...).Rows()
+		return err
+	})
+	if err != nil {
+		log.Errorf("Mysql查出错: %v\n", err)
+		return nil, err
+	}
+	return &Cursor{Rows: rows, db: q.m.getDB(), m: q.m}, nil
+}
+
+// 执行写入 返回影响的行数
+func (q *RawQuery) Exec() (int64, error) {
+	op := q.operation(types.OpExec)
+	err := q.invoke(op, func() error {
+		tx := q.m.getDB().Exec(q.query, q.args...)
+		op.Rows = tx.RowsAffected
+		return tx.Error
+	})
+	return op.Rows, err
+}
@@ -0,0 +1,148 @@
+package sqlorm
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/lfhy/morm/schema"
+	"gorm.io/gorm"
+	gschema "gorm.io/gorm/schema"
+)
+
+// 已应用 morm 标签的 gorm 模型
+var appliedSchemas sync.Map
+
+var applyLock sync.Mutex
+
+// gorm 按连接配置缓存模型
+type schemaKey struct {
+	config *gorm.Config
+	typ    reflect.Type
+}
+
+// 将 morm 标签中的列名 主键与索引应用到 gorm 解析的模型上
+// gorm 只识别 gorm 标签 需要在模型第一次使用前调用
+func applySchema(db *gorm.DB, data any) {
+	if data == nil {
+		return
+	}
+	if _, ok := data.(string); ok {
+		return
+	}
+	key := schemaKey{config: db.Config, typ: reflect.TypeOf(data)}
+	if _, ok := appliedSchemas.Load(key); ok {
+		return
+	}
+	applyLock.Lock()
+	defer applyLock.Unlock()
+	if _, ok := appliedSchemas.Load(key); ok {
+		return
+	}
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(data); err != nil || stmt.Schema == nil {
+		return
+	}
+	sch := stmt.Schema
+	defer appliedSchemas.Store(key, true)
+
+	var mormPK []*gschema.Field
+	for _, field := range sch.Fields {
+		info := schema.ParseField(field.StructField)
+		if !info.Declared {
+			continue
+		}
+		if column := info.Options["column"]; column != "" && column != field.DBName {
+			renameField(sch, field, column)
+		}
+		if info.Has("pk") {
+			mormPK = append(mormPK, field)
+		}
+		// gorm 从原始标签中解析索引
+		if name := info.Options["unique"]; name != "" {
+			field.TagSettings["UNIQUEINDEX"] = name
+			field.Tag = setGormTag(field.Tag, "uniqueIndex:"+name)
+		} else if info.Has("unique") {
+			field.Unique = true
+			field.TagSettings["UNIQUE"] = "UNIQUE"
+		}
+		if name := info.Options["index"]; name != "" {
+			field.TagSettings["INDEX"] = name
+			field.Tag = setGormTag(field.Tag, "index:"+name)
+		} else if info.Has("index") {
+			field.TagSettings["INDEX"] = "INDEX"
+			field.Tag = setGormTag(field.Tag, "index")
+		}
+	}
+	if len(mormPK) > 0 {
+		setPrimaryFields(sch, mormPK)
+	}
+}
+
+// 修改字段的列名
+func renameField(sch *gschema.Schema, field *gschema.Field, column string) {
+	if sch.FieldsByDBName[field.DBName] == field {
+		delete(sch.FieldsByDBName, field.DBName)
+		for i, name := range sch.DBNames {
+			if name == field.DBName {
+				sch.DBNames = append(sch.DBNames[:i:i], sch.DBNames[i+1:]...)
+				break
+			}
+		}
+	}
+	field.DBName = column
+	field.TagSettings["COLUMN"] = column
+	if _, ok := sch.FieldsByDBName[column]; !ok {
+		sch.DBNames = append(sch.DBNames, column)
+	}
+	sch.FieldsByDBName[column] = field
+}
+
+// 使用 morm 标签声明的主键 替换 gorm 按字段名推断的主键
+func setPrimaryFields(sch *gschema.Schema, pks []*gschema.Field) {
+	primary := pks
+	for _, field := range sch.PrimaryFields {
+		_, pk := field.TagSettings["PRIMARYKEY"]
+		_, pk2 := field.TagSettings["PRIMARY_KEY"]
+		if (pk || pk2) && !containsField(primary, field) {
+			primary = append(primary, field)
+			continue
+		}
+		if !containsField(primary, field) {
+			field.PrimaryKey = false
+		}
+	}
+	sch.PrimaryFields = primary
+	sch.PrimaryFieldDBNames = sch.PrimaryFieldDBNames[:0]
+	for _, field := range primary {
+		field.PrimaryKey = true
+		field.TagSettings["PRIMARYKEY"] = "PRIMARYKEY"
+		sch.PrimaryFieldDBNames = append(sch.PrimaryFieldDBNames, field.DBName)
+	}
+	sch.PrioritizedPrimaryField = nil
+	if len(primary) == 1 {
+		sch.PrioritizedPrimaryField = primary[0]
+	}
+}
+
+func containsField(fields []*gschema.Field, field *gschema.Field) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// 在字段的 gorm 标签中追加选项
+func setGormTag(tag reflect.StructTag, option string) reflect.StructTag {
+	value, ok := tag.Lookup("gorm")
+	if !ok {
+		return reflect.StructTag(strings.TrimSpace(string(tag) + ` gorm:"` + option + `"`))
+	}
+	old := `gorm:"` + value + `"`
+	if value != "" {
+		value += ";"
+	}
+	return reflect.StructTag(strings.Replace(string(tag), old, `gorm:"`+value+option+`"`, 1))
+}
@@ -5,14 +5,21 @@ import (
 	"reflect"
 	"sync"
 
+	"github.com/lfhy/morm/types"
 	"gorm.io/gorm"
 )
 
 type DBConn struct {
 	*gorm.DB
 	AutoMigrate bool
+	// 乐观锁版本冲突时事务的重试次数
+	StaleRetry  int
 	migrateLock sync.RWMutex
 	migrateMap  map[string]bool
+	hookOnce    sync.Once
+	// 中间件
+	middlewares    []types.Middleware
+	middlewareLock sync.RWMutex
 }
 
 func (m *DBConn) getDB() *gorm.DB {
@@ -0,0 +1,145 @@
+package sqlorm
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/lfhy/morm/schema"
+	"github.com/lfhy/morm/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	gschema "gorm.io/gorm/schema"
+)
+
+// 查找乐观锁版本字段
+// 结构体字段带有 morm:"version" 标签时视为版本字段 返回字段名
+func versionFieldName(typ reflect.Type) string {
+	sch := schema.Parse(typ)
+	if sch == nil {
+		return ""
+	}
+	for _, f := range sch.Flat {
+		if f.Has("version") {
+			return f.Name
+		}
+	}
+	return ""
+}
+
+// 获取版本字段对应的 gorm 字段
+func (m *Model) versionField(data any) (*gschema.Field, *gschema.Schema) {
+	if data == nil {
+		return nil, nil
+	}
+	typ := reflect.TypeOf(data)
+	name := versionFieldName(typ)
+	if name == "" {
+		return nil, nil
+	}
+	stmt := &gorm.Statement{DB: m.getDB()}
+	if err := stmt.Parse(data); err != nil {
+		return nil, nil
+	}
+	return stmt.Schema.LookUpField(name), stmt.Schema
+}
+
+// 读取版本号
+func versionValue(field *gschema.Field, data any) int64 {
+	rv := reflect.ValueOf(data)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return 0
+		}
+		rv = rv.Elem()
+	}
+	v, _ := field.ValueOf(context.Background(), rv)
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int8:
+		return int64(n)
+	case int16:
+		return int64(n)
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case uint:
+		return int64(n)
+	case uint8:
+		return int64(n)
+	case uint16:
+		return int64(n)
+	case uint32:
+		return int64(n)
+	case uint64:
+		return int64(n)
+	}
+	return 0
+}
+
+// 回写版本号 data 必须是结构体指针
+func setVersionValue(field *gschema.Field, data any, version int64) {
+	rv := reflect.ValueOf(data)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return
+	}
+	field.Set(context.Background(), rv.Elem(), version)
+}
+
+// 插入前初始化版本号为 1
+func (m *Model) initVersion(data any) {
+	field, _ := m.versionField(data)
+	if field == nil || versionValue(field, data) != 0 {
+		return
+	}
+	setVersionValue(field, data, 1)
+}
+
+// 带乐观锁的更新
+// 生成 UPDATE ... SET ..., version = version + 1 WHERE ... AND version = ?
+// 版本号为零值时不做校验 只自增版本号
+// 传入 columns 时只更新这些列 零值同样写入
+func (m *Model) updateWithVersion(op *types.Operation, field *gschema.Field, sch *gschema.Schema, columns ...string) error {
+	rv := reflect.ValueOf(m.Data)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	ctx := context.Background()
+	values := make(map[string]any)
+	for _, f := range sch.Fields {
+		if f.DBName == "" || f.PrimaryKey || !f.Updatable || f == field {
+			continue
+		}
+		v, zero := f.ValueOf(ctx, rv)
+		if len(columns) > 0 {
+			if !containsColumn(columns, f.DBName) {
+				continue
+			}
+		} else if zero {
+			continue
+		}
+		values[f.DBName] = v
+	}
+	column := clause.Column{Name: field.DBName}
+	values[field.DBName] = gorm.Expr("? + 1", column)
+
+	current := versionValue(field, m.Data)
+	query := m.makeQuery()
+	if current != 0 {
+		query = query.Where(clause.Eq{Column: column, Value: current})
+	}
+	tx := query.Updates(values)
+	op.Rows = tx.RowsAffected
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if current == 0 {
+		return nil
+	}
+	if tx.RowsAffected == 0 {
+		return types.ErrStaleVersion
+	}
+	setVersionValue(field, m.Data, current+1)
+	return nil
+}
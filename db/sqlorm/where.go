@@ -6,24 +6,62 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/lfhy/morm/schema"
 	"github.com/lfhy/morm/types"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // 限制条件
 func (m *Model) Where(condition any, value ...any) types.ORMModel {
+	if _, ok := condition.(types.Condition); ok {
+		return m.whereConditions(append([]any{condition}, value...)...)
+	}
 	if len(value) > 0 {
 		key, ok := condition.(string)
 		if ok {
-			m.OpList.Store(fmt.Sprintf("where %s = ?", key), value[0])
-			m.upsertOp.Store(fmt.Sprintf("where %s = ?", key), value[0])
+			v := m.whereValue(key, value[0])
+			m.OpList.Store(fmt.Sprintf("where %s = ?", key), v)
+			m.upsertOp.Store(fmt.Sprintf("where %s = ?", key), v)
 			return m
 		}
 	}
 	return m.whereMode(condition, types.WhereIs)
 }
 
+// 按字段名选择条件 零值同样作为条件
+// nil 指针匹配 NULL
+func (m *Model) WhereFields(data any, fields ...string) types.ORMModel {
+	sch := schema.Of(data)
+	if sch == nil {
+		m.err = fmt.Errorf("%w: %s in %T", types.ErrUnknownField, strings.Join(fields, ","), data)
+		return m
+	}
+	t := reflect.ValueOf(data)
+	if t.Kind() == reflect.Pointer {
+		if t.IsNil() {
+			t = reflect.New(t.Type().Elem())
+		}
+		t = t.Elem()
+	}
+	for _, name := range fields {
+		f := sch.LookUp(name)
+		if f == nil || f.Column == "" {
+			m.err = fmt.Errorf("%w: %s in %s", types.ErrUnknownField, name, t.Type())
+			return m
+		}
+		field := t.FieldByIndex(f.Index)
+		if field.Kind() == reflect.Pointer && field.IsNil() {
+			m.OpList.Store(fmt.Sprintf("where `%s` IS ?", f.Column), clause.Expr{SQL: "NULL"})
+			m.upsertOp.Store(f.Column, nil)
+			continue
+		}
+		m.saveOplist(types.WhereIs, f.Column, reflect.Indirect(field).Interface())
+	}
+	return m
+}
+
 func (m *Model) WhereIs(key string, value any) types.ORMModel {
 	m.OpList.Store(key, value)
 	return m
@@ -44,7 +82,7 @@ func (m *Model) WhereNot(condition any, value ...any) types.ORMModel {
 	if len(value) > 0 {
 		key, ok := condition.(string)
 		if ok {
-			m.OpList.Store(fmt.Sprintf("not %s = ?", key), value[0])
+			m.OpList.Store(fmt.Sprintf("not %s = ?", key), m.whereValue(key, value[0]))
 			return m
 		}
 	}
@@ -99,7 +137,7 @@ func (m *Model) WhereOr(condition any, value ...any) types.ORMModel {
 	if len(value) > 0 {
 		key, ok := condition.(string)
 		if ok {
-			m.OpList.Store(fmt.Sprintf("or %s = ?", key), value[0])
+			m.OpList.Store(fmt.Sprintf("or %s = ?", key), m.whereValue(key, value[0]))
 			return m
 		}
 	}
@@ -150,46 +188,23 @@ func (m *Model) whereMode(condition any, mode types.WhereMode) types.ORMModel {
 	}
 	switch t.Kind() {
 	case reflect.Struct:
-		// 处理结构体的字段，包括嵌套的匿名结构体
-		var processStructFields func(reflect.Value, reflect.Type)
-		processStructFields = func(val reflect.Value, typ reflect.Type) {
-		l1:
-			for i := 0; i < val.NumField(); i++ {
-				field := val.Field(i)
-				fieldType := typ.Field(i)
-
-				// 如果是嵌套的匿名结构体，递归处理
-				if field.Kind() == reflect.Struct && fieldType.Anonymous {
-					processStructFields(field, fieldType.Type)
-					continue
-				}
-
-				if field.IsZero() {
-					continue
-				}
-
-				v, ok := fieldType.Tag.Lookup("gorm")
-				if ok {
-					for _, v2 := range strings.Split(v, ";") {
-						if strings.HasPrefix(v2, "column:") {
-							column := strings.TrimPrefix(v2, "column:")
-							value := field.Interface()
-							m.saveOplist(mode, column, value)
-							continue l1
-						} else if !strings.Contains(v2, ":") {
-							// 直接输入字段信息的情况
-							column := v2
-							value := field.Interface()
-							m.saveOplist(mode, column, value)
-							continue l1
-						}
-					}
+		// 按缓存的字段信息处理 包括嵌套的匿名结构体
+		for _, f := range schema.Parse(t.Type()).Flat {
+			if f.Column == "" {
+				continue
+			}
+			field := t.FieldByIndex(f.Index)
+			if mode == types.OrderAsc || mode == types.OrderDesc {
+				// 排序只看有值的字段
+				if !field.IsZero() {
+					m.saveOplist(mode, f.Column, "")
 				}
+				continue
+			}
+			if value, ok := f.Condition(field); ok {
+				m.saveOplist(mode, f.Column, value)
 			}
 		}
-
-		// 开始处理当前结构体的字段
-		processStructFields(t, t.Type())
 	case reflect.Map:
 		// 遍历map
 		for _, k := range t.MapKeys() {
@@ -203,8 +218,20 @@ func (m *Model) whereMode(condition any, mode types.WhereMode) types.ORMModel {
 
 // 自动生成查询条件
 func (m *Model) makeQuery() *gorm.DB {
-	query := m.getDB().Model(m.Data)
+	return m.applyOpList(m.getDB().Model(m.Data))
+}
+
+// 将条件 排序与分页应用到查询上
+func (m *Model) applyOpList(query *gorm.DB) *gorm.DB {
+	var table string
+	var columns map[string]bool
+	if len(m.joins) > 0 {
+		table, columns = m.tableName(), m.tableColumns()
+	}
 	m.OpList.Range(func(key string, value any) bool {
+		if table != "" {
+			key = qualifyColumn(key, table, columns)
+		}
 		if strings.HasPrefix(key, "where ") {
 			query = query.Where(strings.TrimPrefix(key, "where "), value)
 			return true
@@ -250,40 +277,10 @@ func (m *Model) getID(condition any) (id string) {
 	if t.Kind() == reflect.Slice {
 		t = t.Elem()
 	}
-	switch t.Kind() {
-	case reflect.Struct:
-		for i := 0; i < t.NumField(); i++ {
-			field := t.Field(i)
-			fieldType := t.Type().Field(i)
-
-			// 如果是嵌套结构体(匿名字段)，递归处理
-			if field.Kind() == reflect.Struct && fieldType.Anonymous {
-				if !field.IsZero() {
-					nestedValue := field.Interface()
-					if nestedID := m.getID(nestedValue); nestedID != "" {
-						return nestedID
-					}
-				} else {
-					// 即使嵌套结构体为空，也要检查其类型定义
-					nestedStruct := reflect.New(field.Type()).Elem().Interface()
-					if nestedID := m.getID(nestedStruct); nestedID != "" {
-						// 但由于值为空，实际上没有可用的ID
-						continue
-					}
-				}
-				continue
-			}
-
-			if field.IsZero() {
-				continue
-			}
-			v, ok := fieldType.Tag.Lookup("gorm")
-			if ok {
-				for _, v2 := range strings.Split(v, ";") {
-					if strings.HasPrefix(v2, "id") || strings.HasPrefix(v2, "column:id") || strings.HasPrefix(v2, "primaryKey") {
-						return fmt.Sprint(field.Interface())
-					}
-				}
+	if t.Kind() == reflect.Struct {
+		for _, f := range schema.Parse(t.Type()).PrimaryFields {
+			if field := t.FieldByIndex(f.Index); !field.IsZero() {
+				return fmt.Sprint(field.Interface())
 			}
 		}
 	}
@@ -291,6 +288,10 @@ func (m *Model) getID(condition any) (id string) {
 }
 
 func (m *Model) saveOplist(mode types.WhereMode, column string, value any) {
+	switch mode {
+	case types.WhereIs, types.WhereNot, types.WhereOr:
+		value = m.whereValue(column, value)
+	}
 	switch mode {
 	case types.WhereIs:
 		m.upsertOp.Store(column, value)
@@ -320,6 +321,9 @@ func (m *Model) ResetFilter() types.ORMModel {
 	m.OpList = types.NewOrderedMap()
 	m.upsertOp = sync.Map{}
 	m.Data = nil
+	m.err = nil
+	m.preloads = nil
+	m.joins = nil
 	return m
 }
 
@@ -0,0 +1,205 @@
+// 字段级加密
+//
+// 结构体字段带有 morm:"encrypt" 标签时 写入数据库前使用 AES-GCM 加密 读取后自动解密
+// 带有 morm:"encrypt;deterministic" 标签时相同明文得到相同密文 可以用于等值查询
+//
+//	type User struct {
+//		ID    int    `gorm:"column:id;primaryKey" bson:"_id,omitempty"`
+//		Phone string `gorm:"column:phone" bson:"phone" morm:"encrypt"`
+//		Email string `gorm:"column:email" bson:"email" morm:"encrypt;deterministic"`
+//	}
+//
+//	encrypt.SetKeyProvider(&encrypt.StaticKeys{Current: "k1", Keys: map[string][]byte{"k1": key}})
+//	orm.Model(&User{}).Where("email", "foo@bar.com").One(&user)
+//
+// 密文中记录了密钥 ID 轮换密钥时把新密钥设为 Current 并保留旧密钥即可读取旧数据
+// 确定性加密的查询只使用当前密钥 旧密钥加密的数据需要重新保存后才能被查询到
+// AES-GCM 的密钥与确定性加密 nonce 的 HMAC 密钥均由 HKDF 从配置的密钥派生 互不相同
+package encrypt
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// 密文前缀
+const prefix = "enc:v1:"
+
+var (
+	ErrNoKeyProvider     = errors.New("encrypt: key provider not set")
+	ErrKeyNotFound       = errors.New("encrypt: key not found")
+	ErrInvalidCiphertext = errors.New("encrypt: invalid ciphertext")
+)
+
+// 密钥提供者
+type KeyProvider interface {
+	// 当前用于加密的密钥及其 ID
+	CurrentKey(ctx context.Context) (id string, key []byte, err error)
+	// 按 ID 获取解密用的密钥
+	Key(ctx context.Context, id string) ([]byte, error)
+}
+
+// 固定密钥 密钥长度需为 16 24 或 32 字节
+type StaticKeys struct {
+	// 当前加密使用的密钥 ID
+	Current string
+	// 全部密钥 包含轮换前的旧密钥
+	Keys map[string][]byte
+}
+
+func (s *StaticKeys) CurrentKey(ctx context.Context) (string, []byte, error) {
+	key, err := s.Key(ctx, s.Current)
+	return s.Current, key, err
+}
+
+func (s *StaticKeys) Key(ctx context.Context, id string) ([]byte, error) {
+	key, ok := s.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, id)
+	}
+	return key, nil
+}
+
+var keyProvider atomic.Pointer[KeyProvider]
+
+// 设置密钥提供者
+func SetKeyProvider(p KeyProvider) {
+	if p == nil {
+		keyProvider.Store(nil)
+		return
+	}
+	keyProvider.Store(&p)
+}
+
+// 获取密钥提供者 未设置时返回 nil
+func GetKeyProvider() KeyProvider {
+	if p := keyProvider.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+// 加密字符串 deterministic 为 true 时相同明文与密钥得到相同密文
+func Encrypt(ctx context.Context, plaintext string, deterministic bool) (string, error) {
+	p := GetKeyProvider()
+	if p == nil {
+		return "", ErrNoKeyProvider
+	}
+	id, key, err := p.CurrentKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(id, ":") {
+		return "", fmt.Errorf("encrypt: key id %q must not contain ':'", id)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if deterministic {
+		// 使用明文的 HMAC 作为 nonce HMAC 与 AES-GCM 使用不同的子密钥
+		macKey, err := deriveKey(key, macInfo, sha256.Size)
+		if err != nil {
+			return "", err
+		}
+		mac := hmac.New(sha256.New, macKey)
+		mac.Write([]byte(plaintext))
+		copy(nonce, mac.Sum(nil))
+	} else if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(id))
+	return prefix + id + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// 解密字符串 不是密文时原样返回 以兼容加密前写入的数据
+func Decrypt(ctx context.Context, s string) (string, error) {
+	if !IsEncrypted(s) {
+		return s, nil
+	}
+	id, data, ok := strings.Cut(strings.TrimPrefix(s, prefix), ":")
+	if !ok {
+		return "", ErrInvalidCiphertext
+	}
+	p := GetKeyProvider()
+	if p == nil {
+		return "", ErrNoKeyProvider
+	}
+	key, err := p.Key(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(data)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+	nonce, ct := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ct, []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidCiphertext, err)
+	}
+	return string(plain), nil
+}
+
+// 是否为 Encrypt 生成的密文
+func IsEncrypted(s string) bool {
+	return strings.HasPrefix(s, prefix)
+}
+
+// 密文使用的密钥 ID
+func KeyID(s string) string {
+	if !IsEncrypted(s) {
+		return ""
+	}
+	id, _, _ := strings.Cut(strings.TrimPrefix(s, prefix), ":")
+	return id
+}
+
+// HKDF 派生子密钥使用的 info
+const (
+	encInfo = "morm encrypt aes-gcm"
+	macInfo = "morm encrypt nonce hmac"
+)
+
+// 使用 HKDF-SHA256 从主密钥派生子密钥
+func deriveKey(key []byte, info string, size int) ([]byte, error) {
+	sub := make([]byte, size)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, nil, []byte(info)), sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// AES-GCM 使用派生的加密子密钥 长度与主密钥相同
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	// 先检查主密钥长度 派生后的子密钥长度不再能反映配置错误
+	if _, err := aes.NewCipher(key); err != nil {
+		return nil, err
+	}
+	encKey, err := deriveKey(key, encInfo, len(key))
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
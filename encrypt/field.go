@@ -0,0 +1,304 @@
+package encrypt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/lfhy/morm/schema"
+	gschema "gorm.io/gorm/schema"
+)
+
+var ErrNotDeterministic = errors.New("encrypt: field is not deterministic")
+
+// 加密字段
+type Field struct {
+	// 结构体中的字段下标 包含匿名嵌套结构体
+	Index []int
+	// 结构体字段名
+	Name string
+	// gorm 列名
+	Column string
+	// bson 字段名
+	BSON string
+	// 是否为确定性加密
+	Deterministic bool
+}
+
+type typeFields struct {
+	fields []Field
+	err    error
+}
+
+// 已解析过的类型
+var fieldCache sync.Map
+
+// 解析类型中带有 morm:"encrypt" 标签的字段 只支持 string 与 *string
+func Fields(typ reflect.Type) ([]Field, error) {
+	typ = structType(typ)
+	if typ == nil {
+		return nil, nil
+	}
+	if v, ok := fieldCache.Load(typ); ok {
+		tf := v.(*typeFields)
+		return tf.fields, tf.err
+	}
+	tf := &typeFields{}
+	tf.err = parseFields(typ, nil, &tf.fields)
+	fieldCache.Store(typ, tf)
+	return tf.fields, tf.err
+}
+
+func parseFields(typ reflect.Type, index []int, fields *[]Field) error {
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		idx := append(append([]int(nil), index...), i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			if err := parseFields(sf.Type, idx, fields); err != nil {
+				return err
+			}
+			continue
+		}
+		info := schema.ParseField(sf)
+		if !info.Has("encrypt") {
+			continue
+		}
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() != reflect.String {
+			return fmt.Errorf("encrypt: field %s.%s must be string or *string", typ.Name(), sf.Name)
+		}
+		*fields = append(*fields, Field{
+			Index:         idx,
+			Name:          sf.Name,
+			Column:        columnName(info),
+			BSON:          bsonName(info),
+			Deterministic: info.Has("deterministic"),
+		})
+	}
+	return nil
+}
+
+// 按列名 bson 名或字段名查找加密字段 不区分大小写
+// 名称可以带有表名前缀与反引号 如 `users`.`email`
+func Lookup(typ reflect.Type, name string) (*Field, error) {
+	fields, err := Fields(typ)
+	if err != nil || len(fields) == 0 {
+		return nil, err
+	}
+	name, _, _ = strings.Cut(name, ",")
+	name = strings.ReplaceAll(strings.TrimSpace(name), "`", "")
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+	for i := range fields {
+		f := &fields[i]
+		if strings.EqualFold(name, f.Column) || strings.EqualFold(name, f.BSON) || strings.EqualFold(name, f.Name) {
+			return f, nil
+		}
+	}
+	return nil, nil
+}
+
+// 加密结构体中的加密字段 返回恢复明文的函数
+// v 可以是结构体指针 切片指针或可寻址的 reflect.Value
+func EncryptStruct(ctx context.Context, v any) (restore func(), err error) {
+	var undo []func()
+	restore = func() {
+		for _, f := range undo {
+			f()
+		}
+	}
+	err = walk(v, func(field Field, fv reflect.Value) error {
+		s, ok := stringValue(fv)
+		if !ok || s == "" || IsEncrypted(s) {
+			return nil
+		}
+		enc, err := Encrypt(ctx, s, field.Deterministic)
+		if err != nil {
+			return err
+		}
+		old := reflect.New(fv.Type()).Elem()
+		old.Set(fv)
+		undo = append(undo, func() { fv.Set(old) })
+		setString(fv, enc)
+		return nil
+	})
+	if err != nil {
+		restore()
+		return func() {}, err
+	}
+	return restore, nil
+}
+
+// 解密结构体中的加密字段
+// v 可以是结构体指针 切片指针或可寻址的 reflect.Value
+func DecryptStruct(ctx context.Context, v any) error {
+	return walk(v, func(field Field, fv reflect.Value) error {
+		s, ok := stringValue(fv)
+		if !ok || !IsEncrypted(s) {
+			return nil
+		}
+		plain, err := Decrypt(ctx, s)
+		if err != nil {
+			return fmt.Errorf("decrypt field %s: %w", field.Name, err)
+		}
+		setString(fv, plain)
+		return nil
+	})
+}
+
+// 加密 map 中属于加密字段的值 键为列名 bson 名或字段名
+// 有字段被加密时返回新的 map 不修改传入的 map
+func EncryptMap(ctx context.Context, typ reflect.Type, m map[string]any) (map[string]any, error) {
+	fields, err := Fields(typ)
+	if err != nil || len(fields) == 0 {
+		return m, err
+	}
+	var out map[string]any
+	for k, v := range m {
+		field, _ := Lookup(typ, k)
+		if field == nil {
+			continue
+		}
+		s, ok := stringValue(reflect.ValueOf(v))
+		if !ok || s == "" || IsEncrypted(s) {
+			continue
+		}
+		enc, err := Encrypt(ctx, s, field.Deterministic)
+		if err != nil {
+			return m, err
+		}
+		if out == nil {
+			out = make(map[string]any, len(m))
+			for k2, v2 := range m {
+				out[k2] = v2
+			}
+		}
+		out[k] = enc
+	}
+	if out == nil {
+		return m, nil
+	}
+	return out, nil
+}
+
+// 转换等值查询条件中的值
+// 字段为确定性加密字段时返回加密后的值 非确定性加密字段返回 ErrNotDeterministic
+// 支持 string *string 与 []string
+func WhereValue(ctx context.Context, typ reflect.Type, name string, value any) (any, error) {
+	field, err := Lookup(typ, name)
+	if err != nil || field == nil {
+		return value, err
+	}
+	if !field.Deterministic {
+		return value, fmt.Errorf("%w: %s", ErrNotDeterministic, field.Name)
+	}
+	if list, ok := value.([]string); ok {
+		out := make([]string, len(list))
+		for i, s := range list {
+			if out[i], err = Encrypt(ctx, s, true); err != nil {
+				return value, err
+			}
+		}
+		return out, nil
+	}
+	s, ok := stringValue(reflect.ValueOf(value))
+	if !ok || IsEncrypted(s) {
+		return value, nil
+	}
+	return Encrypt(ctx, s, true)
+}
+
+// 遍历 v 中的加密字段
+func walk(v any, fn func(Field, reflect.Value) error) error {
+	rv, ok := v.(reflect.Value)
+	if !ok {
+		rv = reflect.ValueOf(v)
+	}
+	for rv.IsValid() && (rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface) {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		fields, err := Fields(rv.Type().Elem())
+		if err != nil || len(fields) == 0 {
+			return err
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := walk(rv.Index(i), fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		fields, err := Fields(rv.Type())
+		if err != nil || len(fields) == 0 || !rv.CanAddr() {
+			return err
+		}
+		for _, field := range fields {
+			if err := fn(field, rv.FieldByIndex(field.Index)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func structType(typ reflect.Type) reflect.Type {
+	for typ != nil && (typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil
+	}
+	return typ
+}
+
+func stringValue(v reflect.Value) (string, bool) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.String {
+		return "", false
+	}
+	return v.String(), true
+}
+
+func setString(v reflect.Value, s string) {
+	if v.Kind() == reflect.Ptr {
+		// 不修改指针指向的原值
+		p := reflect.New(v.Type().Elem())
+		p.Elem().SetString(s)
+		v.Set(p)
+		return
+	}
+	v.SetString(s)
+}
+
+func columnName(f *schema.Field) string {
+	if f.Column != "" {
+		return f.Column
+	}
+	return gschema.NamingStrategy{}.ColumnName("", f.Name)
+}
+
+func bsonName(f *schema.Field) string {
+	if f.BSON != "" {
+		return f.BSON
+	}
+	return strings.ToLower(f.Name)
+}
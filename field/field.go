@@ -0,0 +1,85 @@
+// 类型安全的字段描述
+//
+// 通常不需要手写 在模型所在的包中使用 go generate 生成
+//
+//	//go:generate go run github.com/lfhy/morm/cmd/morm gen fields -type User
+//
+// 生成的 UserFields 可以直接作为 Where 的条件 列名与 bson 名由后端选择
+//
+//	db.Model(&User{}).Where(UserFields.Name.Eq("x"), UserFields.Age.Gt(18)).Find().All(&list)
+package field
+
+import "github.com/lfhy/morm/types"
+
+// 字段描述 T 为字段的值类型 指针字段为指向的类型
+type Field[T any] struct {
+	// SQL 列名
+	Column string
+	// MongoDB 字段名
+	BSON string
+}
+
+// 字段描述
+func New[T any](column, bson string) Field[T] {
+	return Field[T]{Column: column, BSON: bson}
+}
+
+func (f Field[T]) cond(op types.CondOp, value any) types.Condition {
+	return types.Condition{Column: f.Column, BSON: f.BSON, Op: op, Value: value}
+}
+
+// 等于
+func (f Field[T]) Eq(v T) types.Condition {
+	return f.cond(types.CondEq, v)
+}
+
+// 不等于
+func (f Field[T]) Ne(v T) types.Condition {
+	return f.cond(types.CondNe, v)
+}
+
+// 大于
+func (f Field[T]) Gt(v T) types.Condition {
+	return f.cond(types.CondGt, v)
+}
+
+// 大于等于
+func (f Field[T]) Gte(v T) types.Condition {
+	return f.cond(types.CondGte, v)
+}
+
+// 小于
+func (f Field[T]) Lt(v T) types.Condition {
+	return f.cond(types.CondLt, v)
+}
+
+// 小于等于
+func (f Field[T]) Lte(v T) types.Condition {
+	return f.cond(types.CondLte, v)
+}
+
+// 在列表中
+func (f Field[T]) In(v ...T) types.Condition {
+	return f.cond(types.CondIn, v)
+}
+
+// 不在列表中
+func (f Field[T]) NotIn(v ...T) types.Condition {
+	return f.cond(types.CondNotIn, v)
+}
+
+// 字符串字段描述 支持模糊查询
+type String struct {
+	Field[string]
+}
+
+// 字符串字段描述
+func NewString(column, bson string) String {
+	return String{Field: New[string](column, bson)}
+}
+
+// 模糊查询 使用 SQL LIKE 语法 如 "a%" 匹配以 a 开头
+// MongoDB 转换为等价的 $regex
+func (f String) Like(pattern string) types.Condition {
+	return f.cond(types.CondLike, pattern)
+}
@@ -0,0 +1,208 @@
+package gen
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/lfhy/morm/db/mongodb"
+	"github.com/lfhy/morm/db/sqlorm"
+	"github.com/lfhy/morm/types"
+)
+
+// 命令行用法
+const Usage = `usage: gen [flags] models
+       gen fields [-type User,Order] [-output morm_fields.go] [dir]
+
+models  根据数据库中已有的表或集合生成模型 每个表生成一个文件
+        SQL 读取表结构 MongoDB 抽样文档推断字段类型
+fields  为模型生成类型安全的字段描述 可以在 go:generate 中使用
+
+flags:
+`
+
+// 执行 gen 命令 models 已存在的文件默认跳过
+// fields 不需要连接数据库
+func RunCommand(ctx context.Context, args []string, out io.Writer, connect func() (types.ORM, error)) error {
+	if len(args) > 0 && args[0] == "fields" {
+		return runFields(args[1:], out)
+	}
+	fs := flag.NewFlagSet("gen", flag.ContinueOnError)
+	fs.SetOutput(out)
+	dir := fs.String("dir", "./models", "生成目录")
+	pkg := fs.String("package", "", "包名 默认为目录名")
+	tables := fs.String("tables", "", "表或集合 以 , 分隔 默认全部")
+	sample := fs.Int("sample", DefaultSample, "MongoDB 每个集合抽样的文档数")
+	dbVar := fs.String("db", "DB", "M 方法使用的连接变量")
+	force := fs.Bool("force", false, "覆盖已存在的文件")
+	fs.Usage = func() {
+		fmt.Fprint(out, Usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	switch cmd := fs.Arg(0); cmd {
+	case "models":
+		// 参数也可以写在子命令之后
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			return err
+		}
+	case "":
+		fs.Usage()
+		return errors.New("gen: missing command")
+	default:
+		fs.Usage()
+		return fmt.Errorf("gen: unknown command %q", cmd)
+	}
+
+	var names []string
+	for _, name := range strings.Split(*tables, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	orm, err := connect()
+	if err != nil {
+		return err
+	}
+	list, err := Tables(ctx, orm, *sample, names...)
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		fmt.Fprintln(out, "no tables")
+		return nil
+	}
+	return WriteModels(*dir, Options{Package: *pkg, DBVar: *dbVar}, *force, out, list...)
+}
+
+// 读取连接中的表或集合
+func Tables(ctx context.Context, orm types.ORM, sample int, names ...string) ([]Table, error) {
+	switch db := orm.(type) {
+	case *sqlorm.DBConn:
+		return SQLTables(db.DB.WithContext(ctx), names...)
+	case *mongodb.DBConn:
+		return MongoTables(ctx, db.Client.Database(db.Database), sample, names...)
+	}
+	return nil, fmt.Errorf("gen: %T is not supported", orm)
+}
+
+// 在 dir 中为每个表生成一个文件 并生成声明连接变量的 db.go
+// force 为 false 时跳过已存在的文件
+func WriteModels(dir string, opts Options, force bool, out io.Writer, tables ...Table) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if opts.Package == "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		opts.Package = packageName(filepath.Base(abs))
+	}
+	if opts.DBVar == "" {
+		opts.DBVar = "DB"
+	}
+	write := func(name string, src []byte) error {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil && !force {
+			fmt.Fprintln(out, "skip", path)
+			return nil
+		}
+		if err := os.WriteFile(path, src, 0o644); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "created", path)
+		return nil
+	}
+	dbSrc, err := format.Source([]byte(fmt.Sprintf("package %s\n\nimport \"github.com/lfhy/morm\"\n\n// 模型使用的连接 初始化后赋值\nvar %s morm.ORM\n", opts.Package, opts.DBVar)))
+	if err != nil {
+		return err
+	}
+	if err := write("db.go", dbSrc); err != nil {
+		return err
+	}
+	for _, t := range tables {
+		src, err := Render(opts, t)
+		if err != nil {
+			return err
+		}
+		if err := write(fileName(t.Name)+".go", src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 表名转为文件名 非字母数字替换为 _
+func fileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return '_'
+	}, name)
+	// 避免生成 _test.go 等特殊文件
+	if strings.HasSuffix(name, "_test") || name == "db" {
+		name += "_model"
+	}
+	return name
+}
+
+func packageName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		return "models"
+	}
+	return name
+}
+
+func runFields(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("gen fields", flag.ContinueOnError)
+	fs.SetOutput(out)
+	typeNames := fs.String("type", "", "结构体 以 , 分隔 默认为带有 TableName 方法的结构体")
+	output := fs.String("output", DefaultFieldsFile, "输出文件 相对于包目录")
+	fs.Usage = func() {
+		fmt.Fprint(out, Usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	dir := "."
+	if fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+	var names []string
+	for _, name := range strings.Split(*typeNames, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	src, err := GenerateFields(dir, names...)
+	if err != nil {
+		return err
+	}
+	path := *output
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	if err := os.WriteFile(path, src, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "created", path)
+	return nil
+}
@@ -0,0 +1,286 @@
+package gen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/lfhy/morm/schema"
+	gschema "gorm.io/gorm/schema"
+)
+
+// 字段描述默认的输出文件
+const DefaultFieldsFile = "morm_fields.go"
+
+// 生成的字段描述
+type fieldDesc struct {
+	Name   string
+	Type   string
+	Column string
+	BSON   string
+}
+
+func (f fieldDesc) Desc() string {
+	if f.Type == "string" {
+		return "field.String"
+	}
+	return "field.Field[" + f.Type + "]"
+}
+
+func (f fieldDesc) New() string {
+	if f.Type == "string" {
+		return fmt.Sprintf("field.NewString(%q, %q)", f.Column, f.BSON)
+	}
+	return fmt.Sprintf("field.New[%s](%q, %q)", f.Type, f.Column, f.BSON)
+}
+
+type fieldsModel struct {
+	Name   string
+	Fields []fieldDesc
+}
+
+const fieldsTemplate = `// Code generated by morm gen fields. DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+{{- range .Imports }}
+	{{ . }}
+{{- end }}
+)
+{{ range .Models }}
+// {{ .Name }}Fields {{ .Name }} 的字段描述
+var {{ .Name }}Fields = struct {
+{{- range .Fields }}
+	{{ .Name }} {{ .Desc }}
+{{- end }}
+}{
+{{- range .Fields }}
+	{{ .Name }}: {{ .New }},
+{{- end }}
+}
+{{ end }}`
+
+var fieldsTmpl = template.Must(template.New("fields").Parse(fieldsTemplate))
+
+// 解析 dir 中的模型 生成字段描述代码
+// 没有传入类型时为包中所有带有 TableName 方法的导出结构体生成
+// 列名与 bson 名的规则与 schema 包一致
+func GenerateFields(dir string, typeNames ...string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	var pkg *ast.Package
+	for name, p := range pkgs {
+		if !strings.HasSuffix(name, "_test") {
+			pkg = p
+		}
+	}
+	if pkg == nil {
+		return nil, fmt.Errorf("gen: no package in %s", dir)
+	}
+
+	structs := make(map[string]*ast.StructType)
+	fileOf := make(map[string]*ast.File)
+	models := make(map[string]bool)
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			switch d := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					ts, ok := spec.(*ast.TypeSpec)
+					if !ok || ts.TypeParams != nil {
+						continue
+					}
+					if st, ok := ts.Type.(*ast.StructType); ok {
+						structs[ts.Name.Name] = st
+						fileOf[ts.Name.Name] = file
+					}
+				}
+			case *ast.FuncDecl:
+				if d.Recv != nil && d.Name.Name == "TableName" && len(d.Recv.List) == 1 {
+					models[receiverName(d.Recv.List[0].Type)] = true
+				}
+			}
+		}
+	}
+	if len(typeNames) == 0 {
+		for name := range models {
+			if structs[name] != nil && ast.IsExported(name) {
+				typeNames = append(typeNames, name)
+			}
+		}
+		sort.Strings(typeNames)
+	}
+	if len(typeNames) == 0 {
+		return nil, errors.New("gen: no models found")
+	}
+
+	g := &fieldsGen{fset: fset, structs: structs, fileOf: fileOf, imports: map[string]bool{`"github.com/lfhy/morm/field"`: true}}
+	data := struct {
+		Package string
+		Imports []string
+		Models  []fieldsModel
+	}{Package: pkg.Name}
+	for _, name := range typeNames {
+		if structs[name] == nil {
+			return nil, fmt.Errorf("gen: struct %s not found in %s", name, dir)
+		}
+		fields := g.fields(name, map[string]bool{}, map[string]bool{})
+		data.Models = append(data.Models, fieldsModel{Name: name, Fields: fields})
+	}
+	for imp := range g.imports {
+		data.Imports = append(data.Imports, imp)
+	}
+	sort.Strings(data.Imports)
+	var buf bytes.Buffer
+	if err := fieldsTmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return buf.Bytes(), fmt.Errorf("gen: format fields: %w", err)
+	}
+	return src, nil
+}
+
+func receiverName(expr ast.Expr) string {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+type fieldsGen struct {
+	fset    *token.FileSet
+	structs map[string]*ast.StructType
+	fileOf  map[string]*ast.File
+	imports map[string]bool
+}
+
+// 结构体的字段 匿名嵌套的同包结构体展开 外层字段优先
+func (g *fieldsGen) fields(name string, seen, visiting map[string]bool) []fieldDesc {
+	visiting[name] = true
+	defer delete(visiting, name)
+	st, file := g.structs[name], g.fileOf[name]
+	var result []fieldDesc
+	var embedded []string
+	for _, f := range st.Fields.List {
+		tag := ""
+		if f.Tag != nil {
+			tag, _ = strconv.Unquote(f.Tag.Value)
+		}
+		if len(f.Names) == 0 {
+			if inner := receiverName(f.Type); g.structs[inner] != nil && !visiting[inner] {
+				embedded = append(embedded, inner)
+			}
+			continue
+		}
+		typ, ok := g.typeString(f.Type, file)
+		if !ok {
+			continue
+		}
+		for _, ident := range f.Names {
+			if !ident.IsExported() || seen[ident.Name] {
+				continue
+			}
+			desc, ok := describe(ident.Name, reflect.StructTag(tag))
+			if !ok {
+				continue
+			}
+			desc.Type = typ
+			seen[ident.Name] = true
+			result = append(result, desc)
+		}
+	}
+	for _, inner := range embedded {
+		result = append(result, g.fields(inner, seen, visiting)...)
+	}
+	return result
+}
+
+// 字段的列名与 bson 名 两者都被忽略时返回 false
+func describe(name string, tag reflect.StructTag) (fieldDesc, bool) {
+	f := schema.ParseField(reflect.StructField{Name: name, Tag: tag, Type: reflect.TypeOf("")})
+	desc := fieldDesc{Name: name, Column: f.Column, BSON: f.BSON}
+	// 没有标签时与 gorm 和 mongo-driver 的默认规则一致
+	gormTag := tag.Get("gorm")
+	if desc.Column == "" && gormTag != "-" && !strings.HasPrefix(gormTag, "-;") && !strings.HasPrefix(gormTag, "-:") {
+		desc.Column = gschema.NamingStrategy{}.ColumnName("", name)
+	}
+	if bsonName, _, _ := strings.Cut(tag.Get("bson"), ","); desc.BSON == "" && bsonName != "-" {
+		desc.BSON = strings.ToLower(name)
+	}
+	return desc, desc.Column != "" || desc.BSON != ""
+}
+
+// 字段类型的源码 指针取指向的类型 函数与通道等类型不生成
+func (g *fieldsGen) typeString(expr ast.Expr, file *ast.File) (string, bool) {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	supported := true
+	ast.Inspect(expr, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncType, *ast.ChanType, *ast.StructType:
+			supported = false
+		case *ast.SelectorExpr:
+			if x, ok := n.X.(*ast.Ident); ok {
+				if imp := findImport(file, x.Name); imp != "" {
+					g.imports[imp] = true
+				}
+			}
+		}
+		return supported
+	})
+	if !supported {
+		return "", false
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, g.fset, expr); err != nil {
+		return "", false
+	}
+	return buf.String(), true
+}
+
+// 按包名查找导入 返回可以直接写入 import 的内容
+func findImport(file *ast.File, name string) string {
+	for _, imp := range file.Imports {
+		p, _ := strconv.Unquote(imp.Path.Value)
+		if imp.Name != nil {
+			if imp.Name.Name == name {
+				return imp.Name.Name + " " + imp.Path.Value
+			}
+			continue
+		}
+		base := path.Base(p)
+		// gopkg.in/yaml.v3 与 example.com/pkg/v2 的包名不含版本
+		if strings.HasPrefix(base, "v") && len(base) > 1 && strings.Trim(base[1:], "0123456789") == "" {
+			base = path.Base(path.Dir(p))
+		}
+		base, _, _ = strings.Cut(base, ".")
+		if base == name {
+			return imp.Path.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,256 @@
+// 根据已有数据库生成模型代码
+//
+// SQL 通过 gorm Migrator 读取表结构 MongoDB 通过抽样文档推断字段类型
+// 生成的结构体同时带有 gorm 与 bson 标签 并实现 morm.BaseModel 的 TableName 与 M 方法
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// 表或集合
+type Table struct {
+	// 表名或集合名
+	Name string
+	// 结构体名 为空时由表名生成
+	StructName string
+	Columns    []Column
+	// 嵌套文档生成的结构体
+	Nested []Table
+}
+
+// 列或字段
+type Column struct {
+	// 列名或 bson 字段名
+	Name string
+	// 结构体字段名 为空时由列名生成
+	FieldName string
+	// Go 类型 如 string *int64 time.Time
+	GoType string
+	// 主键
+	PrimaryKey bool
+	// 自增
+	AutoIncrement bool
+	// 数据库类型 如 varchar(255) 为空时不写入 gorm 标签
+	DBType string
+	// 不能为空
+	NotNull bool
+	// 索引 同名的列组成联合索引
+	Index       string
+	UniqueIndex string
+	// gorm 序列化方式 MongoDB 的嵌套文档与数组为 json
+	Serializer string
+	// 抽样中部分文档没有该字段
+	OmitEmpty bool
+	// 字段说明
+	Comment string
+}
+
+// 生成选项
+type Options struct {
+	// 包名
+	Package string
+	// M 方法使用的连接变量 默认 DB
+	DBVar string
+}
+
+// gorm 标签
+func (c Column) GormTag() string {
+	parts := []string{"column:" + c.Name}
+	if c.DBType != "" {
+		parts = append(parts, "type:"+c.DBType)
+	}
+	if c.PrimaryKey {
+		parts = append(parts, "primaryKey")
+	}
+	if c.AutoIncrement {
+		parts = append(parts, "autoIncrement")
+	}
+	if c.NotNull && !c.PrimaryKey {
+		parts = append(parts, "not null")
+	}
+	if c.Index != "" {
+		parts = append(parts, "index:"+c.Index)
+	}
+	if c.UniqueIndex != "" {
+		parts = append(parts, "uniqueIndex:"+c.UniqueIndex)
+	}
+	if c.Serializer != "" {
+		parts = append(parts, "serializer:"+c.Serializer)
+	}
+	return strings.Join(parts, ";")
+}
+
+// bson 标签 主键固定为 _id
+func (c Column) BSONTag() string {
+	name := c.Name
+	if c.PrimaryKey {
+		name = "_id"
+	}
+	if c.OmitEmpty {
+		name += ",omitempty"
+	}
+	return name
+}
+
+const modelTemplate = `// 由 morm gen models 生成
+package {{ .Package }}
+
+import (
+{{- range .StdImports }}
+	"{{ . }}"
+{{- end }}
+{{ range .Imports }}
+	"{{ . }}"
+{{- end }}
+)
+{{ range .Tables }}
+{{ template "struct" . }}
+// 表名或集合名
+func ({{ .StructName }}) TableName() string {
+	return {{ printf "%q" .Name }}
+}
+
+func ({{ .StructName }}) M() morm.ORMModel {
+	return {{ $.DBVar }}.Model(&{{ .StructName }}{})
+}
+{{ range .Nested }}
+{{ template "struct" . }}
+{{- end }}
+{{- end }}
+
+{{- define "struct" }}
+// {{ .StructName }} 对应 {{ .Name }}
+type {{ .StructName }} struct {
+{{- range .Columns }}
+	{{ .FieldName }} {{ .GoType }} ` + "`" + `bson:"{{ .BSONTag }}" gorm:"{{ .GormTag }}"` + "`" + `{{ if .Comment }} // {{ .Comment }}{{ end }}
+{{- end }}
+}
+{{ end }}
+`
+
+var tmpl = template.Must(template.New("models").Parse(modelTemplate))
+
+// 生成模型代码 结果已经过 gofmt
+func Render(opts Options, tables ...Table) ([]byte, error) {
+	if opts.Package == "" {
+		opts.Package = "models"
+	}
+	if opts.DBVar == "" {
+		opts.DBVar = "DB"
+	}
+	imports := map[string]bool{"github.com/lfhy/morm": true}
+	tables = append([]Table(nil), tables...)
+	for i := range tables {
+		tables[i] = prepare(tables[i], imports)
+	}
+	data := struct {
+		Options
+		StdImports []string
+		Imports    []string
+		Tables     []Table
+	}{Options: opts, Tables: tables}
+	// 标准库与第三方包分组
+	for pkg := range imports {
+		if strings.Contains(strings.Split(pkg, "/")[0], ".") {
+			data.Imports = append(data.Imports, pkg)
+		} else {
+			data.StdImports = append(data.StdImports, pkg)
+		}
+	}
+	sort.Strings(data.StdImports)
+	sort.Strings(data.Imports)
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return buf.Bytes(), fmt.Errorf("gen: format %s: %w", opts.Package, err)
+	}
+	return src, nil
+}
+
+// 补全结构体名与字段名 收集需要导入的包
+func prepare(t Table, imports map[string]bool) Table {
+	if t.StructName == "" {
+		t.StructName = StructName(t.Name)
+	}
+	t.Columns = append([]Column(nil), t.Columns...)
+	used := make(map[string]int)
+	for i := range t.Columns {
+		c := &t.Columns[i]
+		if c.FieldName == "" {
+			c.FieldName = FieldName(c.Name)
+		}
+		// 不同的列生成相同的字段名时加上序号
+		if n := used[c.FieldName]; n > 0 {
+			used[c.FieldName]++
+			c.FieldName = fmt.Sprintf("%s%d", c.FieldName, n+1)
+		} else {
+			used[c.FieldName] = 1
+		}
+		switch {
+		case strings.Contains(c.GoType, "time."):
+			imports["time"] = true
+		case strings.Contains(c.GoType, "primitive."):
+			imports["go.mongodb.org/mongo-driver/bson/primitive"] = true
+		}
+	}
+	t.Nested = append([]Table(nil), t.Nested...)
+	for i := range t.Nested {
+		t.Nested[i] = prepare(t.Nested[i], imports)
+	}
+	return t
+}
+
+// 常见缩写 生成的字段名使用全大写
+var initialisms = map[string]bool{
+	"ACL": true, "API": true, "ASCII": true, "CPU": true, "CSS": true, "DNS": true, "EOF": true,
+	"GUID": true, "HTML": true, "HTTP": true, "HTTPS": true, "ID": true, "IP": true, "JSON": true,
+	"OID": true, "QPS": true, "RAM": true, "SQL": true, "SSH": true, "TCP": true, "TLS": true,
+	"TTL": true, "UDP": true, "UI": true, "UID": true, "URI": true, "URL": true, "UUID": true,
+	"XML": true,
+}
+
+// 列名转为字段名 如 user_id -> UserID _id -> ID
+func FieldName(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, w := range words {
+		if upper := strings.ToUpper(w); initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	s := b.String()
+	if s == "" || !unicode.IsLetter([]rune(s)[0]) {
+		s = "F" + s
+	}
+	return s
+}
+
+// 表名转为结构体名 复数表名转为单数 如 user_orders -> UserOrder
+func StructName(table string) string {
+	name := FieldName(table)
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 3:
+		return name[:len(name)-3] + "y"
+	case strings.HasSuffix(name, "sses"), strings.HasSuffix(name, "xes"), strings.HasSuffix(name, "ches"), strings.HasSuffix(name, "shes"):
+		return name[:len(name)-2]
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") && !strings.HasSuffix(name, "us") && len(name) > 1:
+		return name[:len(name)-1]
+	}
+	return name
+}
@@ -0,0 +1,222 @@
+package gen
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/lfhy/morm/db/mongodb"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// 默认抽样文档数
+const DefaultSample = 100
+
+// 抽样推断 MongoDB 集合的字段 没有传入集合名时读取全部集合
+func MongoTables(ctx context.Context, db *mongo.Database, sample int, collections ...string) ([]Table, error) {
+	if sample <= 0 {
+		sample = DefaultSample
+	}
+	if len(collections) == 0 {
+		names, err := db.ListCollectionNames(ctx, bson.M{})
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if !strings.HasPrefix(name, "system.") {
+				collections = append(collections, name)
+			}
+		}
+		sort.Strings(collections)
+	}
+	result := make([]Table, 0, len(collections))
+	for _, name := range collections {
+		coll := db.Collection(name)
+		cursor, err := coll.Aggregate(ctx, mongo.Pipeline{{{Key: "$sample", Value: bson.D{{Key: "size", Value: sample}}}}})
+		if err != nil {
+			return nil, err
+		}
+		var docs []bson.D
+		if err := cursor.All(ctx, &docs); err != nil {
+			return nil, err
+		}
+		t := InferTable(name, docs)
+		indexes, err := mongodb.ListIndexes(ctx, coll)
+		if err != nil {
+			return nil, err
+		}
+		for _, idx := range indexes {
+			if idx.Name == "_id_" {
+				continue
+			}
+			for _, key := range idx.Key {
+				for i := range t.Columns {
+					if t.Columns[i].Name != key.Key {
+						continue
+					}
+					if idx.Unique {
+						t.Columns[i].UniqueIndex = idx.Name
+					} else {
+						t.Columns[i].Index = idx.Name
+					}
+				}
+			}
+		}
+		result = append(result, t)
+	}
+	return result, nil
+}
+
+// 字段在抽样文档中的取值
+type sampleField struct {
+	name   string
+	count  int
+	null   bool
+	values []any
+}
+
+// 根据抽样文档推断字段 字段按第一次出现的顺序排列
+// 部分文档没有的字段带有 omitempty 出现过 null 的字段使用指针
+// 嵌套文档生成嵌套结构体 类型不一致的字段为 any
+func InferTable(name string, docs []bson.D) Table {
+	t := Table{Name: name, StructName: StructName(name)}
+	inferColumns(&t, t.StructName, docs)
+	return t
+}
+
+func inferColumns(t *Table, structName string, docs []bson.D) {
+	var fields []*sampleField
+	byName := make(map[string]*sampleField)
+	for _, doc := range docs {
+		for _, e := range doc {
+			f, ok := byName[e.Key]
+			if !ok {
+				f = &sampleField{name: e.Key}
+				byName[e.Key] = f
+				fields = append(fields, f)
+			}
+			f.count++
+			if e.Value == nil {
+				f.null = true
+				continue
+			}
+			f.values = append(f.values, e.Value)
+		}
+	}
+	for _, f := range fields {
+		c := Column{Name: f.name, OmitEmpty: f.count < len(docs)}
+		if f.name == "_id" {
+			c.Name, c.PrimaryKey, c.OmitEmpty = "id", true, false
+		}
+		c.GoType = inferType(t, structName+FieldName(f.name), t.Name+"."+f.name, f.values)
+		if f.null && !strings.HasPrefix(c.GoType, "[]") && c.GoType != "any" {
+			c.GoType = "*" + c.GoType
+		}
+		// 嵌套文档与数组在 SQL 中保存为 json
+		switch base := strings.TrimPrefix(c.GoType, "*"); {
+		case base == "[]byte":
+		case strings.HasPrefix(base, "[]"), base == "any", isStruct(t, base):
+			c.Serializer = "json"
+		}
+		t.Columns = append(t.Columns, c)
+	}
+}
+
+func isStruct(t *Table, name string) bool {
+	for _, n := range t.Nested {
+		if n.StructName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// 推断取值的 Go 类型 嵌套文档的结构体加入 t.Nested
+// path 为字段路径 如 users.address 用于嵌套结构体的注释
+func inferType(t *Table, nestedName, path string, values []any) string {
+	if len(values) == 0 {
+		return "any"
+	}
+	kinds := make(map[string]bool)
+	for _, v := range values {
+		kinds[valueKind(v)] = true
+	}
+	if len(kinds) == 2 && kinds["int32"] && kinds["int64"] {
+		return "int64"
+	}
+	if len(kinds) > 1 {
+		numeric := true
+		for k := range kinds {
+			numeric = numeric && (k == "int32" || k == "int64" || k == "float64")
+		}
+		if numeric {
+			return "float64"
+		}
+		return "any"
+	}
+	for kind := range kinds {
+		switch kind {
+		case "object":
+			docs := make([]bson.D, 0, len(values))
+			for _, v := range values {
+				docs = append(docs, v.(bson.D))
+			}
+			nested := Table{StructName: nestedName, Name: path}
+			// 先占位 嵌套结构体排在外层之后
+			i := len(t.Nested)
+			t.Nested = append(t.Nested, nested)
+			inferColumns(&nested, nestedName, docs)
+			// 更深层的嵌套结构体提升到外层
+			t.Nested = append(t.Nested, nested.Nested...)
+			nested.Nested = nil
+			t.Nested[i] = nested
+			return nestedName
+		case "array":
+			var elems []any
+			for _, v := range values {
+				elems = append(elems, v.(bson.A)...)
+			}
+			return "[]" + inferType(t, nestedName+"Item", path+"[]", elems)
+		case "other":
+			return "any"
+		case "int32":
+			// 驱动写入 int 时数值较小的保存为 int32
+			return "int"
+		default:
+			return kind
+		}
+	}
+	return "any"
+}
+
+// 取值对应的 Go 类型
+func valueKind(v any) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case int32:
+		return "int32"
+	case int64:
+		return "int64"
+	case float64:
+		return "float64"
+	case primitive.DateTime:
+		return "time.Time"
+	case primitive.ObjectID:
+		return "primitive.ObjectID"
+	case primitive.Decimal128:
+		return "primitive.Decimal128"
+	case primitive.Binary:
+		return "[]byte"
+	case bson.D:
+		return "object"
+	case bson.A:
+		return "array"
+	default:
+		return "other"
+	}
+}
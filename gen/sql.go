@@ -0,0 +1,127 @@
+package gen
+
+import (
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 读取 SQL 表结构 没有传入表名时读取全部表
+func SQLTables(db *gorm.DB, tables ...string) ([]Table, error) {
+	migrator := db.Migrator()
+	if len(tables) == 0 {
+		list, err := migrator.GetTables()
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range list {
+			// SQLite 内部表
+			if !strings.HasPrefix(name, "sqlite_") {
+				tables = append(tables, name)
+			}
+		}
+		sort.Strings(tables)
+	}
+	result := make([]Table, 0, len(tables))
+	for _, name := range tables {
+		columnTypes, err := migrator.ColumnTypes(name)
+		if err != nil {
+			return nil, err
+		}
+		t := Table{Name: name}
+		for _, ct := range columnTypes {
+			t.Columns = append(t.Columns, sqlColumn(ct))
+		}
+		// 部分驱动不支持读取索引 此时不生成索引标签
+		if indexes, err := migrator.GetIndexes(name); err == nil {
+			applyIndexes(&t, indexes)
+		}
+		result = append(result, t)
+	}
+	return result, nil
+}
+
+func sqlColumn(ct gorm.ColumnType) Column {
+	c := Column{Name: ct.Name()}
+	c.PrimaryKey, _ = ct.PrimaryKey()
+	c.AutoIncrement, _ = ct.AutoIncrement()
+	if nullable, ok := ct.Nullable(); ok {
+		c.NotNull = !nullable
+	}
+	if typ, ok := ct.ColumnType(); ok {
+		c.DBType = typ
+	}
+	if c.DBType == "" {
+		c.DBType = strings.ToLower(ct.DatabaseTypeName())
+	}
+	c.Comment, _ = ct.Comment()
+	c.GoType = SQLGoType(c.DBType)
+	// 可以为空的列使用指针 零值与 NULL 可以区分
+	if !c.NotNull && !c.PrimaryKey && !strings.HasPrefix(c.GoType, "[]") {
+		c.GoType = "*" + c.GoType
+	}
+	return c
+}
+
+func applyIndexes(t *Table, indexes []gorm.Index) {
+	for _, idx := range indexes {
+		if pk, _ := idx.PrimaryKey(); pk {
+			continue
+		}
+		unique, _ := idx.Unique()
+		for _, col := range idx.Columns() {
+			for i := range t.Columns {
+				if t.Columns[i].Name != col {
+					continue
+				}
+				if unique {
+					t.Columns[i].UniqueIndex = idx.Name()
+				} else {
+					t.Columns[i].Index = idx.Name()
+				}
+			}
+		}
+	}
+}
+
+// 数据库类型对应的 Go 类型
+func SQLGoType(dbType string) string {
+	typ := strings.ToLower(strings.TrimSpace(dbType))
+	unsigned := strings.Contains(typ, "unsigned")
+	if i := strings.IndexAny(typ, "( "); i >= 0 {
+		if typ[:i] == "tinyint" && strings.HasPrefix(typ[i:], "(1)") {
+			return "bool"
+		}
+		typ = typ[:i]
+	}
+	prefix := ""
+	if unsigned {
+		prefix = "u"
+	}
+	switch typ {
+	case "bool", "boolean", "bit":
+		return "bool"
+	case "tinyint":
+		return prefix + "int8"
+	case "smallint", "int2":
+		return prefix + "int16"
+	case "mediumint", "int", "int4", "integer":
+		if typ == "integer" && !unsigned {
+			// SQLite 的 integer 为 64 位
+			return "int64"
+		}
+		return prefix + "int32"
+	case "bigint", "int8", "serial", "bigserial":
+		return prefix + "int64"
+	case "float", "real", "float4":
+		return "float32"
+	case "double", "decimal", "numeric", "float8", "money":
+		return "float64"
+	case "date", "datetime", "timestamp", "timestamptz":
+		return "time.Time"
+	case "blob", "tinyblob", "mediumblob", "longblob", "binary", "varbinary", "bytea":
+		return "[]byte"
+	}
+	return "string"
+}
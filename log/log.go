@@ -6,7 +6,6 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
-	"time"
 
 	"github.com/lfhy/morm/conf"
 	"gorm.io/gorm/logger"
@@ -21,6 +20,18 @@ func InitDBLoger() logger.Interface {
 	if logout != nil {
 		return *logout
 	}
+	if err := initRedactPolicy(); err != nil {
+		log.Println("数据库", "日志脱敏规则初始化失败", err)
+	}
+	slow, ignoreNotFound := loadLogConfig()
+	if l := GetLogger(); l != nil {
+		SetDBLoger(NewGormLogger(l, logger.Config{
+			SlowThreshold:             slow,
+			LogLevel:                  logLevel(),
+			IgnoreRecordNotFoundError: ignoreNotFound,
+		}))
+		return *logout
+	}
 	LogName := conf.ReadConfigToString("db", "log")
 	var logWrite io.Writer
 	if LogName == "" {
@@ -37,12 +48,12 @@ func InitDBLoger() logger.Interface {
 	}
 
 	log := logger.New(log.New(logWrite, "\r\n", log.LstdFlags), logger.Config{
-		SlowThreshold:             200 * time.Millisecond,                                  // 慢 SQL 阈值
-		LogLevel:                  logger.LogLevel(conf.ReadConfigToInt("db", "loglevel")), // 日志级别
-		IgnoreRecordNotFoundError: false,                                                   // 忽略ErrRecordNotFound（记录未找到）错误
-		Colorful:                  false,                                                   // 禁用彩色打印
+		SlowThreshold:             slow,           // 慢 SQL 阈值
+		LogLevel:                  logLevel(),     // 日志级别
+		IgnoreRecordNotFoundError: ignoreNotFound, // 忽略ErrRecordNotFound（记录未找到）错误
+		Colorful:                  false,          // 禁用彩色打印
 	})
-	logout = &log
+	SetDBLoger(log)
 	return *logout
 }
 
@@ -56,6 +67,8 @@ func GetDBLoger() logger.Interface {
 }
 
 // 设置自定义日志
+// 输出前会按脱敏策略处理
 func SetDBLoger(log logger.Interface) {
+	log = newRedactLogger(log)
 	logout = &log
 }
@@ -10,7 +10,7 @@ var ctx = context.Background()
 
 // 错误
 func Errorln(v ...any) error {
-	GetDBLoger().Error(ctx, "%s", fmt.Sprint(v...))
+	GetDBLoger().Error(ctx, "%s", fmt.Sprint(GetRedactPolicy().redactAll(v)...))
 	return errors.New(fmt.Sprint(v...))
 }
 
@@ -19,10 +19,15 @@ func Error(v ...any) error {
 }
 
 func Errorf(format string, v ...any) error {
-	GetDBLoger().Error(ctx, fmt.Sprintf(format, v...))
+	GetDBLoger().Error(ctx, format, v...)
 	return fmt.Errorf(format, v...)
 }
 
+// 警告
+func Warnf(format string, v ...any) {
+	GetDBLoger().Warn(ctx, format, v...)
+}
+
 // 调试
 func Debugf(format string, v ...any) {
 	GetDBLoger().Info(ctx, format, v...)
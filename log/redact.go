@@ -0,0 +1,586 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lfhy/morm/schema"
+	"go.mongodb.org/mongo-driver/bson"
+	"gorm.io/gorm/logger"
+	gschema "gorm.io/gorm/schema"
+)
+
+// 默认脱敏后的值
+const DefaultMask = "***"
+
+// 脱敏策略
+// 字段名匹配 Fields 或带有 morm:"sensitive" 标签的字段 其值在日志中替换为 Mask
+// 匹配 Patterns 的内容 如手机号 在任意日志输出中替换为 Mask
+type RedactPolicy struct {
+	// 敏感字段名 匹配列名 bson 名或结构体字段名 不区分大小写
+	Fields []string
+	// 敏感内容正则
+	Patterns []*regexp.Regexp
+	// 脱敏后的值 默认为 ***
+	Mask string
+
+	fields map[string]bool
+}
+
+var (
+	redactPolicy atomic.Pointer[RedactPolicy]
+	// 带有 morm:"sensitive" 标签的字段 键为 表名.列名
+	sensitiveFields sync.Map
+	// 带有 morm:"sensitive" 标签的列名 表名未知时使用
+	sensitiveColumns sync.Map
+	// 已注册模型的表名
+	sensitiveTables sync.Map
+	// 已解析过标签的类型
+	sensitiveTypes sync.Map
+)
+
+// 设置脱敏策略 传入 nil 时只对带有 morm:"sensitive" 标签的字段脱敏
+func SetRedactPolicy(p *RedactPolicy) {
+	if p == nil {
+		redactPolicy.Store(nil)
+		return
+	}
+	np := *p
+	if np.Mask == "" {
+		np.Mask = DefaultMask
+	}
+	np.fields = make(map[string]bool, len(np.Fields))
+	for _, f := range np.Fields {
+		np.fields[strings.ToLower(f)] = true
+	}
+	redactPolicy.Store(&np)
+}
+
+// 获取当前脱敏策略
+func GetRedactPolicy() *RedactPolicy {
+	if p := redactPolicy.Load(); p != nil {
+		return p
+	}
+	return &RedactPolicy{Mask: DefaultMask}
+}
+
+// 从配置的 redact_fields 与 redact_patterns 设置脱敏策略
+func initRedactPolicy() error {
+	fields := confStrings("redact_fields")
+	var patterns []*regexp.Regexp
+	for _, p := range confStrings("redact_patterns") {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return err
+		}
+		patterns = append(patterns, re)
+	}
+	if len(fields) > 0 || len(patterns) > 0 {
+		SetRedactPolicy(&RedactPolicy{Fields: fields, Patterns: patterns})
+	}
+	return nil
+}
+
+// 注册模型中带有 morm:"sensitive" 标签的字段
+func RegisterSensitive(models ...any) {
+	for _, model := range models {
+		sensitiveFieldIndex(reflect.TypeOf(model))
+	}
+}
+
+// 字段是否敏感 name 可以带表名前缀 如 users.password
+// 带有 morm:"sensitive" 标签的字段只在所属的表中敏感 表名未知时在所有表中敏感
+func (p *RedactPolicy) IsSensitive(name string) bool {
+	name = strings.ToLower(name)
+	table := ""
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		table, name = name[:i], name[i+1:]
+		// 去掉库名前缀
+		if j := strings.LastIndexByte(table, '.'); j >= 0 {
+			table = table[j+1:]
+		}
+	}
+	return p.isSensitive(table, name)
+}
+
+func (p *RedactPolicy) isSensitive(table, name string) bool {
+	name = strings.ToLower(name)
+	if p.isPolicyField(name) {
+		return true
+	}
+	table = strings.ToLower(table)
+	if _, ok := sensitiveTables.Load(table); ok {
+		_, ok := sensitiveFields.Load(table + "." + name)
+		return ok
+	}
+	_, ok := sensitiveColumns.Load(name)
+	return ok
+}
+
+// 是否为策略中的敏感字段 name 为小写
+func (p *RedactPolicy) isPolicyField(name string) bool {
+	name = strings.ToLower(name)
+	if p.fields != nil {
+		if p.fields[name] {
+			return true
+		}
+	} else {
+		// 未通过 SetRedactPolicy 设置的策略
+		for _, f := range p.Fields {
+			if strings.EqualFold(f, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *RedactPolicy) mask() string {
+	if p.Mask == "" {
+		return DefaultMask
+	}
+	return p.Mask
+}
+
+// 对字符串中匹配正则的内容脱敏
+func (p *RedactPolicy) RedactString(s string) string {
+	for _, re := range p.Patterns {
+		s = re.ReplaceAllString(s, p.mask())
+	}
+	return s
+}
+
+// 标明所属表的值 其中的字段按该表带有 morm:"sensitive" 标签的字段脱敏
+type tableValue struct {
+	table string
+	v     any
+}
+
+// 标明日志中的 map 或 bson 文档所属的表或集合
+func Table(table string, v any) any {
+	return tableValue{table: table, v: v}
+}
+
+func (t tableValue) Format(f fmt.State, verb rune) {
+	fmt.Fprintf(f, fmt.FormatString(f, verb), GetRedactPolicy().redact(t.table, t.v))
+}
+
+// 对值脱敏 返回脱敏后的副本
+// 支持 map bson.D 切片与结构体 其余类型只对字符串做正则脱敏
+// map 与 bson 文档没有通过 Table 标明表名时 按所有表的敏感字段脱敏
+func (p *RedactPolicy) Redact(v any) any {
+	return p.redact("", v)
+}
+
+func (p *RedactPolicy) redact(table string, v any) any {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case tableValue:
+		return p.redact(val.table, val.v)
+	case string:
+		return p.RedactString(val)
+	case bson.M:
+		return bson.M(p.redactMap(table, val))
+	case map[string]any:
+		return p.redactMap(table, val)
+	case bson.D:
+		d := make(bson.D, len(val))
+		for i, e := range val {
+			d[i] = bson.E{Key: e.Key, Value: p.redactField(table, e.Key, e.Value)}
+		}
+		return d
+	case bson.A:
+		return bson.A(p.redactSlice(table, val))
+	case []any:
+		return p.redactSlice(table, val)
+	}
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.Kind() == reflect.Struct:
+		return p.redactStruct(rv).Interface()
+	case rv.Kind() == reflect.Ptr && !rv.IsNil() && rv.Elem().Kind() == reflect.Struct:
+		return p.redactStruct(rv.Elem()).Addr().Interface()
+	}
+	return v
+}
+
+func (p *RedactPolicy) redactAll(v []any) []any {
+	out := make([]any, len(v))
+	for i, arg := range v {
+		out[i] = p.Redact(arg)
+	}
+	return out
+}
+
+func (p *RedactPolicy) redactField(table, key string, v any) any {
+	// 嵌套文档的字段按最后一段判断
+	name := key
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+	if p.isSensitive(table, name) {
+		return p.mask()
+	}
+	return p.redact(table, v)
+}
+
+func (p *RedactPolicy) redactMap(table string, m map[string]any) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		out[k] = p.redactField(table, k, v)
+	}
+	return out
+}
+
+func (p *RedactPolicy) redactSlice(table string, s []any) []any {
+	out := make([]any, len(s))
+	for i, v := range s {
+		out[i] = p.redact(table, v)
+	}
+	return out
+}
+
+// 复制结构体并清除敏感字段 字符串字段替换为 Mask 其余置为零值
+func (p *RedactPolicy) redactStruct(rv reflect.Value) reflect.Value {
+	out := reflect.New(rv.Type()).Elem()
+	out.Set(rv)
+	typ := rv.Type()
+	tagged := sensitiveFieldIndex(typ)
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		field := out.Field(i)
+		if tagged[i] || p.isPolicyField(sf.Name) || p.isPolicyField(fieldColumn(sf)) {
+			if field.Kind() == reflect.String {
+				field.SetString(p.mask())
+			} else {
+				field.Set(reflect.Zero(sf.Type))
+			}
+			continue
+		}
+		switch {
+		case field.Kind() == reflect.String:
+			field.SetString(p.RedactString(field.String()))
+		case field.Kind() == reflect.Struct && sf.Type != reflect.TypeOf(time.Time{}):
+			field.Set(p.redactStruct(field))
+		}
+	}
+	return out
+}
+
+// 解析结构体中带有 morm:"sensitive" 标签的字段 返回字段下标
+func sensitiveFieldIndex(typ reflect.Type) map[int]bool {
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil
+	}
+	if v, ok := sensitiveTypes.Load(typ); ok {
+		return v.(map[int]bool)
+	}
+	index := make(map[int]bool)
+	tables := modelTables(typ)
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		field := schema.ParseField(sf)
+		if !field.Has("sensitive") {
+			continue
+		}
+		index[i] = true
+		names := []string{sf.Name, fieldColumn(sf)}
+		if field.BSON != "" {
+			names = append(names, field.BSON)
+		}
+		for _, name := range names {
+			name = strings.ToLower(name)
+			sensitiveColumns.Store(name, true)
+			for _, table := range tables {
+				sensitiveFields.Store(table+"."+name, true)
+			}
+		}
+	}
+	// 字段登记后再登记表名 避免并发时按表名查不到字段
+	for _, table := range tables {
+		sensitiveTables.Store(table, true)
+	}
+	sensitiveTypes.Store(typ, index)
+	return index
+}
+
+// 模型的表名 有 TableName 方法时使用该方法 否则为 gorm 默认表名与 MongoDB 默认集合名
+func modelTables(typ reflect.Type) []string {
+	if t, ok := reflect.New(typ).Interface().(interface{ TableName() string }); ok {
+		return []string{strings.ToLower(t.TableName())}
+	}
+	if typ.Name() == "" {
+		return nil
+	}
+	return []string{strings.ToLower(gschema.NamingStrategy{}.TableName(typ.Name())), strings.ToLower(typ.Name())}
+}
+
+// 字段对应的列名 依次取 morm column gorm column bson 名与默认命名
+func fieldColumn(sf reflect.StructField) string {
+	field := schema.ParseField(sf)
+	if field.Column != "" {
+		return field.Column
+	}
+	if field.BSON != "" {
+		return field.BSON
+	}
+	return gschema.NamingStrategy{}.ColumnName("", sf.Name)
+}
+
+// 对 SQL 参数脱敏
+// 根据 SQL 推断每个占位符对应的列 敏感列的参数替换为 Mask 字符串参数再做正则脱敏
+func (p *RedactPolicy) ParamsFilter(ctx context.Context, sql string, params ...any) (string, []any) {
+	columns := sqlParamColumns(sql)
+	table := sqlTable(sql)
+	out := make([]any, len(params))
+	for i, param := range params {
+		if i < len(columns) && columns[i] != "" && p.paramSensitive(table, columns[i]) {
+			out[i] = p.mask()
+			continue
+		}
+		if s, ok := param.(string); ok {
+			out[i] = p.RedactString(s)
+			continue
+		}
+		out[i] = param
+	}
+	return sql, out
+}
+
+// 参数对应的列是否敏感 列名带表名前缀时按前缀的表判断 否则按语句的主表判断
+func (p *RedactPolicy) paramSensitive(table, column string) bool {
+	if strings.Contains(column, ".") {
+		return p.IsSensitive(column)
+	}
+	return p.isSensitive(table, column)
+}
+
+// 带脱敏的 gorm 日志
+// 实现 gorm 的 ParamsFilter 并对输出的 SQL 与消息做正则脱敏
+type redactLogger struct {
+	logger.Interface
+}
+
+func newRedactLogger(l logger.Interface) logger.Interface {
+	if _, ok := l.(redactLogger); ok {
+		return l
+	}
+	return redactLogger{Interface: l}
+}
+
+func (l redactLogger) LogMode(level logger.LogLevel) logger.Interface {
+	return redactLogger{Interface: l.Interface.LogMode(level)}
+}
+
+func (l redactLogger) Info(ctx context.Context, msg string, data ...any) {
+	l.Interface.Info(ctx, msg, redactArgs(data)...)
+}
+
+func (l redactLogger) Warn(ctx context.Context, msg string, data ...any) {
+	l.Interface.Warn(ctx, msg, redactArgs(data)...)
+}
+
+func (l redactLogger) Error(ctx context.Context, msg string, data ...any) {
+	l.Interface.Error(ctx, msg, redactArgs(data)...)
+}
+
+func (l redactLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	l.Interface.Trace(ctx, begin, func() (string, int64) {
+		sql, rows := fc()
+		return GetRedactPolicy().RedactString(sql), rows
+	}, err)
+}
+
+func (l redactLogger) ParamsFilter(ctx context.Context, sql string, params ...any) (string, []any) {
+	if filter, ok := l.Interface.(interface {
+		ParamsFilter(ctx context.Context, sql string, params ...any) (string, []any)
+	}); ok {
+		sql, params = filter.ParamsFilter(ctx, sql, params...)
+	}
+	return GetRedactPolicy().ParamsFilter(ctx, sql, params...)
+}
+
+// 延迟脱敏 日志实际输出时才对参数脱敏 不输出的日志没有额外开销
+func redactArgs(args []any) []any {
+	out := make([]any, len(args))
+	for i, arg := range args {
+		out[i] = lazyRedact{v: arg}
+	}
+	return out
+}
+
+type lazyRedact struct {
+	v any
+}
+
+func (r lazyRedact) Format(f fmt.State, verb rune) {
+	fmt.Fprintf(f, fmt.FormatString(f, verb), GetRedactPolicy().Redact(r.v))
+}
+
+// 占位符前不作为列名的关键字
+var sqlKeywords = map[string]bool{
+	"and": true, "or": true, "not": true, "in": true, "like": true, "between": true,
+	"is": true, "null": true, "where": true, "set": true, "values": true, "having": true,
+	"on": true, "when": true, "then": true, "else": true, "case": true, "select": true,
+}
+
+// 占位符前出现时重置列名的关键字
+var sqlResetKeywords = map[string]bool{
+	"limit": true, "offset": true, "from": true, "join": true, "order": true, "group": true,
+}
+
+// 推断 SQL 中每个 ? 占位符对应的列名 无法推断时为空
+// 带表名前缀的列返回 表名.列名
+func sqlParamColumns(sql string) []string {
+	var (
+		columns    []string
+		last       string
+		prefix     string
+		insertCols []string
+		inValues   bool
+		valueIndex int
+		depth      int
+	)
+	upper := strings.ToUpper(strings.TrimSpace(sql))
+	isInsert := strings.HasPrefix(upper, "INSERT") || strings.HasPrefix(upper, "REPLACE")
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '?':
+			if inValues && len(insertCols) > 0 {
+				columns = append(columns, insertCols[valueIndex%len(insertCols)])
+				valueIndex++
+			} else {
+				columns = append(columns, last)
+			}
+		case c == '\'':
+			// 跳过字符串字面量
+			for i++; i < len(sql) && sql[i] != '\''; i++ {
+			}
+		case c == '`' || c == '"':
+			end := strings.IndexByte(sql[i+1:], c)
+			if end < 0 {
+				return columns
+			}
+			name := sql[i+1 : i+1+end]
+			i += end + 1
+			if i+1 < len(sql) && sql[i+1] == '.' {
+				// 表名前缀
+				prefix = name
+				continue
+			}
+			last = qualify(prefix, name)
+			prefix = ""
+			if isInsert && !inValues && depth == 1 {
+				insertCols = append(insertCols, name)
+			}
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case isIdentStart(c):
+			j := i
+			for j < len(sql) && isIdentPart(sql[j]) {
+				j++
+			}
+			word := sql[i:j]
+			lower := strings.ToLower(word)
+			i = j - 1
+			switch {
+			case lower == "values" && isInsert:
+				inValues = true
+			case inValues && (lower == "on" || lower == "returning"):
+				// ON DUPLICATE KEY UPDATE / ON CONFLICT 之后按普通语句推断
+				inValues = false
+				insertCols = nil
+				last = ""
+			case sqlResetKeywords[lower]:
+				last = ""
+			case sqlKeywords[lower]:
+			case j < len(sql) && sql[j] == '.':
+				// 表名前缀
+				prefix = word
+			default:
+				last = qualify(prefix, word)
+				prefix = ""
+				if isInsert && !inValues && depth == 1 {
+					insertCols = append(insertCols, word)
+				}
+			}
+		}
+	}
+	return columns
+}
+
+func qualify(table, column string) string {
+	if table == "" {
+		return column
+	}
+	return table + "." + column
+}
+
+// 语句的主表 即第一个 INTO UPDATE 或 FROM 之后的表名 无法推断时为空
+func sqlTable(sql string) string {
+	want := false
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '\'':
+			for i++; i < len(sql) && sql[i] != '\''; i++ {
+			}
+		case c == '(':
+			// FROM 之后为子查询
+			want = false
+		case c == '`' || c == '"':
+			end := strings.IndexByte(sql[i+1:], c)
+			if end < 0 {
+				return ""
+			}
+			name := sql[i+1 : i+1+end]
+			i += end + 1
+			// 跳过库名前缀
+			if want && !(i+1 < len(sql) && sql[i+1] == '.') {
+				return name
+			}
+		case isIdentStart(c):
+			j := i
+			for j < len(sql) && isIdentPart(sql[j]) {
+				j++
+			}
+			word := sql[i:j]
+			i = j - 1
+			if want {
+				if j < len(sql) && sql[j] == '.' {
+					continue
+				}
+				return word
+			}
+			switch strings.ToLower(word) {
+			case "into", "update", "from":
+				want = true
+			}
+		}
+	}
+	return ""
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,213 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/lfhy/morm/conf"
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 默认慢查询阈值
+const DefaultSlowThreshold = 200 * time.Millisecond
+
+var slogger atomic.Pointer[slog.Logger]
+
+// 设置结构化日志
+// 设置后 SQL 日志通过 gorm 适配器输出到 l Mongo 日志与每个操作的结构化日志也输出到 l
+// 传入 nil 时恢复使用 gorm 日志
+func SetLogger(l *slog.Logger) {
+	slogger.Store(l)
+	if l == nil {
+		// 恢复为按配置创建的 gorm 日志
+		if conf.IsInited() {
+			logout = nil
+			InitDBLoger()
+		} else {
+			SetDBLoger(logger.Default)
+		}
+		return
+	}
+	slow, ignoreNotFound := loadLogConfig()
+	SetDBLoger(NewGormLogger(l, logger.Config{
+		SlowThreshold:             slow,
+		LogLevel:                  logLevel(),
+		IgnoreRecordNotFoundError: ignoreNotFound,
+	}))
+}
+
+// 获取结构化日志 未设置时返回 nil
+func GetLogger() *slog.Logger {
+	return slogger.Load()
+}
+
+// 慢查询阈值与是否忽略未查到数据的错误
+type logSettings struct {
+	slow           time.Duration
+	ignoreNotFound bool
+}
+
+// 缓存的日志配置 在 SetLogger 与 InitDBLoger 中读取 避免每个操作读取配置
+var settings atomic.Pointer[logSettings]
+
+// 缓存的日志配置 未缓存时读取配置
+func logConfig() (slow time.Duration, ignoreNotFound bool) {
+	if s := settings.Load(); s != nil {
+		return s.slow, s.ignoreNotFound
+	}
+	return loadLogConfig()
+}
+
+// 从配置中读取慢查询阈值与是否忽略未查到数据的错误并缓存
+func loadLogConfig() (slow time.Duration, ignoreNotFound bool) {
+	slow = DefaultSlowThreshold
+	if conf.IsInited() {
+		if d := conf.ReadConfigToTimeDuration("db", "slow_threshold"); d > 0 {
+			slow = d
+		}
+		ignoreNotFound = conf.ReadConfigToBool("db", "ignore_record_not_found")
+	}
+	settings.Store(&logSettings{slow: slow, ignoreNotFound: ignoreNotFound})
+	return
+}
+
+// 读取 [db] 中的字符串数组配置
+func confStrings(key string) []string {
+	if !conf.IsInited() {
+		return nil
+	}
+	return conf.ReadConfigToStringSlice("db", key)
+}
+
+func logLevel() logger.LogLevel {
+	if !conf.IsInited() {
+		return 0
+	}
+	return logger.LogLevel(conf.ReadConfigToInt("db", "loglevel"))
+}
+
+// 是否为未查到数据的错误
+func IsNotFound(err error) bool {
+	return errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, mongo.ErrNoDocuments)
+}
+
+// 记录一次操作的结构化日志
+// 出错时为 Error 级别 超过慢查询阈值时为 Warn 级别 其余为 Debug 级别
+func Operation(ctx context.Context, op *types.Operation, err error) {
+	l := GetLogger()
+	if l == nil {
+		return
+	}
+	slow, ignoreNotFound := logConfig()
+	attrs := []slog.Attr{
+		slog.String("backend", string(op.Backend)),
+		slog.String("table", op.Table),
+		slog.String("op", string(op.Type)),
+		slog.Duration("duration", op.Duration),
+		slog.Int64("rows", op.Rows),
+	}
+	switch {
+	case err != nil && !(ignoreNotFound && IsNotFound(err)):
+		attrs = append(attrs, slog.String("error", err.Error()))
+		l.LogAttrs(ctx, slog.LevelError, "morm operation failed", attrs...)
+	case slow > 0 && op.Duration >= slow:
+		attrs = append(attrs, slog.Duration("threshold", slow))
+		l.LogAttrs(ctx, slog.LevelWarn, "morm slow operation", attrs...)
+	default:
+		l.LogAttrs(ctx, slog.LevelDebug, "morm operation", attrs...)
+	}
+}
+
+// 基于 slog 的 gorm 日志适配器
+type GormLogger struct {
+	logger *slog.Logger
+	logger.Config
+}
+
+// 创建 gorm 日志适配器
+// LogLevel 为 0 时使用 Info 由 slog 的 Handler 决定是否输出
+func NewGormLogger(l *slog.Logger, config logger.Config) *GormLogger {
+	if config.LogLevel == 0 {
+		config.LogLevel = logger.Info
+	}
+	return &GormLogger{logger: l, Config: config}
+}
+
+func (l *GormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	nl := *l
+	nl.LogLevel = level
+	return &nl
+}
+
+func (l *GormLogger) Info(ctx context.Context, msg string, data ...any) {
+	if l.LogLevel >= logger.Info {
+		l.logger.DebugContext(ctx, fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *GormLogger) Warn(ctx context.Context, msg string, data ...any) {
+	if l.LogLevel >= logger.Warn {
+		l.logger.WarnContext(ctx, fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *GormLogger) Error(ctx context.Context, msg string, data ...any) {
+	if l.LogLevel >= logger.Error {
+		l.logger.ErrorContext(ctx, fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.LogLevel <= logger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && l.LogLevel >= logger.Error && !(l.IgnoreRecordNotFoundError && errors.Is(err, gorm.ErrRecordNotFound)):
+		sql, rows := fc()
+		l.logger.LogAttrs(ctx, slog.LevelError, "sql failed", l.traceAttrs(sql, rows, elapsed, slog.String("error", err.Error()))...)
+	case l.SlowThreshold > 0 && elapsed > l.SlowThreshold && l.LogLevel >= logger.Warn:
+		sql, rows := fc()
+		l.logger.LogAttrs(ctx, slog.LevelWarn, "slow sql", l.traceAttrs(sql, rows, elapsed, slog.Duration("threshold", l.SlowThreshold))...)
+	case l.LogLevel >= logger.Info:
+		sql, rows := fc()
+		l.logger.LogAttrs(ctx, slog.LevelDebug, "sql", l.traceAttrs(sql, rows, elapsed)...)
+	}
+}
+
+func (l *GormLogger) traceAttrs(sql string, rows int64, elapsed time.Duration, extra ...slog.Attr) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("sql", sql),
+		slog.Duration("duration", elapsed),
+		slog.Int64("rows", rows),
+	}
+	return append(attrs, extra...)
+}
+
+// 慢查询日志 格式与 gorm 的慢 SQL 日志一致
+// 设置了结构化日志时输出到结构化日志
+func Slow(ctx context.Context, backend types.DBType, table, statement string, elapsed, threshold time.Duration, rows int64) {
+	if l := GetLogger(); l != nil {
+		l.LogAttrs(ctx, slog.LevelWarn, "slow query",
+			slog.String("backend", string(backend)),
+			slog.String("table", table),
+			slog.String("statement", statement),
+			slog.Duration("duration", elapsed),
+			slog.Int64("rows", rows),
+			slog.Duration("threshold", threshold),
+		)
+		return
+	}
+	rowsStr := "-"
+	if rows >= 0 {
+		rowsStr = fmt.Sprint(rows)
+	}
+	GetDBLoger().Warn(ctx, "SLOW QUERY >= %v\n[%.3fms] [rows:%v] %s", threshold, float64(elapsed.Nanoseconds())/1e6, rowsStr, statement)
+}
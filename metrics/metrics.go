@@ -0,0 +1,191 @@
+// Prometheus 指标
+//
+// 记录每个操作的次数 耗时与错误 并导出 SQL 连接池状态与 Mongo 连接池事件
+//
+//	c, _ := metrics.New(prometheus.DefaultRegisterer)
+//	c.InstrumentMongo() // 需在 morm.Init 前调用
+//	orm := morm.Init(...)
+//	c.Instrument(orm)
+package metrics
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lfhy/morm/db/mongodb"
+	"github.com/lfhy/morm/db/sqlorm"
+	"github.com/lfhy/morm/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gorm.io/gorm"
+)
+
+type config struct {
+	namespace string
+	buckets   []float64
+}
+
+type Option func(*config)
+
+// 指标名前缀 默认为 morm
+func WithNamespace(namespace string) Option {
+	return func(c *config) {
+		c.namespace = namespace
+	}
+}
+
+// 耗时直方图的桶 单位秒 默认为 prometheus.DefBuckets
+func WithBuckets(buckets []float64) Option {
+	return func(c *config) {
+		c.buckets = buckets
+	}
+}
+
+type Collector struct {
+	reg prometheus.Registerer
+
+	ops      *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+
+	poolCheckedOut *prometheus.GaugeVec
+	poolWaiting    *prometheus.GaugeVec
+	poolCreated    *prometheus.CounterVec
+	poolClosed     *prometheus.CounterVec
+}
+
+// 创建指标收集器并注册到 reg
+func New(reg prometheus.Registerer, opts ...Option) (*Collector, error) {
+	cfg := &config{
+		namespace: "morm",
+		buckets:   prometheus.DefBuckets,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	labels := []string{"backend", "table", "op"}
+	c := &Collector{
+		reg: reg,
+		ops: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.namespace,
+			Name:      "operations_total",
+			Help:      "数据库操作次数",
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.namespace,
+			Name:      "operation_errors_total",
+			Help:      "数据库操作错误次数",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: cfg.namespace,
+			Name:      "operation_duration_seconds",
+			Help:      "数据库操作耗时",
+			Buckets:   cfg.buckets,
+		}, labels),
+		poolCheckedOut: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: cfg.namespace,
+			Subsystem: "mongo_pool",
+			Name:      "checked_out_connections",
+			Help:      "Mongo 连接池中已取出的连接数",
+		}, []string{"address"}),
+		poolWaiting: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: cfg.namespace,
+			Subsystem: "mongo_pool",
+			Name:      "waiting_requests",
+			Help:      "Mongo 连接池中等待取出连接的请求数",
+		}, []string{"address"}),
+		poolCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.namespace,
+			Subsystem: "mongo_pool",
+			Name:      "connections_created_total",
+			Help:      "Mongo 连接池创建的连接数",
+		}, []string{"address"}),
+		poolClosed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: cfg.namespace,
+			Subsystem: "mongo_pool",
+			Name:      "connections_closed_total",
+			Help:      "Mongo 连接池关闭的连接数",
+		}, []string{"address"}),
+	}
+	for _, collector := range []prometheus.Collector{
+		c.ops, c.errors, c.duration,
+		c.poolCheckedOut, c.poolWaiting, c.poolCreated, c.poolClosed,
+	} {
+		if err := reg.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// 记录操作次数 耗时与错误的中间件
+func (c *Collector) Middleware() types.Middleware {
+	return func(next types.Handler) types.Handler {
+		return func(ctx context.Context, op *types.Operation) error {
+			err := next(ctx, op)
+			labels := prometheus.Labels{
+				"backend": string(op.Backend),
+				"table":   op.Table,
+				"op":      string(op.Type),
+			}
+			c.ops.With(labels).Inc()
+			c.duration.With(labels).Observe(op.Duration.Seconds())
+			if err != nil && !isNotFound(err) {
+				c.errors.With(labels).Inc()
+			}
+			return err
+		}
+	}
+}
+
+// 为已初始化的连接启用指标
+// SQL 连接会额外注册 sql.DBStats 指标 Mongo 连接池指标需在初始化前调用 InstrumentMongo
+func (c *Collector) Instrument(orm types.ORM) error {
+	if conn, ok := orm.(*sqlorm.DBConn); ok {
+		sqlDB, err := conn.DB.DB()
+		if err != nil {
+			return err
+		}
+		dbName := conn.Migrator().CurrentDatabase()
+		if err := c.reg.Register(collectors.NewDBStatsCollector(sqlDB, dbName)); err != nil {
+			return err
+		}
+	}
+	orm.Use(c.Middleware())
+	return nil
+}
+
+// 为之后初始化的 Mongo 连接记录连接池指标
+func (c *Collector) InstrumentMongo() {
+	mongodb.AddPoolMonitor(c.PoolMonitor())
+}
+
+// Mongo 连接池监听
+func (c *Collector) PoolMonitor() *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(evt *event.PoolEvent) {
+			switch evt.Type {
+			case event.GetStarted:
+				c.poolWaiting.WithLabelValues(evt.Address).Inc()
+			case event.GetSucceeded:
+				c.poolWaiting.WithLabelValues(evt.Address).Dec()
+				c.poolCheckedOut.WithLabelValues(evt.Address).Inc()
+			case event.GetFailed:
+				c.poolWaiting.WithLabelValues(evt.Address).Dec()
+			case event.ConnectionReturned:
+				c.poolCheckedOut.WithLabelValues(evt.Address).Dec()
+			case event.ConnectionCreated:
+				c.poolCreated.WithLabelValues(evt.Address).Inc()
+			case event.ConnectionClosed:
+				c.poolClosed.WithLabelValues(evt.Address).Inc()
+			}
+		},
+	}
+}
+
+// 未查到数据不计为错误
+func isNotFound(err error) bool {
+	return errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, mongo.ErrNoDocuments)
+}
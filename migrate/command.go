@@ -0,0 +1,228 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/lfhy/morm/conf"
+	"github.com/lfhy/morm/types"
+)
+
+// 命令行用法
+const Usage = `usage: migrate [flags] <command> [args]
+
+commands:
+  up [version]     执行未执行的迁移 传入版本号时只执行到该版本
+  down [version]   回滚最后一个迁移 传入版本号时回滚到该版本 0 回滚全部
+  status           查看迁移状态
+  create <name>    在迁移目录中生成迁移文件
+
+flags:
+`
+
+// 执行迁移命令 在项目自己的命令中导入迁移目录后调用
+// up down status 需要迁移已经通过 Register 注册 即在调用方的程序中导入迁移目录
+// connect 只在需要连接数据库时调用 为 nil 时只支持 create
+// 配置文件中 [migrate] 的 dir table lock_timeout 作为参数默认值
+func RunCommand(ctx context.Context, args []string, out io.Writer, connect func() (types.ORM, error)) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(out)
+	dir := fs.String("dir", configString("dir", "./migrations"), "迁移文件目录")
+	table := fs.String("table", configString("table", ""), "历史表或集合 默认 morm_migrations")
+	lockTimeout := fs.Duration("lock-timeout", configDuration("lock_timeout"), "等待锁的时间 默认 1m")
+	fs.Usage = func() {
+		fmt.Fprint(out, Usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("migrate: missing command")
+	}
+	cmd, rest := fs.Arg(0), fs.Args()[1:]
+
+	if cmd == "create" {
+		if len(rest) == 0 {
+			return errors.New("migrate: create requires a name")
+		}
+		path, err := Create(*dir, strings.Join(rest, "_"), time.Now())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "created", path)
+		return nil
+	}
+
+	var version int64
+	if len(rest) > 0 {
+		v, err := strconv.ParseInt(rest[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("migrate: invalid version %q", rest[0])
+		}
+		version = v
+	}
+	switch cmd {
+	case "up", "down", "status":
+	default:
+		fs.Usage()
+		return fmt.Errorf("migrate: unknown command %q", cmd)
+	}
+	if connect == nil {
+		return fmt.Errorf("migrate: %s needs migrations compiled into the program, call migrate.RunCommand from your own main", cmd)
+	}
+
+	orm, err := connect()
+	if err != nil {
+		return err
+	}
+	m, err := New(orm, Options{Table: *table, LockTimeout: *lockTimeout})
+	if err != nil {
+		return err
+	}
+	var done []Migration
+	switch cmd {
+	case "up":
+		done, err = m.UpTo(ctx, version)
+	case "down":
+		if len(rest) > 0 {
+			done, err = m.DownTo(ctx, version)
+		} else {
+			done, err = m.Down(ctx)
+		}
+	case "status":
+		return printStatus(ctx, m, out)
+	}
+	for _, mg := range done {
+		fmt.Fprintln(out, cmd, mg)
+	}
+	if err == nil && len(done) == 0 {
+		fmt.Fprintln(out, "no migrations to", cmd)
+	}
+	return err
+}
+
+func printStatus(ctx context.Context, m *Migrator, out io.Writer) error {
+	list, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		fmt.Fprintln(out, "no migrations")
+		return nil
+	}
+	for _, s := range list {
+		state := "pending"
+		switch {
+		case s.Missing:
+			state = "missing " + s.AppliedAt.Format(time.DateTime)
+		case s.Applied:
+			state = "applied " + s.AppliedAt.Format(time.DateTime)
+		}
+		fmt.Fprintf(out, "%-40s %s\n", s.Migration, state)
+	}
+	return nil
+}
+
+func configString(key, def string) string {
+	if conf.IsInited() {
+		if v := conf.ReadConfigToString("migrate", key); v != "" {
+			return v
+		}
+	}
+	return def
+}
+
+func configDuration(key string) time.Duration {
+	if conf.IsInited() {
+		return conf.ReadConfigToTimeDuration("migrate", key)
+	}
+	return 0
+}
+
+var migrationTemplate = template.Must(template.New("migration").Parse(`package {{.Package}}
+
+import (
+	"context"
+
+	"github.com/lfhy/morm/migrate"
+)
+
+func init() {
+	migrate.Register(migrate.Migration{
+		Version: {{.Version}},
+		Name:    {{printf "%q" .Name}},
+		Up: func(ctx context.Context, db *migrate.DB) error {
+			return nil
+		},
+		Down: func(ctx context.Context, db *migrate.DB) error {
+			return nil
+		},
+	})
+}
+`))
+
+// 在 dir 中生成迁移文件 版本号为 now 的时间 返回文件路径
+// 包名为目录名
+func Create(dir, name string, now time.Time) (string, error) {
+	name = fileName(name)
+	if name == "" {
+		return "", errors.New("migrate: invalid migration name")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	pkg := fileName(filepath.Base(abs))
+	if pkg == "" || unicode.IsDigit(rune(pkg[0])) {
+		pkg = "migrations"
+	}
+	version := now.Format("20060102150405")
+	var buf bytes.Buffer
+	err = migrationTemplate.Execute(&buf, map[string]any{"Package": pkg, "Version": version, "Name": name})
+	if err != nil {
+		return "", err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, version+"_"+name+".go")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	_, err = f.Write(src)
+	return path, err
+}
+
+// 转换为小写加下划线的文件名
+func fileName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "_"):
+			b.WriteByte('_')
+		}
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
@@ -0,0 +1,397 @@
+// 版本化迁移
+//
+// 迁移按版本号从小到大执行 执行记录保存在历史表或集合中
+// 执行前会加锁 多个服务实例同时启动时同一个迁移只会执行一次
+// 持有锁期间后台定时刷新锁 执行时间超过 LockTTL 的迁移不会被其他实例接管
+// SQL 后端每个迁移在事务中执行 MongoDB 后端不使用事务 迁移失败时已执行的修改不会回滚
+//
+//	func init() {
+//		migrate.Register(migrate.Migration{
+//			Version: 20240101120000,
+//			Name:    "add_user_age",
+//			Up: func(ctx context.Context, db *migrate.DB) error {
+//				return db.SQL.Exec("ALTER TABLE users ADD COLUMN age INT").Error
+//			},
+//			Down: func(ctx context.Context, db *migrate.DB) error {
+//				return db.SQL.Exec("ALTER TABLE users DROP COLUMN age").Error
+//			},
+//		})
+//	}
+//
+//	m, err := migrate.New(orm, migrate.Options{})
+//	applied, err := m.Up(ctx)
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lfhy/morm/db/mongodb"
+	"github.com/lfhy/morm/db/sqlorm"
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gorm.io/gorm"
+)
+
+var (
+	// 等待锁超时
+	ErrLocked = errors.New("migrate: lock is held by another instance")
+	// 执行中锁被其他实例接管
+	ErrLockLost = errors.New("migrate: lock was taken over by another instance")
+	// 迁移没有 Down
+	ErrNoDown = errors.New("migrate: migration has no down")
+	// 历史记录中的迁移在代码中不存在
+	ErrMissing = errors.New("migrate: applied migration is missing")
+)
+
+// 迁移
+type Migration struct {
+	// 版本号 按从小到大执行 create 生成的版本号为时间 20060102150405
+	Version int64
+	// 名称
+	Name string
+	// 升级
+	Up func(ctx context.Context, db *DB) error
+	// 回滚
+	Down func(ctx context.Context, db *DB) error
+}
+
+func (m Migration) String() string {
+	return strconv.FormatInt(m.Version, 10) + "_" + m.Name
+}
+
+// 迁移中使用的连接
+type DB struct {
+	// 当前连接
+	// SQL 后端在事务中执行迁移 SQLite 等单连接数据库需要使用 SQL 而不是 ORM
+	ORM types.ORM
+	// SQL 后端为当前迁移的事务 迁移成功后与历史记录一起提交
+	// MySQL 的 DDL 会隐式提交 无法回滚
+	SQL *gorm.DB
+	// MongoDB 后端为当前数据库
+	// 迁移不在事务中执行 失败时已执行的修改不会回滚 需要迁移自身可以重复执行
+	Mongo *mongo.Database
+}
+
+// 迁移状态
+type Status struct {
+	Migration
+	// 是否已执行
+	Applied bool
+	// 执行时间
+	AppliedAt time.Time
+	// 历史记录中存在但代码中没有
+	Missing bool
+}
+
+// 配置
+type Options struct {
+	// 历史表或集合 默认 morm_migrations 锁使用 <Table>_lock
+	Table string
+	// 等待锁的时间 默认 1 分钟
+	LockTimeout time.Duration
+	// 锁过期时间 持有锁的实例异常退出后可以被接管 默认 10 分钟
+	// 持有锁期间每 LockTTL/3 刷新一次
+	LockTTL time.Duration
+}
+
+// 历史记录
+type record struct {
+	Version   int64
+	Name      string
+	AppliedAt time.Time
+}
+
+// 历史记录与锁的存储
+type store interface {
+	// 创建历史表与锁表
+	init(ctx context.Context) error
+	// 尝试加锁 锁被其他实例持有时返回 false
+	lock(ctx context.Context, owner string, ttl time.Duration) (bool, error)
+	unlock(ctx context.Context, owner string) error
+	// 已执行的迁移 按版本号排序
+	applied(ctx context.Context) ([]record, error)
+	// 执行迁移并写入或删除历史记录
+	run(ctx context.Context, m Migration, up bool) error
+}
+
+// 全局注册的迁移
+var (
+	registry     []Migration
+	registryLock sync.Mutex
+)
+
+// 注册迁移 一般在迁移文件的 init 中调用
+// 版本号重复时 panic
+func Register(migrations ...Migration) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	for _, m := range migrations {
+		for _, r := range registry {
+			if r.Version == m.Version {
+				panic(fmt.Sprintf("migrate: duplicate version %d", m.Version))
+			}
+		}
+		registry = append(registry, m)
+	}
+}
+
+// 已注册的迁移 按版本号排序
+func Registered() []Migration {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	return sortMigrations(registry)
+}
+
+func sortMigrations(migrations []Migration) []Migration {
+	list := append([]Migration(nil), migrations...)
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list
+}
+
+// 迁移执行器
+type Migrator struct {
+	store      store
+	migrations []Migration
+	opts       Options
+	owner      string
+}
+
+// 创建迁移执行器
+// 不传入 migrations 时使用 Register 注册的迁移
+func New(orm types.ORM, opts Options, migrations ...Migration) (*Migrator, error) {
+	if opts.Table == "" {
+		opts.Table = "morm_migrations"
+	}
+	if opts.LockTimeout == 0 {
+		opts.LockTimeout = time.Minute
+	}
+	if opts.LockTTL == 0 {
+		opts.LockTTL = 10 * time.Minute
+	}
+	if len(migrations) == 0 {
+		migrations = Registered()
+	}
+	migrations = sortMigrations(migrations)
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("migrate: duplicate version %d", migrations[i].Version)
+		}
+	}
+	m := &Migrator{migrations: migrations, opts: opts}
+	host, _ := os.Hostname()
+	m.owner = fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano())
+	switch conn := orm.(type) {
+	case *sqlorm.DBConn:
+		m.store = &sqlStore{orm: orm, db: conn.DB, table: opts.Table}
+	case *mongodb.DBConn:
+		m.store = &mongoStore{orm: orm, db: conn.Client.Database(conn.Database), collection: opts.Table}
+	default:
+		return nil, fmt.Errorf("migrate: unsupported orm %T", orm)
+	}
+	return m, nil
+}
+
+// 全部迁移
+func (m *Migrator) Migrations() []Migration {
+	return append([]Migration(nil), m.migrations...)
+}
+
+// 执行全部未执行的迁移 返回本次执行的迁移
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.UpTo(ctx, 0)
+}
+
+// 执行版本号不大于 version 的未执行迁移 version 为 0 时执行全部
+func (m *Migrator) UpTo(ctx context.Context, version int64) (done []Migration, err error) {
+	err = m.locked(ctx, func(ctx context.Context) error {
+		applied, err := m.appliedSet(ctx)
+		if err != nil {
+			return err
+		}
+		for _, mg := range m.migrations {
+			if version != 0 && mg.Version > version {
+				break
+			}
+			if _, ok := applied[mg.Version]; ok {
+				continue
+			}
+			if mg.Up == nil {
+				return fmt.Errorf("migrate: %s has no up", mg)
+			}
+			if err := m.run(ctx, mg, true); err != nil {
+				return err
+			}
+			done = append(done, mg)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// 回滚最后一个已执行的迁移
+func (m *Migrator) Down(ctx context.Context) ([]Migration, error) {
+	return m.down(ctx, func(i int, _ Migration) bool { return i == 0 })
+}
+
+// 回滚版本号大于 version 的全部迁移 version 为 0 时回滚全部
+func (m *Migrator) DownTo(ctx context.Context, version int64) ([]Migration, error) {
+	return m.down(ctx, func(_ int, mg Migration) bool { return mg.Version > version })
+}
+
+// 从最新的迁移开始回滚 match 返回 false 时停止
+func (m *Migrator) down(ctx context.Context, match func(int, Migration) bool) (done []Migration, err error) {
+	err = m.locked(ctx, func(ctx context.Context) error {
+		records, err := m.store.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for i := len(records) - 1; i >= 0; i-- {
+			mg, ok := m.find(records[i].Version)
+			if !ok {
+				mg = Migration{Version: records[i].Version, Name: records[i].Name}
+			}
+			if !match(len(records)-1-i, mg) {
+				break
+			}
+			if !ok {
+				return fmt.Errorf("%w: %s", ErrMissing, mg)
+			}
+			if mg.Down == nil {
+				return fmt.Errorf("%w: %s", ErrNoDown, mg)
+			}
+			if err := m.run(ctx, mg, false); err != nil {
+				return err
+			}
+			done = append(done, mg)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// 迁移状态 按版本号排序 包含历史记录中存在但代码中没有的迁移
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	if err := m.store.init(ctx); err != nil {
+		return nil, err
+	}
+	records, err := m.store.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Status, 0, len(m.migrations))
+	seen := make(map[int64]bool, len(records))
+	for _, r := range records {
+		seen[r.Version] = true
+		mg, ok := m.find(r.Version)
+		if !ok {
+			mg = Migration{Version: r.Version, Name: r.Name}
+		}
+		list = append(list, Status{Migration: mg, Applied: true, AppliedAt: r.AppliedAt, Missing: !ok})
+	}
+	for _, mg := range m.migrations {
+		if !seen[mg.Version] {
+			list = append(list, Status{Migration: mg})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+func (m *Migrator) find(version int64) (Migration, bool) {
+	for _, mg := range m.migrations {
+		if mg.Version == version {
+			return mg, true
+		}
+	}
+	return Migration{}, false
+}
+
+func (m *Migrator) appliedSet(ctx context.Context) (map[int64]record, error) {
+	records, err := m.store.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[int64]record, len(records))
+	for _, r := range records {
+		set[r.Version] = r
+	}
+	return set, nil
+}
+
+// 执行单个迁移
+func (m *Migrator) run(ctx context.Context, mg Migration, up bool) error {
+	if err := m.store.run(ctx, mg, up); err != nil {
+		action := "up"
+		if !up {
+			action = "down"
+		}
+		return fmt.Errorf("migrate: %s %s: %w", action, mg, err)
+	}
+	return nil
+}
+
+// 加锁后执行 fn 执行期间后台刷新锁
+// 锁被其他实例接管时取消 fn 的 ctx
+func (m *Migrator) locked(ctx context.Context, fn func(context.Context) error) error {
+	if err := m.store.init(ctx); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(m.opts.LockTimeout)
+	for {
+		ok, err := m.store.lock(ctx, m.owner, m.opts.LockTTL)
+		if err != nil {
+			return err
+		}
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			return ErrLocked
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+	defer m.store.unlock(context.WithoutCancel(ctx), m.owner)
+	ctx, cancel := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.heartbeat(ctx, cancel)
+	}()
+	err := fn(ctx)
+	cancel(nil)
+	<-done
+	if err != nil && errors.Is(context.Cause(ctx), ErrLockLost) {
+		return fmt.Errorf("%w: %w", ErrLockLost, err)
+	}
+	return err
+}
+
+// 定时刷新锁直到 ctx 结束
+func (m *Migrator) heartbeat(ctx context.Context, lost context.CancelCauseFunc) {
+	ticker := time.NewTicker(max(m.opts.LockTTL/3, time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// 刷新出错时等下次重试 锁已被接管时停止迁移
+		ok, err := m.store.lock(ctx, m.owner, m.opts.LockTTL)
+		if err == nil && !ok {
+			lost(ErrLockLost)
+			return
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package migrate
+
+import (
+	"context"
+	"time"
+
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoDB 历史记录
+type mongoRecord struct {
+	Version   int64     `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"applied_at"`
+}
+
+// 锁文档的 _id
+const mongoLockID = "lock"
+
+type mongoStore struct {
+	orm        types.ORM
+	db         *mongo.Database
+	collection string
+}
+
+func (s *mongoStore) history() *mongo.Collection {
+	return s.db.Collection(s.collection)
+}
+
+func (s *mongoStore) locks() *mongo.Collection {
+	return s.db.Collection(s.collection + "_lock")
+}
+
+// 集合在第一次写入时自动创建
+func (s *mongoStore) init(ctx context.Context) error {
+	return nil
+}
+
+func (s *mongoStore) lock(ctx context.Context, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	// 清理过期的锁
+	_, err := s.locks().DeleteOne(ctx, bson.M{"_id": mongoLockID, "locked_at": bson.M{"$lt": now.Add(-ttl)}})
+	if err != nil {
+		return false, err
+	}
+	// 已持有锁时刷新时间
+	result, err := s.locks().UpdateOne(ctx, bson.M{"_id": mongoLockID, "owner": owner}, bson.M{"$set": bson.M{"locked_at": now}})
+	if err != nil {
+		return false, err
+	}
+	if result.MatchedCount > 0 {
+		return true, nil
+	}
+	_, err = s.locks().InsertOne(ctx, bson.M{"_id": mongoLockID, "owner": owner, "locked_at": now})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *mongoStore) unlock(ctx context.Context, owner string) error {
+	_, err := s.locks().DeleteOne(ctx, bson.M{"_id": mongoLockID, "owner": owner})
+	return err
+}
+
+func (s *mongoStore) applied(ctx context.Context) ([]record, error) {
+	cursor, err := s.history().Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var rows []mongoRecord
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	records := make([]record, len(rows))
+	for i, r := range rows {
+		records[i] = record{Version: r.Version, Name: r.Name, AppliedAt: r.AppliedAt}
+	}
+	return records, nil
+}
+
+// MongoDB 不在事务中执行 迁移失败时不写入历史记录
+func (s *mongoStore) run(ctx context.Context, m Migration, up bool) error {
+	db := &DB{ORM: s.orm, Mongo: s.db}
+	if !up {
+		if err := m.Down(ctx, db); err != nil {
+			return err
+		}
+		_, err := s.history().DeleteOne(ctx, bson.M{"_id": m.Version})
+		return err
+	}
+	if err := m.Up(ctx, db); err != nil {
+		return err
+	}
+	_, err := s.history().InsertOne(ctx, mongoRecord{Version: m.Version, Name: m.Name, AppliedAt: time.Now()})
+	return err
+}
@@ -0,0 +1,101 @@
+package migrate
+
+import (
+	"context"
+	"time"
+
+	"github.com/lfhy/morm/types"
+	"gorm.io/gorm"
+)
+
+// SQL 历史记录
+type sqlRecord struct {
+	Version   int64     `gorm:"column:version;primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"column:name;size:255"`
+	AppliedAt time.Time `gorm:"column:applied_at"`
+}
+
+// SQL 锁 表中最多只有一行
+type sqlLock struct {
+	ID       int       `gorm:"column:id;primaryKey;autoIncrement:false"`
+	Owner    string    `gorm:"column:owner;size:255"`
+	LockedAt time.Time `gorm:"column:locked_at"`
+}
+
+type sqlStore struct {
+	orm   types.ORM
+	db    *gorm.DB
+	table string
+}
+
+func (s *sqlStore) lockTable() string {
+	return s.table + "_lock"
+}
+
+func (s *sqlStore) init(ctx context.Context) error {
+	db := s.db.WithContext(ctx)
+	if err := db.Table(s.table).AutoMigrate(&sqlRecord{}); err != nil {
+		return err
+	}
+	return db.Table(s.lockTable()).AutoMigrate(&sqlLock{})
+}
+
+func (s *sqlStore) lock(ctx context.Context, owner string, ttl time.Duration) (bool, error) {
+	db := s.db.WithContext(ctx).Table(s.lockTable())
+	now := time.Now()
+	// 清理过期的锁
+	if err := db.Where("id = ? AND locked_at < ?", 1, now.Add(-ttl)).Delete(&sqlLock{}).Error; err != nil {
+		return false, err
+	}
+	// 已持有锁时刷新时间
+	tx := s.db.WithContext(ctx).Table(s.lockTable()).Where("id = ? AND owner = ?", 1, owner).Update("locked_at", now)
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	if tx.RowsAffected > 0 {
+		return true, nil
+	}
+	err := s.db.WithContext(ctx).Table(s.lockTable()).Create(&sqlLock{ID: 1, Owner: owner, LockedAt: now}).Error
+	if err == nil {
+		return true, nil
+	}
+	// 主键冲突说明锁被其他实例持有
+	var count int64
+	if s.db.WithContext(ctx).Table(s.lockTable()).Where("id = ?", 1).Count(&count).Error == nil && count > 0 {
+		return false, nil
+	}
+	return false, err
+}
+
+func (s *sqlStore) unlock(ctx context.Context, owner string) error {
+	return s.db.WithContext(ctx).Table(s.lockTable()).Where("id = ? AND owner = ?", 1, owner).Delete(&sqlLock{}).Error
+}
+
+func (s *sqlStore) applied(ctx context.Context) ([]record, error) {
+	var rows []sqlRecord
+	if err := s.db.WithContext(ctx).Table(s.table).Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	records := make([]record, len(rows))
+	for i, r := range rows {
+		records[i] = record{Version: r.Version, Name: r.Name, AppliedAt: r.AppliedAt}
+	}
+	return records, nil
+}
+
+// 迁移与历史记录在同一个事务中执行
+func (s *sqlStore) run(ctx context.Context, m Migration, up bool) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		db := &DB{ORM: s.orm, SQL: tx}
+		if !up {
+			if err := m.Down(ctx, db); err != nil {
+				return err
+			}
+			return tx.Table(s.table).Where("version = ?", m.Version).Delete(&sqlRecord{}).Error
+		}
+		if err := m.Up(ctx, db); err != nil {
+			return err
+		}
+		return tx.Table(s.table).Create(&sqlRecord{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+	})
+}
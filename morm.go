@@ -2,12 +2,14 @@ package morm
 
 import (
 	"fmt"
+	"log/slog"
 	"sync"
 
 	"github.com/lfhy/morm/conf"
 	"github.com/lfhy/morm/db/mongodb"
 	"github.com/lfhy/morm/db/mysql"
 	"github.com/lfhy/morm/db/sqlite"
+	"github.com/lfhy/morm/db/sqlorm"
 	"github.com/lfhy/morm/log"
 	"github.com/spf13/viper"
 	"gorm.io/gorm/logger"
@@ -187,3 +189,16 @@ func GetDBLoger() logger.Interface {
 func InitDBLoger() logger.Interface {
 	return log.InitDBLoger()
 }
+
+// 设置结构化日志
+// 已初始化的 SQL 连接会同步使用基于 l 的 gorm 日志
+func SetLogger(l *slog.Logger) {
+	log.SetLogger(l)
+	if sqlorm.ORMConn != nil && sqlorm.ORMConn.DB != nil {
+		sqlorm.ORMConn.SetLogger(log.GetDBLoger())
+	}
+}
+
+func GetLogger() *slog.Logger {
+	return log.GetLogger()
+}
@@ -0,0 +1,107 @@
+package morm
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lfhy/morm/types"
+)
+
+// 默认健康检查间隔
+const DefaultHealthInterval = 10 * time.Second
+
+// 连接健康状态
+type HealthStatus struct {
+	// 是否可用
+	Healthy bool
+	// 最近一次检查的错误
+	Err error
+	// 进入当前状态的时间
+	Since time.Time
+	// 最近一次检查时的连接池状态
+	Stats types.PoolStats
+}
+
+// 后台健康检查
+// 定时 Ping 数据库 状态在可用与不可用之间变化时回调 onChange
+type HealthMonitor struct {
+	orm      ORM
+	interval time.Duration
+	onChange func(HealthStatus)
+
+	lock   sync.RWMutex
+	status HealthStatus
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// 启动健康检查 首次检查的结果也会回调 onChange
+// interval 为检查间隔 同时作为每次 Ping 的超时时间 不大于 0 时使用 DefaultHealthInterval
+func StartHealthMonitor(orm ORM, interval time.Duration, onChange func(HealthStatus)) *HealthMonitor {
+	if interval <= 0 {
+		interval = DefaultHealthInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &HealthMonitor{
+		orm:      orm,
+		interval: interval,
+		onChange: onChange,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	h.check(ctx, true)
+	go h.run(ctx)
+	return h
+}
+
+func (h *HealthMonitor) run(ctx context.Context) {
+	defer close(h.done)
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.check(ctx, false)
+		}
+	}
+}
+
+func (h *HealthMonitor) check(ctx context.Context, first bool) {
+	pingCtx, cancel := context.WithTimeout(ctx, h.interval)
+	err := h.orm.Ping(pingCtx)
+	cancel()
+	if ctx.Err() != nil {
+		return
+	}
+
+	h.lock.Lock()
+	changed := first || h.status.Healthy != (err == nil)
+	h.status.Healthy = err == nil
+	h.status.Err = err
+	h.status.Stats = h.orm.Stats()
+	if changed {
+		h.status.Since = time.Now()
+	}
+	status := h.status
+	h.lock.Unlock()
+
+	if changed && h.onChange != nil {
+		h.onChange(status)
+	}
+}
+
+// 当前状态
+func (h *HealthMonitor) Status() HealthStatus {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.status
+}
+
+// 停止健康检查
+func (h *HealthMonitor) Stop() {
+	h.cancel()
+	<-h.done
+}
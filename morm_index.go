@@ -0,0 +1,18 @@
+package morm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lfhy/morm/types"
+)
+
+// 同步模型声明的索引 目前只支持 MongoDB
+// 新建缺少的索引 模型没有声明的索引只在结果中报告
+func SyncIndexes(ctx context.Context, orm ORM, models ...any) ([]IndexReport, error) {
+	syncer, ok := orm.(types.IndexSyncer)
+	if !ok {
+		return nil, fmt.Errorf("morm: %T does not support SyncIndexes", orm)
+	}
+	return syncer.SyncIndexes(ctx, models...)
+}
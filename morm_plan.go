@@ -0,0 +1,85 @@
+package morm
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/lfhy/morm/types"
+)
+
+var (
+	modelsMu sync.Mutex
+	models   []any
+)
+
+// 注册需要检查结构的模型 plan 命令默认检查已注册的模型
+func RegisterModels(list ...any) {
+	modelsMu.Lock()
+	defer modelsMu.Unlock()
+	models = append(models, list...)
+}
+
+// 已注册的模型
+func RegisteredModels() []any {
+	modelsMu.Lock()
+	defer modelsMu.Unlock()
+	return append([]any(nil), models...)
+}
+
+// 对比模型与数据库结构 返回差异与需要执行的 SQL 或命令 不修改数据库
+// SQL 对比列 类型 可空性与索引 MongoDB 对比索引
+// 没有传入模型时使用 RegisterModels 注册的模型
+func Plan(ctx context.Context, orm ORM, list ...any) (*SchemaPlan, error) {
+	planner, ok := orm.(types.Planner)
+	if !ok {
+		return nil, fmt.Errorf("morm: %T does not support Plan", orm)
+	}
+	if len(list) == 0 {
+		list = RegisteredModels()
+	}
+	return planner.Plan(ctx, list...)
+}
+
+// plan 命令用法
+const PlanUsage = `usage: plan [flags]
+
+对比已注册的模型与数据库结构 输出差异与需要执行的语句
+存在差异时返回 ErrSchemaDrift 可用于 CI 检查
+
+flags:
+`
+
+// 执行 plan 命令 在项目自己的命令中通过 RegisterModels 注册模型后调用
+func RunPlanCommand(ctx context.Context, args []string, out io.Writer, connect func() (ORM, error)) error {
+	fs := flag.NewFlagSet("plan", flag.ContinueOnError)
+	fs.SetOutput(out)
+	ignoreExtra := fs.Bool("ignore-extra", false, "忽略数据库中多出的列与索引")
+	fs.Usage = func() {
+		fmt.Fprint(out, PlanUsage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	list := RegisteredModels()
+	if len(list) == 0 {
+		return errors.New("plan: no models registered, call morm.RegisterModels before RunPlanCommand")
+	}
+	orm, err := connect()
+	if err != nil {
+		return err
+	}
+	plan, err := Plan(ctx, orm, list...)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(out, plan)
+	if !plan.Empty(*ignoreExtra) {
+		return ErrSchemaDrift
+	}
+	return nil
+}
@@ -23,6 +23,12 @@ type Model = types.ORMModel
 
 type ORMQuery = types.ORMQuery
 
+type PreloadScope = types.PreloadScope
+
+type RawQuery = types.RawQuery
+
+type QueryPlan = types.QueryPlan
+
 type BulkWriteOperation = types.BulkWriteOperation
 
 type MongoBulkWriteOperation = types.MongoBulkWriteOperation
@@ -65,3 +71,84 @@ const (
 )
 
 type LogConfig = conf.LogConfig
+
+// 乐观锁版本不一致
+var ErrStaleVersion = types.ErrStaleVersion
+
+// 字段不存在
+var ErrUnknownField = types.ErrUnknownField
+
+// 连接条件不合法
+var ErrInvalidJoin = types.ErrInvalidJoin
+
+// 后端不支持的操作
+var ErrNotSupported = types.ErrNotSupported
+
+// 模型与数据库结构不一致
+var ErrSchemaDrift = types.ErrSchemaDrift
+
+// 生命周期钩子
+type BeforeCreateHook = types.BeforeCreateHook
+
+type AfterCreateHook = types.AfterCreateHook
+
+type BeforeUpdateHook = types.BeforeUpdateHook
+
+type AfterUpdateHook = types.AfterUpdateHook
+
+type BeforeDeleteHook = types.BeforeDeleteHook
+
+type AfterFindHook = types.AfterFindHook
+
+// 中间件
+type Middleware = types.Middleware
+
+type Handler = types.Handler
+
+type Operation = types.Operation
+
+type OpType = types.OpType
+
+const (
+	OpCreate        = types.OpCreate
+	OpSave          = types.OpSave
+	OpUpdate        = types.OpUpdate
+	OpDelete        = types.OpDelete
+	OpFindOne       = types.OpFindOne
+	OpFindAll       = types.OpFindAll
+	OpCount         = types.OpCount
+	OpCursor        = types.OpCursor
+	OpBulk          = types.OpBulk
+	OpSession       = types.OpSession
+	OpIncr          = types.OpIncr
+	OpUpdateColumns = types.OpUpdateColumns
+	OpExec          = types.OpExec
+)
+
+// 连接池状态
+type PoolStats = types.PoolStats
+
+// 索引定义
+type IndexSpec = types.IndexSpec
+
+type Indexer = types.Indexer
+
+type SyncIndexOptions = types.SyncIndexOptions
+
+type IndexReport = types.IndexReport
+
+// 结构对比结果
+type SchemaPlan = types.SchemaPlan
+
+type SchemaChange = types.SchemaChange
+
+// 文档校验选项
+type ValidatorOptions = types.ValidatorOptions
+
+const (
+	ValidationLevelStrict   = types.ValidationLevelStrict
+	ValidationLevelModerate = types.ValidationLevelModerate
+	ValidationLevelOff      = types.ValidationLevelOff
+	ValidationActionError   = types.ValidationActionError
+	ValidationActionWarn    = types.ValidationActionWarn
+)
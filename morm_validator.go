@@ -0,0 +1,18 @@
+package morm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lfhy/morm/types"
+)
+
+// 根据模型生成 $jsonSchema 并应用到集合 目前只支持 MongoDB
+// 集合不存在时创建集合 opts 为空时使用配置中的校验级别与处理方式
+func SyncValidators(ctx context.Context, orm ORM, opts ValidatorOptions, models ...any) error {
+	syncer, ok := orm.(types.ValidatorSyncer)
+	if !ok {
+		return fmt.Errorf("morm: %T does not support SyncValidators", orm)
+	}
+	return syncer.SyncValidators(ctx, opts, models...)
+}
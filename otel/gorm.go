@@ -0,0 +1,143 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanKey = "morm:otel_span"
+
+type gormPlugin struct {
+	cfg    *config
+	tracer trace.Tracer
+	attrs  []attribute.KeyValue
+}
+
+// gorm 插件 为每条语句创建 span
+//
+//	db.Use(otel.NewGormPlugin())
+func NewGormPlugin(opts ...Option) gorm.Plugin {
+	return &gormPlugin{cfg: newConfig(opts)}
+}
+
+func (p *gormPlugin) Name() string {
+	return "morm:otel"
+}
+
+func (p *gormPlugin) Initialize(db *gorm.DB) error {
+	p.tracer = p.cfg.tracer()
+	p.attrs = append(p.attrs, dbSystem(db.Dialector.Name()))
+	dbName := p.cfg.dbName
+	if dbName == "" {
+		dbName = db.Migrator().CurrentDatabase()
+	}
+	if dbName != "" {
+		p.attrs = append(p.attrs, semconv.DBName(dbName))
+	}
+	p.attrs = append(p.attrs, p.cfg.attrs...)
+
+	cb := db.Callback()
+	// 在所有回调之前开始 之后结束 钩子中发起的查询会成为子 span
+	errs := []error{
+		cb.Create().Before("*").Register("morm:otel_before_create", p.before),
+		cb.Create().After("*").Register("morm:otel_after_create", p.after("INSERT")),
+		cb.Query().Before("*").Register("morm:otel_before_query", p.before),
+		cb.Query().After("*").Register("morm:otel_after_query", p.after("SELECT")),
+		cb.Update().Before("*").Register("morm:otel_before_update", p.before),
+		cb.Update().After("*").Register("morm:otel_after_update", p.after("UPDATE")),
+		cb.Delete().Before("*").Register("morm:otel_before_delete", p.before),
+		cb.Delete().After("*").Register("morm:otel_after_delete", p.after("DELETE")),
+		cb.Row().Before("*").Register("morm:otel_before_row", p.before),
+		cb.Row().After("*").Register("morm:otel_after_row", p.after("")),
+		cb.Raw().Before("*").Register("morm:otel_before_raw", p.before),
+		cb.Raw().After("*").Register("morm:otel_after_raw", p.after("")),
+	}
+	return errors.Join(errs...)
+}
+
+// 语句执行期间的 span 与原上下文
+type gormSpan struct {
+	span   trace.Span
+	parent context.Context
+}
+
+func (p *gormPlugin) before(db *gorm.DB) {
+	parent := db.Statement.Context
+	ctx, span := p.tracer.Start(parent, "morm", trace.WithSpanKind(trace.SpanKindClient))
+	db.Statement.Context = ctx
+	db.InstanceSet(spanKey, &gormSpan{span: span, parent: parent})
+}
+
+func (p *gormPlugin) after(op string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(spanKey)
+		if !ok {
+			return
+		}
+		gs, ok := value.(*gormSpan)
+		if !ok {
+			return
+		}
+		span := gs.span
+		defer span.End()
+		// 恢复原上下文 链式调用的后续语句不会成为当前 span 的子 span
+		db.Statement.Context = gs.parent
+
+		// SQL 中的参数为占位符 不包含实际的值
+		statement := db.Statement.SQL.String()
+		op := op
+		if op == "" {
+			op = sqlOperation(statement)
+		}
+		table := db.Statement.Table
+		name := op
+		if table != "" {
+			name = op + " " + table
+		}
+		span.SetName(name)
+		span.SetAttributes(p.attrs...)
+		span.SetAttributes(
+			semconv.DBOperation(op),
+			semconv.DBStatement(statement),
+		)
+		if db.RowsAffected >= 0 {
+			span.SetAttributes(attribute.Int64("db.rows_affected", db.RowsAffected))
+		}
+		if table != "" {
+			span.SetAttributes(semconv.DBSQLTable(table))
+		}
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			span.RecordError(db.Error)
+			span.SetStatus(codes.Error, db.Error.Error())
+		}
+	}
+}
+
+// 取 SQL 的第一个关键字作为操作名
+func sqlOperation(statement string) string {
+	statement = strings.TrimSpace(statement)
+	if i := strings.IndexAny(statement, " \t\n"); i > 0 {
+		statement = statement[:i]
+	}
+	return strings.ToUpper(statement)
+}
+
+func dbSystem(dialector string) attribute.KeyValue {
+	switch dialector {
+	case "mysql":
+		return semconv.DBSystemMySQL
+	case "sqlite":
+		return semconv.DBSystemSqlite
+	case "mongodb":
+		return semconv.DBSystemMongoDB
+	default:
+		return semconv.DBSystemKey.String(dialector)
+	}
+}
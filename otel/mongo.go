@@ -0,0 +1,68 @@
+package otel
+
+import (
+	"context"
+	"sync"
+
+	"github.com/lfhy/morm/db/mongodb"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Mongo 命令监听 为每个命令创建 span
+// 父级 span 取自执行命令时的上下文
+//
+//	mongodb.AddCommandMonitor(otel.NewCommandMonitor())
+func NewCommandMonitor(opts ...Option) *event.CommandMonitor {
+	cfg := newConfig(opts)
+	tracer := cfg.tracer()
+	// 按请求 ID 关联开始与结束事件
+	var spans sync.Map
+
+	finish := func(requestID int64) trace.Span {
+		value, ok := spans.LoadAndDelete(requestID)
+		if !ok {
+			return nil
+		}
+		return value.(trace.Span)
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			collection, _ := evt.Command.Lookup(evt.CommandName).StringValueOK()
+			name := evt.CommandName
+			if collection != "" {
+				name += " " + collection
+			}
+			dbName := evt.DatabaseName
+			if cfg.dbName != "" {
+				dbName = cfg.dbName
+			}
+			_, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+			span.SetAttributes(
+				semconv.DBSystemMongoDB,
+				semconv.DBName(dbName),
+				semconv.DBOperation(evt.CommandName),
+				semconv.DBStatement(mongodb.SanitizeCommand(evt.Command)),
+			)
+			if collection != "" {
+				span.SetAttributes(semconv.DBMongoDBCollection(collection))
+			}
+			span.SetAttributes(cfg.attrs...)
+			spans.Store(evt.RequestID, span)
+		},
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			if span := finish(evt.RequestID); span != nil {
+				span.End()
+			}
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			if span := finish(evt.RequestID); span != nil {
+				span.SetStatus(codes.Error, evt.Failure)
+				span.End()
+			}
+		},
+	}
+}
@@ -0,0 +1,85 @@
+// OpenTelemetry 链路追踪
+//
+// SQL 通过 gorm 回调为每条语句创建 span Mongo 通过驱动的 CommandMonitor 为每个命令创建 span
+// span 的父级取自模型的上下文 使用 SetContext 传入即可
+//
+//	otel.InstrumentMongo() // 需在 morm.Init 前调用
+//	orm := morm.Init(...)
+//	otel.Instrument(orm)
+//	orm.Model(&User{}).SetContext(ctx).One(&user)
+package otel
+
+import (
+	"errors"
+
+	"github.com/lfhy/morm/db/mongodb"
+	"github.com/lfhy/morm/db/sqlorm"
+	"github.com/lfhy/morm/types"
+	gotel "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/lfhy/morm/otel"
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	attrs          []attribute.KeyValue
+	dbName         string
+}
+
+type Option func(*config)
+
+// 指定 TracerProvider 默认使用全局 TracerProvider
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+// 为每个 span 附加额外属性
+func WithAttributes(attrs ...attribute.KeyValue) Option {
+	return func(c *config) {
+		c.attrs = append(c.attrs, attrs...)
+	}
+}
+
+// 指定 db.name 属性 SQL 默认读取当前数据库名
+func WithDBName(name string) Option {
+	return func(c *config) {
+		c.dbName = name
+	}
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.tracerProvider == nil {
+		c.tracerProvider = gotel.GetTracerProvider()
+	}
+	return c
+}
+
+func (c *config) tracer() trace.Tracer {
+	return c.tracerProvider.Tracer(instrumentationName)
+}
+
+// 为已初始化的连接启用追踪
+// Mongo 的命令监听只能在连接时设置 需在初始化前调用 InstrumentMongo
+func Instrument(orm types.ORM, opts ...Option) error {
+	switch conn := orm.(type) {
+	case *sqlorm.DBConn:
+		return conn.DB.Use(NewGormPlugin(opts...))
+	case *mongodb.DBConn:
+		return errors.New("otel: mongodb 需在初始化前调用 InstrumentMongo")
+	default:
+		return errors.New("otel: 不支持的连接类型")
+	}
+}
+
+// 为之后初始化的 Mongo 连接启用追踪
+func InstrumentMongo(opts ...Option) {
+	mongodb.AddCommandMonitor(NewCommandMonitor(opts...))
+}
@@ -0,0 +1,273 @@
+// 模型结构解析
+//
+// 统一使用 morm 标签描述字段 MySQL SQLite 与 MongoDB 使用相同的字段名
+//
+//	type User struct {
+//		ID    string `morm:"column:id;pk"`
+//		Name  string `morm:"column:name;index"`
+//		Email string `morm:"column:email;unique"`
+//		Age   int    `morm:"column:age;must"`
+//	}
+//
+// 支持的选项
+//   - column:name 字段名 MongoDB 中主键固定为 _id
+//   - pk 主键
+//   - index 普通索引 index:name 同名的字段组成联合索引
+//   - unique 唯一索引 unique:name 同名的字段组成联合唯一索引
+//   - ttl:24h MongoDB TTL 索引
+//   - text MongoDB 文本索引
+//   - must 零值也写入 也作为查询条件
+//   - omitempty 零值不写入
+//   - required enum:a|b min:n max:n pattern:re MongoDB 文档校验规则
+//
+// 没有 morm 标签时使用 gorm 与 bson 标签
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm/schema"
+)
+
+// 字段
+type Field struct {
+	// 结构体字段名
+	Name string
+	// 字段在结构体中的下标 Flat 中为包含匿名嵌套结构体的完整路径
+	Index []int
+	// 字段类型
+	Type reflect.Type
+	// 原始标签
+	Tag reflect.StructTag
+	// 是否为匿名嵌套结构体
+	Anonymous bool
+	// SQL 列名 为空时不参与 SQL 条件
+	Column string
+	// MongoDB 字段名 为空时不参与 MongoDB 条件
+	BSON string
+	// 主键
+	PrimaryKey bool
+	// 普通索引
+	Indexed bool
+	// 唯一索引
+	Unique bool
+	// 零值也写入 也作为查询条件
+	Must bool
+	// 零值不写入
+	OmitEmpty bool
+	// morm 标签中的全部选项 键为小写
+	Options map[string]string
+	// 是否通过 morm 标签声明了字段名或索引等信息
+	Declared bool
+}
+
+// 是否有 morm 标签选项
+func (f *Field) Has(option string) bool {
+	_, ok := f.Options[option]
+	return ok
+}
+
+// 字段作为查询条件时的值
+// 非空指针取指向的值 零值只有带有 must 选项时才作为条件
+func (f *Field) Condition(v reflect.Value) (any, bool) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, false
+		}
+		return v.Elem().Interface(), true
+	}
+	if !f.Must && v.IsZero() {
+		return nil, false
+	}
+	return v.Interface(), true
+}
+
+// 结构体
+type Schema struct {
+	Type reflect.Type
+	// 与结构体字段一一对应
+	Fields []*Field
+	// 展开匿名嵌套结构体后的全部字段 Index 为完整的下标路径
+	Flat []*Field
+	// 主键字段 按字段顺序
+	PrimaryFields []*Field
+	// MongoDB 的 _id 字段
+	IDField *Field
+}
+
+// 已解析的结构体
+var cache sync.Map
+
+// 解析结构体 结果按类型缓存 不是结构体时返回 nil
+func Parse(typ reflect.Type) *Schema {
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil
+	}
+	if v, ok := cache.Load(typ); ok {
+		return v.(*Schema)
+	}
+	s := &Schema{Type: typ, Fields: make([]*Field, typ.NumField())}
+	for i := 0; i < typ.NumField(); i++ {
+		f := ParseField(typ.Field(i))
+		s.Fields[i] = f
+		if !f.Anonymous {
+			s.Flat = append(s.Flat, f)
+			continue
+		}
+		// 匿名嵌套结构体的字段加上外层下标
+		for _, nested := range Parse(f.Type).Flat {
+			nf := *nested
+			nf.Index = append(append([]int(nil), f.Index...), nested.Index...)
+			s.Flat = append(s.Flat, &nf)
+		}
+	}
+	for _, f := range s.Flat {
+		if f.PrimaryKey {
+			s.PrimaryFields = append(s.PrimaryFields, f)
+		}
+		if s.IDField == nil && (f.BSON == "_id" || f.BSON == "id") {
+			s.IDField = f
+		}
+	}
+	v, _ := cache.LoadOrStore(typ, s)
+	return v.(*Schema)
+}
+
+// 解析值的结构体
+func Of(v any) *Schema {
+	if v == nil {
+		return nil
+	}
+	return Parse(reflect.TypeOf(v))
+}
+
+// 按字段名 列名或 bson 名查找字段 包含匿名嵌套结构体
+// 返回的字段 Index 为完整的下标路径
+func (s *Schema) LookUp(name string) *Field {
+	if s == nil {
+		return nil
+	}
+	for _, f := range s.Flat {
+		if f.Name == name || f.Column == name || f.BSON == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// 解析单个字段
+func ParseField(sf reflect.StructField) *Field {
+	f := &Field{
+		Name:      sf.Name,
+		Index:     sf.Index,
+		Type:      sf.Type,
+		Tag:       sf.Tag,
+		Anonymous: sf.Anonymous && sf.Type.Kind() == reflect.Struct,
+		Options:   ParseTag(sf.Tag.Get("morm")),
+	}
+	column := f.Options["column"]
+	for _, opt := range []string{"column", "pk", "index", "unique", "must", "omitempty", "ttl", "text", "required", "enum", "min", "max", "pattern"} {
+		if f.Has(opt) {
+			f.Declared = true
+		}
+	}
+	f.PrimaryKey = f.Has("pk")
+	f.Indexed = f.Has("index")
+	f.Unique = f.Has("unique")
+	f.Must = f.Has("must")
+	f.OmitEmpty = f.Has("omitempty")
+
+	// SQL 列名
+	gormTag, hasGorm := sf.Tag.Lookup("gorm")
+	gormColumn, gormSettings := parseGormTag(gormTag)
+	switch {
+	case column != "":
+		f.Column = column
+	case gormColumn == "-":
+	case gormColumn != "":
+		f.Column = gormColumn
+	case hasGorm || f.Declared:
+		f.Column = schema.NamingStrategy{}.ColumnName("", sf.Name)
+	}
+	if gormColumn != "-" {
+		_, pk := gormSettings["PRIMARYKEY"]
+		_, pk2 := gormSettings["PRIMARY_KEY"]
+		_, index := gormSettings["INDEX"]
+		_, unique := gormSettings["UNIQUE"]
+		_, uniqueIndex := gormSettings["UNIQUEINDEX"]
+		f.PrimaryKey = f.PrimaryKey || pk || pk2 || f.Column == "id"
+		f.Indexed = f.Indexed || index
+		f.Unique = f.Unique || unique || uniqueIndex
+	}
+
+	// MongoDB 字段名
+	bsonTag, hasBSON := sf.Tag.Lookup("bson")
+	bsonName, bsonOpts, _ := strings.Cut(bsonTag, ",")
+	switch {
+	case f.Has("pk"):
+		f.BSON = "_id"
+	case column != "":
+		f.BSON = column
+	case bsonName == "-":
+	case bsonName != "":
+		f.BSON = bsonName
+	case hasBSON || f.Declared:
+		f.BSON = strings.ToLower(sf.Name)
+	}
+	if bsonName != "-" {
+		for _, opt := range strings.Split(bsonOpts, ",") {
+			switch opt {
+			case "must":
+				f.Must = true
+			case "omitempty":
+				f.OmitEmpty = true
+			}
+		}
+		f.PrimaryKey = f.PrimaryKey || f.BSON == "_id"
+	}
+	return f
+}
+
+// 解析 morm 标签 选项以 ; 分隔 键转为小写
+// 值中可以包含 , 如 pattern:^[0-9]{1,3}$
+func ParseTag(tag string) map[string]string {
+	options := make(map[string]string)
+	for _, part := range strings.Split(tag, ";") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), ":")
+		if k = strings.ToLower(strings.TrimSpace(k)); k != "" {
+			options[k] = strings.TrimSpace(v)
+		}
+	}
+	return options
+}
+
+// gorm 标签中没有值的选项
+var gormFlags = map[string]bool{
+	"PRIMARYKEY": true, "PRIMARY_KEY": true, "AUTOINCREMENT": true, "UNIQUE": true,
+	"INDEX": true, "UNIQUEINDEX": true, "NOT NULL": true, "NOTNULL": true, "EMBEDDED": true,
+	"AUTOCREATETIME": true, "AUTOUPDATETIME": true, "<-": true, "->": true,
+}
+
+// 解析 gorm 标签 返回列名与选项
+// 兼容直接写列名的写法 如 gorm:"name"
+func parseGormTag(tag string) (column string, settings map[string]string) {
+	settings = schema.ParseTagSetting(tag, ";")
+	if v, ok := settings["-"]; ok && (v == "-" || strings.EqualFold(v, "all")) {
+		return "-", settings
+	}
+	if v, ok := settings["COLUMN"]; ok {
+		return v, settings
+	}
+	for _, part := range strings.Split(tag, ";") {
+		part = strings.TrimSpace(part)
+		if part != "" && !strings.Contains(part, ":") && !gormFlags[strings.ToUpper(part)] {
+			return part, settings
+		}
+	}
+	return "", settings
+}
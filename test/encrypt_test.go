@@ -0,0 +1,257 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lfhy/morm/db/mongodb"
+	"github.com/lfhy/morm/encrypt"
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// encryptItem 用于测试字段加密
+type encryptItem struct {
+	ID    int     `gorm:"column:id;primaryKey;autoIncrement"`
+	Name  string  `gorm:"column:name"`
+	Phone string  `gorm:"column:phone" morm:"encrypt"`
+	Email string  `gorm:"column:email" morm:"encrypt;deterministic"`
+	Note  *string `gorm:"column:note" morm:"encrypt"`
+}
+
+func (encryptItem) TableName() string { return "encrypt_items" }
+
+func setTestKeys(t *testing.T, current string, ids ...string) {
+	t.Helper()
+	keys := make(map[string][]byte)
+	for _, id := range ids {
+		keys[id] = bytes.Repeat([]byte(id[:1]), 32)
+	}
+	encrypt.SetKeyProvider(&encrypt.StaticKeys{Current: current, Keys: keys})
+	t.Cleanup(func() { encrypt.SetKeyProvider(nil) })
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	db := newSQLDB(t, &encryptItem{})
+	setTestKeys(t, "a1", "a1")
+
+	note := "secret note"
+	item := &encryptItem{Name: "foo", Phone: "13812345678", Email: "foo@bar.com", Note: &note}
+	if _, err := db.Model(&encryptItem{}).Create(item); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if item.Phone != "13812345678" || *item.Note != "secret note" {
+		t.Fatalf("create must leave plaintext in data, got %+v", item)
+	}
+
+	var raw struct {
+		Phone string
+		Email string
+		Note  string
+	}
+	if err := db.Raw("SELECT phone, email, note FROM encrypt_items WHERE id = ?", item.ID).Scan(&raw).Error; err != nil {
+		t.Fatalf("raw: %v", err)
+	}
+	for _, v := range []string{raw.Phone, raw.Email, raw.Note} {
+		if !encrypt.IsEncrypted(v) {
+			t.Fatalf("expected ciphertext in database, got %q", v)
+		}
+	}
+
+	var got encryptItem
+	if err := db.Model(&encryptItem{}).Where("id", item.ID).One(&got); err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if got.Phone != "13812345678" || got.Email != "foo@bar.com" || got.Note == nil || *got.Note != "secret note" {
+		t.Fatalf("expected decrypted data, got %+v", got)
+	}
+
+	// 更新后仍为密文
+	if err := db.Model(&encryptItem{}).Where("id", item.ID).Update(&encryptItem{Phone: "13900000000"}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := db.Model(&encryptItem{}).Where("id", item.ID).Update("phone", "13911111111"); err != nil {
+		t.Fatalf("update column: %v", err)
+	}
+	if err := db.Raw("SELECT phone FROM encrypt_items WHERE id = ?", item.ID).Scan(&raw).Error; err != nil {
+		t.Fatalf("raw: %v", err)
+	}
+	if !encrypt.IsEncrypted(raw.Phone) {
+		t.Fatalf("expected ciphertext after update, got %q", raw.Phone)
+	}
+
+	var list []*encryptItem
+	if err := db.Model(&encryptItem{}).All(&list); err != nil {
+		t.Fatalf("all: %v", err)
+	}
+	if len(list) != 1 || list[0].Phone != "13911111111" {
+		t.Fatalf("expected decrypted list, got %+v", list)
+	}
+
+	cursor, err := db.Model(&encryptItem{}).Cursor()
+	if err != nil {
+		t.Fatalf("cursor: %v", err)
+	}
+	defer cursor.Close()
+	for cursor.Next() {
+		var c encryptItem
+		if err := cursor.Decode(&c); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if c.Email != "foo@bar.com" {
+			t.Fatalf("expected decrypted cursor data, got %+v", c)
+		}
+	}
+}
+
+func TestEncryptDeterministicWhere(t *testing.T) {
+	db := newSQLDB(t, &encryptItem{})
+	setTestKeys(t, "a1", "a1")
+
+	for _, name := range []string{"foo", "bar"} {
+		if _, err := db.Model(&encryptItem{}).Create(&encryptItem{Name: name, Phone: "1", Email: name + "@bar.com"}); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	var got encryptItem
+	if err := db.Model(&encryptItem{}).Where("email", "bar@bar.com").One(&got); err != nil {
+		t.Fatalf("find by email: %v", err)
+	}
+	if got.Name != "bar" {
+		t.Fatalf("expected bar, got %+v", got)
+	}
+	if n := db.Model(&encryptItem{}).Where(&encryptItem{Email: "foo@bar.com"}).Count(); n != 1 {
+		t.Fatalf("expected 1 row by struct condition, got %d", n)
+	}
+
+	// Save 插入新数据时同样加密
+	if err := db.Model(&encryptItem{}).Where(&encryptItem{Email: "baz@bar.com"}).Save(&encryptItem{Name: "baz", Phone: "9", Email: "baz@bar.com"}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	var phone string
+	if err := db.Raw("SELECT phone FROM encrypt_items WHERE name = ?", "baz").Scan(&phone).Error; err != nil {
+		t.Fatalf("raw: %v", err)
+	}
+	if !encrypt.IsEncrypted(phone) {
+		t.Fatalf("expected ciphertext after save, got %q", phone)
+	}
+	got = encryptItem{}
+	if err := db.Model(&encryptItem{}).Where("email", "baz@bar.com").One(&got); err != nil || got.Phone != "9" {
+		t.Fatalf("expected saved row, got %+v %v", got, err)
+	}
+
+	// 随机加密的字段相同明文得到不同密文
+	a, _ := encrypt.Encrypt(context.Background(), "x", false)
+	b, _ := encrypt.Encrypt(context.Background(), "x", false)
+	if a == b {
+		t.Fatal("expected random nonce for non-deterministic encryption")
+	}
+}
+
+func TestEncryptKeyRotation(t *testing.T) {
+	db := newSQLDB(t, &encryptItem{})
+	setTestKeys(t, "a1", "a1")
+
+	old := &encryptItem{Name: "old", Phone: "111"}
+	if _, err := db.Model(&encryptItem{}).Create(old); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	// 轮换到新密钥 保留旧密钥用于解密
+	setTestKeys(t, "b2", "a1", "b2")
+	item := &encryptItem{Name: "new", Phone: "222"}
+	if _, err := db.Model(&encryptItem{}).Create(item); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	var phones []string
+	if err := db.Raw("SELECT phone FROM encrypt_items ORDER BY id").Scan(&phones).Error; err != nil {
+		t.Fatalf("raw: %v", err)
+	}
+	if encrypt.KeyID(phones[0]) != "a1" || encrypt.KeyID(phones[1]) != "b2" {
+		t.Fatalf("unexpected key ids: %v", phones)
+	}
+
+	var list []encryptItem
+	if err := db.Model(&encryptItem{}).Asc("id").All(&list); err != nil {
+		t.Fatalf("all: %v", err)
+	}
+	if list[0].Phone != "111" || list[1].Phone != "222" {
+		t.Fatalf("expected both keys to decrypt, got %+v", list)
+	}
+
+	// 旧密钥移除后无法解密
+	setTestKeys(t, "b2", "b2")
+	var got encryptItem
+	err := db.Model(&encryptItem{}).Where("id", old.ID).One(&got)
+	if !errors.Is(err, encrypt.ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestEncryptWhereError(t *testing.T) {
+	db := newSQLDB(t, &encryptItem{})
+	setTestKeys(t, "a1", "a1")
+	if _, err := db.Model(&encryptItem{}).Create(&encryptItem{Name: "foo", Phone: "1", Email: "foo@bar.com"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	// 随机加密的字段不能作为查询条件
+	var got encryptItem
+	err := db.Model(&encryptItem{}).Where("phone", "1").One(&got)
+	if !errors.Is(err, encrypt.ErrNotDeterministic) {
+		t.Fatalf("expected ErrNotDeterministic, got %v", err)
+	}
+	mm := &mongodb.Model{Tx: &mongodb.DBConn{}, Data: &encryptItem{}, WhereList: bson.M{}}
+	if err := mm.Where("phone", "1").One(&got); !errors.Is(err, encrypt.ErrNotDeterministic) {
+		t.Fatalf("expected ErrNotDeterministic on mongodb, got %v", err)
+	}
+
+	// 没有密钥时无法加密查询条件
+	encrypt.SetKeyProvider(nil)
+	err = db.Model(&encryptItem{}).Where("email", "foo@bar.com").One(&got)
+	if !errors.Is(err, encrypt.ErrNoKeyProvider) {
+		t.Fatalf("expected ErrNoKeyProvider, got %v", err)
+	}
+	mm = &mongodb.Model{Tx: &mongodb.DBConn{}, Data: &encryptItem{}, WhereList: bson.M{}}
+	if err := mm.Where("email", "foo@bar.com").One(&got); !errors.Is(err, encrypt.ErrNoKeyProvider) {
+		t.Fatalf("expected ErrNoKeyProvider on mongodb, got %v", err)
+	}
+}
+
+func TestEncryptSubkeys(t *testing.T) {
+	setTestKeys(t, "a1", "a1")
+	key := bytes.Repeat([]byte("a"), 32)
+	enc, err := encrypt.Encrypt(context.Background(), "foo@bar.com", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(enc[strings.LastIndexByte(enc, ':')+1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// nonce 不能直接使用 AES 密钥计算的 HMAC
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("foo@bar.com"))
+	if bytes.Equal(sealed[:12], mac.Sum(nil)[:12]) {
+		t.Fatal("expected nonce derived from a separate mac key")
+	}
+	if plain, err := encrypt.Decrypt(context.Background(), enc); err != nil || plain != "foo@bar.com" {
+		t.Fatalf("unexpected decrypt %q %v", plain, err)
+	}
+}
+
+func TestEncryptMongoUpdateOperators(t *testing.T) {
+	setTestKeys(t, "a1", "a1")
+	m := &mongodb.Model{Tx: &mongodb.DBConn{}, Data: &encryptItem{}, WhereList: bson.M{}}
+	// 除 $set $setOnInsert 外的操作符写入的值无法加密
+	err := m.Where("name", "foo").Update(&encryptItem{Name: "foo"}, bson.M{"$push": bson.M{"phone": "1"}})
+	if !errors.Is(err, types.ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/lfhy/morm/db/mongodb"
+	"github.com/lfhy/morm/db/sqlorm"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type explainItem struct {
+	ID     int    `gorm:"column:id;primaryKey"`
+	Name   string `gorm:"column:name"`
+	Status int    `gorm:"column:status"`
+}
+
+func (explainItem) TableName() string { return "explain_items" }
+
+func TestToSQL(t *testing.T) {
+	db := newSQLDB(t, &explainItem{})
+	m := db.Model(&explainItem{}).Where("name", "a").WhereGt("status", 1).Desc("id").Limit(5).(*sqlorm.Model)
+	got := m.ToSQL()
+	want := "SELECT * FROM `explain_items` WHERE name = \"a\" AND status > 1 ORDER BY id DESC LIMIT 5"
+	if got != want {
+		t.Fatalf("unexpected sql\n got %s\nwant %s", got, want)
+	}
+	// 只生成语句 不执行查询
+	var n int64
+	db.DB.Table("explain_items").Count(&n)
+	if n != 0 {
+		t.Fatalf("expected no rows, got %d", n)
+	}
+}
+
+func TestExplainSQL(t *testing.T) {
+	db := newSQLDB(t, &explainItem{})
+	plan, err := db.Model(&explainItem{}).Where("name", "a").Explain(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.FullScan || len(plan.Indexes) != 0 || !strings.Contains(plan.Statement, "name = \"a\"") {
+		t.Fatalf("expected full scan without index, got %+v", plan)
+	}
+
+	if err := db.DB.Exec("CREATE INDEX idx_explain_name ON explain_items(name)").Error; err != nil {
+		t.Fatal(err)
+	}
+	plan, err = db.Model(&explainItem{}).Where("name", "a").Explain(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.FullScan || len(plan.Indexes) != 1 || plan.Indexes[0] != "idx_explain_name" {
+		t.Fatalf("expected index scan, got %+v", plan)
+	}
+
+	plan, err = db.Model(&explainItem{}).Where("id", 1).Explain(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.FullScan || len(plan.Indexes) != 1 || plan.Indexes[0] != "PRIMARY" {
+		t.Fatalf("expected primary key search, got %+v", plan)
+	}
+}
+
+func TestToFilter(t *testing.T) {
+	id := primitive.NewObjectID()
+	m := &mongodb.Model{Data: &bson.M{}, Collection: "users", WhereList: bson.M{}}
+	m.Where("_id", id.Hex()).WhereLike("name", "a").WhereLike("email", "b").Asc("age").Limit(10)
+	filter, opts := m.ToFilter()
+	if eq, ok := filter["_id"].(bson.M); !ok || eq["$eq"] != id {
+		t.Fatalf("expected _id converted to ObjectID, got %#v", filter["_id"])
+	}
+	if _, ok := filter["$or_like"]; ok {
+		t.Fatalf("expected $or_like merged, got %v", filter)
+	}
+	if or, ok := filter["$or"].(bson.A); !ok || len(or) != 2 {
+		t.Fatalf("unexpected $or %#v", filter["$or"])
+	}
+	if opts.Limit == nil || *opts.Limit != 10 {
+		t.Fatalf("unexpected limit %v", opts.Limit)
+	}
+	if sort, ok := opts.Sort.(bson.D); !ok || len(sort) != 1 || sort[0].Key != "age" {
+		t.Fatalf("unexpected sort %#v", opts.Sort)
+	}
+}
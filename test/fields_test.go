@@ -0,0 +1,154 @@
+package test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lfhy/morm/db/mongodb"
+	"github.com/lfhy/morm/field"
+	"github.com/lfhy/morm/gen"
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// 与 morm gen fields 为 zeroItem 生成的内容一致
+var zeroItemFields = struct {
+	ID       field.Field[int]
+	Name     field.String
+	IsDelete field.Field[int]
+	Status   field.Field[int]
+	Score    field.Field[int]
+	Remark   field.String
+}{
+	ID:       field.New[int]("id", "_id"),
+	Name:     field.NewString("name", "name"),
+	IsDelete: field.New[int]("is_delete", "is_delete"),
+	Status:   field.New[int]("status", "status"),
+	Score:    field.New[int]("score", "score"),
+	Remark:   field.NewString("remark", "remark"),
+}
+
+func TestFieldConditions(t *testing.T) {
+	db := newSQLDB(t, &zeroItem{})
+	for i, name := range []string{"alice", "bob", "carol", "dave"} {
+		if _, err := db.Model(&zeroItem{}).Create(&zeroItem{Name: name, Status: i, Score: intPtr(i * 10)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f := zeroItemFields
+	for _, c := range []struct {
+		conds []any
+		want  int64
+	}{
+		{[]any{f.Name.Eq("bob")}, 1},
+		{[]any{f.Status.Eq(0)}, 1},
+		{[]any{f.Status.Ne(0)}, 3},
+		{[]any{f.Score.Gt(10)}, 2},
+		{[]any{f.Score.Gte(10), f.Score.Lt(30)}, 2},
+		{[]any{f.Status.Lte(1)}, 2},
+		{[]any{f.Name.In("alice", "dave", "eve")}, 2},
+		{[]any{f.Name.NotIn("alice")}, 3},
+		{[]any{f.Name.Like("%o%")}, 2},
+		{[]any{f.Name.Like("_ave")}, 1},
+	} {
+		if n := db.Model(&zeroItem{}).Where(c.conds[0], c.conds[1:]...).Count(); n != c.want {
+			t.Fatalf("%+v: expected %d rows, got %d", c.conds, c.want, n)
+		}
+	}
+
+	err := db.Model(&zeroItem{}).Where(f.Name.Eq("bob"), "status").Delete()
+	if err == nil {
+		t.Fatal("expected error for mixed conditions")
+	}
+	err = db.Model(&zeroItem{}).Where(field.NewString("", "only_mongo").Eq("x")).Delete()
+	if !errors.Is(err, types.ErrUnknownField) {
+		t.Fatalf("expected ErrUnknownField, got %v", err)
+	}
+	if n := db.Model(&zeroItem{}).Count(); n != 4 {
+		t.Fatalf("expected rows to be kept, got %d", n)
+	}
+}
+
+func TestFieldConditionsMongo(t *testing.T) {
+	f := zeroItemFields
+	m := &mongodb.Model{WhereList: bson.M{}}
+	m.Where(f.Score.Gte(10), f.Score.Lt(30), f.Name.Like("a.b%"), f.Status.In(1, 2), f.ID.Eq(3))
+	score, ok := m.WhereList["score"].(bson.M)
+	if !ok || score["$gte"] != 10 || score["$lt"] != 30 {
+		t.Fatalf("expected merged range on score, got %+v", m.WhereList)
+	}
+	if v := m.WhereList["name"].(bson.M)["$regex"]; v != `^a\.b.*$` {
+		t.Fatalf("unexpected regex %v", v)
+	}
+	if v, ok := m.WhereList["status"].(bson.M)["$in"].(bson.A); !ok || len(v) != 2 {
+		t.Fatalf("unexpected $in %+v", m.WhereList["status"])
+	}
+	if m.WhereList["_id"] != 3 {
+		t.Fatalf("expected _id condition, got %+v", m.WhereList)
+	}
+}
+
+const fieldsSource = `package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+type Base struct {
+	ID primitive.ObjectID ` + "`bson:\"_id\" gorm:\"column:id\"`" + `
+}
+
+type User struct {
+	Base
+	Name     string
+	Age      *int   ` + "`morm:\"column:user_age\"`" + `
+	Tags     []string ` + "`bson:\"tags\" gorm:\"-\"`" + `
+	Internal string ` + "`bson:\"-\" gorm:\"-\"`" + `
+	hidden   string
+	Callback func()
+}
+
+func (User) TableName() string { return "users" }
+
+type notModel struct{ Name string }
+`
+
+func TestGenerateFields(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "user.go"), []byte(fieldsSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src, err := gen.GenerateFields(dir)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, src)
+	}
+	code := string(src)
+	for _, want := range []string{
+		"// Code generated by morm gen fields. DO NOT EDIT.",
+		`"github.com/lfhy/morm/field"`,
+		`"go.mongodb.org/mongo-driver/bson/primitive"`,
+		"var UserFields = struct {",
+		`Name: field.NewString("name", "name"),`,
+		`Age:  field.New[int]("user_age", "user_age"),`,
+		`Tags: field.New[[]string]("", "tags"),`,
+		`ID:   field.New[primitive.ObjectID]("id", "_id"),`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("expected %q in:\n%s", want, code)
+		}
+	}
+	for _, unwanted := range []string{"Internal", "hidden", "Callback", "notModel"} {
+		if strings.Contains(code, unwanted) {
+			t.Fatalf("unexpected %s in:\n%s", unwanted, code)
+		}
+	}
+
+	var out strings.Builder
+	if err := gen.RunCommand(nil, []string{"fields", "-type", "User", dir}, &out, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, gen.DefaultFieldsFile)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,132 @@
+package test
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lfhy/morm/gen"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestGenSQLModels(t *testing.T) {
+	db := newSQLDB(t)
+	for _, sql := range []string{
+		"CREATE TABLE `user_orders` (`id` integer PRIMARY KEY AUTOINCREMENT, `user_id` integer NOT NULL, `amount` numeric NOT NULL, `remark` varchar(255) NULL, `paid_at` datetime NULL, `data` blob NULL)",
+		"CREATE INDEX `idx_user_orders_user_id` ON `user_orders`(`user_id`)",
+		"CREATE TABLE `categories` (`id` integer PRIMARY KEY, `name` text NOT NULL)",
+	} {
+		if err := db.Exec(sql).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	tables, err := gen.SQLTables(db.DB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 2 || tables[0].Name != "categories" || tables[1].Name != "user_orders" {
+		t.Fatalf("unexpected tables: %+v", tables)
+	}
+	src, err := gen.Render(gen.Options{Package: "models"}, tables...)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, src)
+	}
+	code := string(src)
+	for _, want := range []string{
+		"type Category struct",
+		"type UserOrder struct",
+		"ID     int64      `bson:\"_id\" gorm:\"column:id;type:integer;primaryKey\"`",
+		"UserID int64      `bson:\"user_id\" gorm:\"column:user_id;type:integer;not null\"`",
+		"Amount float64    `bson:\"amount\" gorm:\"column:amount;type:numeric;not null\"`",
+		"Remark *string",
+		"PaidAt *time.Time",
+		"Data   []byte",
+		`func (UserOrder) TableName() string {`,
+		`return DB.Model(&UserOrder{})`,
+		`"time"`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("expected %q in:\n%s", want, code)
+		}
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "models.go", src, 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenMongoModels(t *testing.T) {
+	oid := primitive.NewObjectID()
+	now := primitive.NewDateTimeFromTime(time.Now())
+	docs := []bson.D{
+		{{Key: "_id", Value: oid}, {Key: "name", Value: "a"}, {Key: "age", Value: int32(1)}, {Key: "score", Value: int32(1)},
+			{Key: "created_at", Value: now}, {Key: "tags", Value: bson.A{"x"}},
+			{Key: "address", Value: bson.D{{Key: "city", Value: "sz"}, {Key: "geo", Value: bson.D{{Key: "lat", Value: 1.5}}}}}},
+		{{Key: "_id", Value: oid}, {Key: "name", Value: "b"}, {Key: "age", Value: int64(1 << 40)}, {Key: "score", Value: 2.5},
+			{Key: "created_at", Value: now}, {Key: "remark", Value: nil}, {Key: "any", Value: "x"}},
+		{{Key: "_id", Value: oid}, {Key: "name", Value: "c"}, {Key: "age", Value: int32(2)}, {Key: "remark", Value: "r"}, {Key: "any", Value: true}},
+	}
+	table := gen.InferTable("users", docs)
+	src, err := gen.Render(gen.Options{Package: "models", DBVar: "Mongo"}, table)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, src)
+	}
+	code := string(src)
+	for _, want := range []string{
+		"type User struct",
+		"ID        primitive.ObjectID `bson:\"_id\"",
+		"Name      string ",
+		"Age       int64 ",
+		"Score     float64            `bson:\"score,omitempty\"",
+		"CreatedAt time.Time          `bson:\"created_at,omitempty\"",
+		"Tags      []string           `bson:\"tags,omitempty\" gorm:\"column:tags;serializer:json\"`",
+		"Address   UserAddress        `bson:\"address,omitempty\" gorm:\"column:address;serializer:json\"`",
+		"Remark    *string",
+		"Any       any ",
+		"type UserAddress struct",
+		"Geo  UserAddressGeo",
+		"type UserAddressGeo struct",
+		"Lat float64",
+		"return Mongo.Model(&User{})",
+	} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("expected %q in:\n%s", want, code)
+		}
+	}
+	if strings.Contains(code, "func (UserAddress) M()") {
+		t.Fatalf("nested struct should not be a model:\n%s", code)
+	}
+
+	dir := filepath.Join(t.TempDir(), "models")
+	var out strings.Builder
+	if err := gen.WriteModels(dir, gen.Options{}, false, &out, table); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"db.go", "users.go"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || !strings.HasPrefix(strings.TrimPrefix(string(data), "// 由 morm gen models 生成\n"), "package models") {
+			t.Fatalf("unexpected %s: %v\n%s", name, err, data)
+		}
+	}
+	out.Reset()
+	if err := gen.WriteModels(dir, gen.Options{}, false, &out, table); err != nil || strings.Count(out.String(), "skip") != 2 {
+		t.Fatalf("expected existing files to be skipped, got %v: %s", err, out.String())
+	}
+}
+
+func TestGenNames(t *testing.T) {
+	for in, want := range map[string]string{"user_id": "UserID", "_id": "ID", "api-url": "APIURL", "2fa": "F2fa", "createdAt": "CreatedAt"} {
+		if got := gen.FieldName(in); got != want {
+			t.Fatalf("FieldName(%q) = %q, want %q", in, got, want)
+		}
+	}
+	for in, want := range map[string]string{"users": "User", "categories": "Category", "boxes": "Box", "status": "Status", "user_address": "UserAddress"} {
+		if got := gen.StructName(in); got != want {
+			t.Fatalf("StructName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lfhy/morm"
+)
+
+func TestPingStatsClose(t *testing.T) {
+	db := newSQLDB(t)
+	ctx := context.Background()
+	if err := db.Ping(ctx); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	stats := db.Stats()
+	if stats.Backend != "sqlite" || stats.MaxOpenConnections != 1 || stats.OpenConnections != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if err := db.Close(ctx); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := db.Ping(ctx); err == nil {
+		t.Fatalf("expected ping to fail after close")
+	}
+}
+
+func TestHealthMonitor(t *testing.T) {
+	db := newSQLDB(t)
+	changes := make(chan morm.HealthStatus, 4)
+	h := morm.StartHealthMonitor(db, 10*time.Millisecond, func(s morm.HealthStatus) {
+		changes <- s
+	})
+	defer h.Stop()
+
+	if s := <-changes; !s.Healthy {
+		t.Fatalf("expected healthy on start, got %+v", s)
+	}
+	db.Close(context.Background())
+	select {
+	case s := <-changes:
+		if s.Healthy || s.Err == nil {
+			t.Fatalf("expected unhealthy after close, got %+v", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected state change after close")
+	}
+	if h.Status().Healthy {
+		t.Fatalf("expected status to be unhealthy")
+	}
+}
+
+func TestHealthMonitorDefaultInterval(t *testing.T) {
+	db := newSQLDB(t)
+	h := morm.StartHealthMonitor(db, 0, nil)
+	defer h.Stop()
+	if s := h.Status(); !s.Healthy {
+		t.Fatalf("expected healthy with default interval, got %+v", s)
+	}
+}
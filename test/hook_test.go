@@ -0,0 +1,177 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"gorm.io/gorm/logger"
+)
+
+var errProtected = errors.New("protected")
+
+// hookItem 用于测试生命周期钩子
+type hookItem struct {
+	ID      int    `gorm:"column:id;primaryKey;autoIncrement"`
+	Name    string `gorm:"column:name"`
+	Slug    string `gorm:"column:slug"`
+	Found   bool   `gorm:"-"`
+	Protect bool   `gorm:"column:protect"`
+}
+
+func (hookItem) TableName() string { return "hook_items" }
+
+func (h *hookItem) BeforeCreate(ctx context.Context, m types.ORMModel) error {
+	h.Slug = "slug-" + h.Name
+	return nil
+}
+
+func (h *hookItem) AfterFind(ctx context.Context, m types.ORMModel) error {
+	h.Found = true
+	return nil
+}
+
+func (h *hookItem) BeforeDelete(ctx context.Context, m types.ORMModel) error {
+	// 钩子中可以继续使用 m 查询
+	var cur hookItem
+	if err := m.TableName(h).Where("id", h.ID).One(&cur); err != nil {
+		return err
+	}
+	if cur.Protect {
+		return errProtected
+	}
+	return nil
+}
+
+func TestHooks(t *testing.T) {
+	db := newSQLDB(t, &hookItem{})
+
+	item := &hookItem{Name: "foo", Protect: true}
+	if _, err := db.Model(&hookItem{}).Create(item); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if item.Slug != "slug-foo" {
+		t.Fatalf("expected BeforeCreate to set slug, got %q", item.Slug)
+	}
+
+	var got hookItem
+	if err := db.Model(&hookItem{}).Where("id", item.ID).One(&got); err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if !got.Found || got.Slug != "slug-foo" {
+		t.Fatalf("expected AfterFind to run and slug stored, got %+v", got)
+	}
+
+	var list []*hookItem
+	if err := db.Model(&hookItem{}).All(&list); err != nil {
+		t.Fatalf("all: %v", err)
+	}
+	if len(list) != 1 || !list[0].Found {
+		t.Fatalf("expected AfterFind on every element, got %+v", list)
+	}
+
+	cur, err := db.Model(&hookItem{}).Cursor()
+	if err != nil {
+		t.Fatalf("cursor: %v", err)
+	}
+	defer cur.Close()
+	for cur.Next() {
+		var c hookItem
+		if err := cur.Decode(&c); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if !c.Found {
+			t.Fatalf("expected AfterFind on cursor decode")
+		}
+	}
+
+	err = db.Model(&hookItem{}).Delete(&hookItem{ID: item.ID})
+	if !errors.Is(err, errProtected) {
+		t.Fatalf("expected BeforeDelete to abort, got %v", err)
+	}
+	if n := db.Model(&hookItem{}).Count(); n != 1 {
+		t.Fatalf("expected record to remain, got count=%d", n)
+	}
+}
+
+func TestHookWarnFilterScope(t *testing.T) {
+	// 导入 sqlorm 不修改进程内的 gorm 默认日志 只包装 morm 使用的连接的日志
+	if name := fmt.Sprintf("%T", logger.Default); strings.Contains(name, "hookWarnFilter") {
+		t.Fatalf("expected logger.Default untouched, got %s", name)
+	}
+	db := newSQLDB(t, &hookItem{})
+	db.Model(&hookItem{})
+	if name := fmt.Sprintf("%T", db.DB.Logger); !strings.Contains(name, "hookWarnFilter") {
+		t.Fatalf("expected connection logger wrapped, got %s", name)
+	}
+}
+
+// mongoHookItem 记录 MongoDB 调用的钩子
+type mongoHookItem struct {
+	ID    primitive.ObjectID `bson:"_id"`
+	Name  string             `bson:"name"`
+	Calls []string           `bson:"-"`
+}
+
+func (h *mongoHookItem) BeforeCreate(ctx context.Context, m types.ORMModel) error {
+	h.Calls = append(h.Calls, "before_create")
+	return nil
+}
+
+func (h *mongoHookItem) AfterCreate(ctx context.Context, m types.ORMModel) error {
+	h.Calls = append(h.Calls, "after_create")
+	return nil
+}
+
+func (h *mongoHookItem) BeforeUpdate(ctx context.Context, m types.ORMModel) error {
+	h.Calls = append(h.Calls, "before_update")
+	return nil
+}
+
+func (h *mongoHookItem) AfterUpdate(ctx context.Context, m types.ORMModel) error {
+	h.Calls = append(h.Calls, "after_update")
+	return nil
+}
+
+func TestHooksMongoSave(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("insert", func(mt *mtest.T) {
+		db := newMockMongo(mt)
+		item := &mongoHookItem{ID: primitive.NewObjectID(), Name: "a"}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.mongo_hook_items", mtest.FirstBatch),
+			mtest.CreateSuccessResponse(
+				bson.E{Key: "n", Value: int32(1)},
+				bson.E{Key: "nModified", Value: int32(0)},
+				bson.E{Key: "upserted", Value: bson.A{bson.D{{Key: "index", Value: int32(0)}, {Key: "_id", Value: item.ID}}}},
+			),
+		)
+		if err := db.Model(&mongoHookItem{}).Where("_id", item.ID).Save(item); err != nil {
+			mt.Fatal(err)
+		}
+		if want := []string{"before_create", "after_create"}; !reflect.DeepEqual(item.Calls, want) {
+			mt.Fatalf("expected %v, got %v", want, item.Calls)
+		}
+	})
+	mt.Run("update", func(mt *mtest.T) {
+		db := newMockMongo(mt)
+		item := &mongoHookItem{ID: primitive.NewObjectID(), Name: "a"}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.mongo_hook_items", mtest.FirstBatch, bson.D{{Key: "n", Value: int32(1)}}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: int32(1)}, bson.E{Key: "nModified", Value: int32(1)}),
+		)
+		if err := db.Model(&mongoHookItem{}).Where("_id", item.ID).Save(item); err != nil {
+			mt.Fatal(err)
+		}
+		if want := []string{"before_update", "after_update"}; !reflect.DeepEqual(item.Calls, want) {
+			mt.Fatalf("expected %v, got %v", want, item.Calls)
+		}
+	})
+}
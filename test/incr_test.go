@@ -1,11 +1,16 @@
 package test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/glebarez/sqlite"
+	"github.com/lfhy/morm/db/mongodb"
 	"github.com/lfhy/morm/db/sqlorm"
+	"github.com/lfhy/morm/log"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 // incItem 用于测试 Incr / UpdateColumns
@@ -17,25 +22,44 @@ type incItem struct {
 
 func (incItem) TableName() string { return "inc_items" }
 
-func newSQLDB(t *testing.T) *sqlorm.DBConn {
+// newSQLDB 为每个测试创建独立的内存库并迁移传入的模型
+func newSQLDB(t testing.TB, models ...any) *sqlorm.DBConn {
 	t.Helper()
+	// 测试中不读取配置文件 使用静默日志
+	log.SetDBLoger(logger.Discard)
 	// 用文件模式而非 :memory:，确保多连接共享同一数据库（sqlite 内存库每连接独立）
-	gdb, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
+	// 库名使用测试名 各测试之间互不影响
+	dsn := "file:" + strings.ReplaceAll(t.Name(), "/", "_") + "?mode=memory&cache=shared"
+	gdb, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
 	if err != nil {
 		t.Fatalf("open sqlite: %v", err)
 	}
-	// 限制单连接，避免 :memory: 的多连接表隔离问题
-	if sqlDB, e := gdb.DB(); e == nil {
-		sqlDB.SetMaxOpenConns(1)
+	sqlDB, err := gdb.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
 	}
-	if err := gdb.AutoMigrate(&incItem{}); err != nil {
-		t.Fatalf("migrate: %v", err)
+	// 限制单连接，避免 :memory: 的多连接表隔离问题
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if len(models) > 0 {
+		if err := gdb.AutoMigrate(models...); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
 	}
 	return &sqlorm.DBConn{DB: gdb}
 }
 
+// newMockMongo 使用 mtest 的模拟连接 不需要 MongoDB 服务
+// 服务端的响应通过 mt.AddMockResponses 按顺序提供 发出的命令通过 mt.GetStartedEvent 读取
+func newMockMongo(mt *mtest.T) *mongodb.DBConn {
+	mt.Helper()
+	// 测试中不读取配置文件 使用静默日志
+	log.SetDBLoger(logger.Discard)
+	return &mongodb.DBConn{Client: mt.Client, Database: "test"}
+}
+
 func TestIncr(t *testing.T) {
-	db := newSQLDB(t)
+	db := newSQLDB(t, &incItem{})
 
 	// 插入初始记录
 	item := &incItem{Name: "foo", Count: 10}
@@ -59,7 +83,7 @@ func TestIncr(t *testing.T) {
 }
 
 func TestIncrConcurrent(t *testing.T) {
-	db := newSQLDB(t)
+	db := newSQLDB(t, &incItem{})
 	item := &incItem{Name: "bar", Count: 0}
 	if _, err := db.Model(&incItem{}).Create(item); err != nil {
 		t.Fatalf("create: %v", err)
@@ -87,7 +111,7 @@ func TestIncrConcurrent(t *testing.T) {
 }
 
 func TestUpdateColumns(t *testing.T) {
-	db := newSQLDB(t)
+	db := newSQLDB(t, &incItem{})
 	item := &incItem{Name: "baz", Count: 1}
 	if _, err := db.Model(&incItem{}).Create(item); err != nil {
 		t.Fatalf("create: %v", err)
@@ -0,0 +1,240 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lfhy/morm/db/mongodb"
+	"github.com/lfhy/morm/log"
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gorm.io/gorm/logger"
+)
+
+var _ types.IndexSyncer = (*mongodb.DBConn)(nil)
+
+// indexItem 通过标签与 Indexes 方法声明索引
+type indexItem struct {
+	ID      string    `bson:"_id"`
+	Name    string    `morm:"index"`
+	Email   string    `morm:"unique"`
+	Org     string    `morm:"index:idx_org_user"`
+	User    string    `morm:"index:idx_org_user"`
+	Code    string    `morm:"unique:uk_org_code"`
+	Until   time.Time `morm:"ttl:24h"`
+	Title   string    `morm:"text"`
+	Body    string    `morm:"text"`
+	Deleted bool      `bson:"deleted"`
+}
+
+func (indexItem) TableName() string { return "index_items" }
+
+func (*indexItem) Indexes() []types.IndexSpec {
+	return []types.IndexSpec{
+		{
+			Keys:          bson.D{{Key: "name", Value: 1}, {Key: "deleted", Value: -1}},
+			PartialFilter: bson.M{"deleted": false},
+			Collation:     &options.Collation{Locale: "zh"},
+		},
+		// 覆盖标签声明的同名索引
+		{Name: "uk_org_code", Keys: bson.D{{Key: "org", Value: 1}, {Key: "code", Value: 1}}, Unique: true},
+	}
+}
+
+func TestModelIndexes(t *testing.T) {
+	specs, err := mongodb.ModelIndexes(&indexItem{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string]types.IndexSpec)
+	for _, s := range specs {
+		byName[s.Name] = s
+	}
+	if len(specs) != len(byName) {
+		t.Fatalf("duplicate index names: %+v", specs)
+	}
+	for name, keys := range map[string]string{
+		"name_1":               "name_1",
+		"email_1":              "email_1",
+		"idx_org_user":         "org_1_user_1",
+		"uk_org_code":          "org_1_code_1",
+		"until_1":              "until_1",
+		"title_text_body_text": "title_text_body_text",
+		"name_1_deleted_-1":    "name_1_deleted_-1",
+	} {
+		s, ok := byName[name]
+		if !ok {
+			t.Fatalf("missing index %s in %+v", name, specs)
+		}
+		if got := mongodb.IndexName(s.Keys); got != keys {
+			t.Fatalf("index %s: unexpected keys %s", name, got)
+		}
+	}
+	if !byName["email_1"].Unique || !byName["uk_org_code"].Unique || byName["name_1"].Unique {
+		t.Fatalf("unexpected unique flags: %+v", specs)
+	}
+	if byName["until_1"].TTL != 24*time.Hour {
+		t.Fatalf("expected ttl index, got %+v", byName["until_1"])
+	}
+
+	model := mongodb.IndexModel(byName["name_1_deleted_-1"])
+	if model.Options.PartialFilterExpression == nil || model.Options.Collation.Locale != "zh" || *model.Options.Name != "name_1_deleted_-1" {
+		t.Fatalf("unexpected index options: %+v", model.Options)
+	}
+	if ttl := mongodb.IndexModel(byName["until_1"]).Options.ExpireAfterSeconds; ttl == nil || *ttl != 86400 {
+		t.Fatalf("unexpected ttl option: %v", ttl)
+	}
+}
+
+func TestDiffIndexes(t *testing.T) {
+	specs, _ := mongodb.ModelIndexes(&indexItem{})
+	ttl := int64(3600)
+	existing := []mongodb.IndexInfo{
+		{Name: "_id_", Key: bson.D{{Key: "_id", Value: int32(1)}}},
+		// 名称不同但定义相同
+		{Name: "custom_name", Key: bson.D{{Key: "name", Value: int32(1)}}},
+		{Name: "email_1", Key: bson.D{{Key: "email", Value: 1.0}}, Unique: true},
+		// TTL 不一致
+		{Name: "until_1", Key: bson.D{{Key: "until", Value: int32(1)}}, ExpireAfterSeconds: &ttl},
+		{Name: "title_text_body_text", Key: bson.D{{Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: int32(1)}}, Weights: bson.M{"title": int32(1), "body": int32(1)}},
+		{Name: "old_index", Key: bson.D{{Key: "legacy", Value: int32(1)}}},
+	}
+	diff := mongodb.DiffIndexes(specs, existing)
+
+	var missing, changed []string
+	for _, s := range diff.Missing {
+		missing = append(missing, s.Name)
+	}
+	for _, s := range diff.Changed {
+		changed = append(changed, s.Name)
+	}
+	if len(changed) != 1 || changed[0] != "until_1" {
+		t.Fatalf("expected until_1 to be changed, got %v", changed)
+	}
+	if len(diff.Extra) != 1 || diff.Extra[0] != "old_index" {
+		t.Fatalf("expected old_index to be extra, got %v", diff.Extra)
+	}
+	want := map[string]bool{"idx_org_user": true, "uk_org_code": true, "name_1_deleted_-1": true}
+	if len(missing) != len(want) {
+		t.Fatalf("unexpected missing indexes: %v", missing)
+	}
+	for _, name := range missing {
+		if !want[name] {
+			t.Fatalf("unexpected missing index %s", name)
+		}
+	}
+}
+
+// namedIndexItem 联合索引同样应用到 SQL
+type namedIndexItem struct {
+	ID   int    `gorm:"column:id;primaryKey;autoIncrement"`
+	Org  string `morm:"column:org;index:idx_org_user"`
+	User string `morm:"column:user;index:idx_org_user"`
+	Code string `morm:"column:code;unique:uk_org_code"`
+}
+
+func (namedIndexItem) TableName() string { return "named_index_items" }
+
+func TestSchemaNamedIndexSQL(t *testing.T) {
+	db := newSQLDB(t)
+	db.AutoMigrate = true
+	model := db.Model(&namedIndexItem{})
+	for _, name := range []string{"idx_org_user", "uk_org_code"} {
+		if !db.Migrator().HasIndex(&namedIndexItem{}, name) {
+			t.Fatalf("expected index %s", name)
+		}
+	}
+	if _, err := model.Create(&namedIndexItem{Org: "a", User: "u", Code: "c"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := db.Model(&namedIndexItem{}).Create(&namedIndexItem{Org: "b", User: "u", Code: "c"}); err == nil {
+		t.Fatal("expected unique constraint error")
+	}
+}
+
+// autoSyncItem 用于测试自动同步索引 gorm 标签的索引不同步到 MongoDB
+type autoSyncItem struct {
+	ID   string `bson:"_id"`
+	Name string `morm:"index"`
+	Code string `bson:"code" gorm:"uniqueIndex"`
+}
+
+func (autoSyncItem) TableName() string { return "auto_sync_items" }
+
+// 已存在的索引
+func existingIndexes(names ...string) []bson.D {
+	docs := []bson.D{{{Key: "name", Value: "_id_"}, {Key: "key", Value: bson.D{{Key: "_id", Value: 1}}}}}
+	for _, name := range names {
+		field := strings.TrimSuffix(name, "_1")
+		docs = append(docs, bson.D{{Key: "name", Value: name}, {Key: "key", Value: bson.D{{Key: field, Value: 1}}}})
+	}
+	return docs
+}
+
+func TestAutoSyncIndexes(t *testing.T) {
+	specs, err := mongodb.ModelIndexes(&autoSyncItem{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(specs) != 1 || specs[0].Name != "name_1" {
+		t.Fatalf("expected only morm tag index, got %+v", specs)
+	}
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	ns := "test.auto_sync_items"
+	wait := func(mt *mtest.T, db *mongodb.DBConn) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := db.WaitIndexSync(ctx); err != nil {
+			mt.Fatalf("wait index sync: %v", err)
+		}
+	}
+	listed := func(mt *mtest.T) int {
+		n := 0
+		for _, e := range mt.GetAllStartedEvents() {
+			if e.CommandName == "listIndexes" {
+				n++
+			}
+		}
+		mt.ClearEvents()
+		return n
+	}
+
+	mt.Run("background retry", func(mt *mtest.T) {
+		db := newMockMongo(mt)
+		db.AutoMigrate = true
+		// 第一次同步失败 重试后成功
+		mt.AddMockResponses(
+			mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 1, Message: "boom"}),
+			mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, existingIndexes("name_1")...),
+		)
+		db.Model(&autoSyncItem{})
+		db.Model(&autoSyncItem{})
+		wait(mt, db)
+		if n := listed(mt); n != 2 {
+			mt.Fatalf("expected failed sync to be retried once, got %d listIndexes", n)
+		}
+
+		// 成功后不再同步
+		db.Model(&autoSyncItem{})
+		wait(mt, db)
+		if n := listed(mt); n != 0 {
+			mt.Fatalf("expected no sync after success, got %d listIndexes", n)
+		}
+	})
+
+	mt.Run("warn extra index without slog", func(mt *mtest.T) {
+		db := newMockMongo(mt)
+		db.AutoMigrate = true
+		log.SetLogger(nil)
+		log.SetDBLoger(logger.Discard)
+		// 数据库中有模型没有声明的索引
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, ns, mtest.FirstBatch, existingIndexes("name_1", "old_1")...))
+		db.Model(&autoSyncItem{})
+		wait(mt, db)
+	})
+}
@@ -0,0 +1,114 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lfhy/morm/db/mongodb"
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type joinCustomer struct {
+	ID     int    `gorm:"column:id;primaryKey" bson:"_id"`
+	Name   string `gorm:"column:name" bson:"name"`
+	Region string `gorm:"column:region" bson:"region"`
+}
+
+func (joinCustomer) TableName() string { return "customers" }
+
+type joinOrder struct {
+	ID         int    `gorm:"column:id;primaryKey" bson:"_id"`
+	CustomerID int    `gorm:"column:customer_id" bson:"customer_id"`
+	State      string `gorm:"column:state" bson:"state"`
+}
+
+func (joinOrder) TableName() string { return "orders" }
+
+func TestJoinSQL(t *testing.T) {
+	db := newSQLDB(t, &joinCustomer{}, &joinOrder{})
+	customers := []joinCustomer{{ID: 1, Name: "a", Region: "north"}, {ID: 2, Name: "b", Region: "south"}}
+	orders := []joinOrder{{ID: 1, CustomerID: 1, State: "paid"}, {ID: 2, CustomerID: 2, State: "paid"}, {ID: 3, CustomerID: 1, State: "new"}, {ID: 4, CustomerID: 9, State: "paid"}}
+	if err := db.DB.Create(&customers).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DB.Create(&orders).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	north := func() types.ORMModel {
+		return db.Model(&joinOrder{}).
+			Join(&joinCustomer{}, "customers.ID = orders.CustomerID").
+			Where("customers.region", "north")
+	}
+	var list []joinOrder
+	// 两张表都有 id 列 本表的列自动加上表名
+	if err := north().Desc("id").Find().All(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != 3 || list[1].ID != 1 {
+		t.Fatalf("unexpected orders %+v", list)
+	}
+	if n := north().Where(&joinOrder{State: "paid"}).Find().Count(); n != 1 {
+		t.Fatalf("expected 1 paid order in north, got %d", n)
+	}
+	var one joinOrder
+	if err := north().Where("state", "new").One(&one); err != nil || one.ID != 3 {
+		t.Fatalf("unexpected one %+v %v", one, err)
+	}
+
+	if n := db.Model(&joinOrder{}).Join("customers", "customers.id = orders.customer_id").Count(); n != 3 {
+		t.Fatalf("expected inner join to drop orphan order, got %d", n)
+	}
+	if n := db.Model(&joinOrder{}).LeftJoin("customers", "customers.id = orders.customer_id").Count(); n != 4 {
+		t.Fatalf("expected left join to keep orphan order, got %d", n)
+	}
+
+	err := db.Model(&joinOrder{}).Join(&joinCustomer{}, "users.id = orders.customer_id").All(&list)
+	if !errors.Is(err, types.ErrInvalidJoin) {
+		t.Fatalf("expected ErrInvalidJoin, got %v", err)
+	}
+}
+
+func TestJoinPipeline(t *testing.T) {
+	m := &mongodb.Model{Data: &joinOrder{}, WhereList: bson.M{}}
+	m.Join(&joinCustomer{}, "customers.ID = orders.CustomerID").
+		Where("customers.Region", "north").
+		Where("orders.state", "paid").
+		Desc("orders._id").
+		Limit(10)
+	pipeline := m.JoinPipeline()
+	if len(pipeline) != 6 {
+		t.Fatalf("unexpected pipeline %v", pipeline)
+	}
+	want := `[{"$lookup":{"from":"customers","localField":"customer_id","foreignField":"_id","as":"customers"}},` +
+		`{"$unwind":{"path":"$customers","preserveNullAndEmptyArrays":false}}]`
+	if got := pipelineJSON(t, pipeline[:2]); got != want {
+		t.Fatalf("unexpected lookup\n got %s\nwant %s", got, want)
+	}
+	// 本集合的字段去掉集合名 连接的集合中的字段转换为 bson 名
+	match := pipeline[2][0].Value.(bson.M)
+	if len(match) != 2 || match["customers.region"] == nil || match["state"] == nil {
+		t.Fatalf("unexpected match %v", match)
+	}
+	if got := pipelineJSON(t, pipeline[3:]); got != `[{"$sort":{"_id":-1}},{"$limit":10},{"$project":{"customers":0}}]` {
+		t.Fatalf("unexpected stages %s", got)
+	}
+
+	left := &mongodb.Model{Data: &joinOrder{}, WhereList: bson.M{}}
+	left.LeftJoin("customers", "customers._id = orders.customer_id")
+	if got := pipelineJSON(t, left.JoinPipeline()); got != `[{"$lookup":{"from":"customers","localField":"customer_id","foreignField":"_id","as":"customers"}},`+
+		`{"$unwind":{"path":"$customers","preserveNullAndEmptyArrays":true}},{"$match":{}},{"$project":{"customers":0}}]` {
+		t.Fatalf("unexpected left join pipeline %s", got)
+	}
+}
+
+func pipelineJSON(t *testing.T, pipeline any) string {
+	t.Helper()
+	data, err := bson.MarshalExtJSON(bson.M{"p": pipeline}, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 去掉外层的 {"p":...}
+	return string(data[5 : len(data)-1])
+}
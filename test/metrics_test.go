@@ -0,0 +1,112 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lfhy/morm/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// metricsItem 用于测试指标
+type metricsItem struct {
+	ID   int    `gorm:"column:id;primaryKey;autoIncrement"`
+	Name string `gorm:"column:name"`
+}
+
+func (metricsItem) TableName() string { return "metrics_items" }
+
+// metricsMissing 没有建表 查询会出错
+type metricsMissing struct {
+	ID int `gorm:"column:id;primaryKey"`
+}
+
+func (metricsMissing) TableName() string { return "metrics_missing" }
+
+func TestMetrics(t *testing.T) {
+	db := newSQLDB(t, &metricsItem{})
+	reg := prometheus.NewRegistry()
+	c, err := metrics.New(reg)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if err := c.Instrument(db); err != nil {
+		t.Fatalf("instrument: %v", err)
+	}
+
+	db.Model(&metricsItem{}).Create(&metricsItem{Name: "foo"})
+	db.Model(&metricsItem{}).Where("name", "foo").One(&metricsItem{})
+	// 未查到数据不计为错误
+	db.Model(&metricsItem{}).Where("name", "bar").One(&metricsItem{})
+	// 表不存在
+	db.Model(&metricsMissing{}).One(&metricsMissing{})
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	values := make(map[string]float64)
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			key := mf.GetName()
+			for _, l := range m.GetLabel() {
+				key += "," + l.GetName() + "=" + l.GetValue()
+			}
+			switch {
+			case m.Counter != nil:
+				values[key] = m.Counter.GetValue()
+			case m.Histogram != nil:
+				values[key] = float64(m.Histogram.GetSampleCount())
+			}
+		}
+	}
+	for key, want := range map[string]float64{
+		"morm_operations_total,backend=sqlite,op=create,table=metrics_items":             1,
+		"morm_operations_total,backend=sqlite,op=find_one,table=metrics_items":           2,
+		"morm_operation_duration_seconds,backend=sqlite,op=find_one,table=metrics_items": 2,
+		"morm_operation_errors_total,backend=sqlite,op=find_one,table=metrics_missing":   1,
+	} {
+		if values[key] != want {
+			t.Fatalf("%s: expected %v, got %v", key, want, values[key])
+		}
+	}
+	if _, ok := values["morm_operation_errors_total,backend=sqlite,op=find_one,table=metrics_items"]; ok {
+		t.Fatalf("not found should not be counted as error")
+	}
+	found := false
+	for _, mf := range mfs {
+		if mf.GetName() == "go_sql_open_connections" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected sql.DBStats metrics")
+	}
+}
+
+func TestMetricsMongoPool(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	c, err := metrics.New(reg)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	monitor := c.PoolMonitor()
+	for _, typ := range []string{event.ConnectionCreated, event.GetStarted, event.GetSucceeded, event.GetStarted} {
+		monitor.Event(&event.PoolEvent{Type: typ, Address: "db:27017"})
+	}
+	expected := `
+# HELP morm_mongo_pool_checked_out_connections Mongo 连接池中已取出的连接数
+# TYPE morm_mongo_pool_checked_out_connections gauge
+morm_mongo_pool_checked_out_connections{address="db:27017"} 1
+# HELP morm_mongo_pool_waiting_requests Mongo 连接池中等待取出连接的请求数
+# TYPE morm_mongo_pool_waiting_requests gauge
+morm_mongo_pool_waiting_requests{address="db:27017"} 1
+`
+	err = testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"morm_mongo_pool_checked_out_connections", "morm_mongo_pool_waiting_requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
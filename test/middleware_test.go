@@ -0,0 +1,140 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+var errReadOnly = errors.New("read only")
+
+// mwItem 用于测试中间件
+type mwItem struct {
+	ID   int    `gorm:"column:id;primaryKey;autoIncrement"`
+	Name string `gorm:"column:name"`
+}
+
+func (mwItem) TableName() string { return "mw_items" }
+
+func TestMiddleware(t *testing.T) {
+	db := newSQLDB(t, &mwItem{})
+
+	var ops []types.Operation
+	db.Use(func(next types.Handler) types.Handler {
+		return func(ctx context.Context, op *types.Operation) error {
+			err := next(ctx, op)
+			ops = append(ops, *op)
+			return err
+		}
+	})
+
+	if _, err := db.Model(&mwItem{}).Create(&mwItem{Name: "foo"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	var list []mwItem
+	if err := db.Model(&mwItem{}).Where("name", "foo").Limit(10).All(&list); err != nil {
+		t.Fatalf("all: %v", err)
+	}
+	if err := db.Model(&mwItem{}).Where("name", "foo").Update(&mwItem{Name: "bar"}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	want := []types.OpType{types.OpCreate, types.OpFindAll, types.OpUpdate}
+	if len(ops) != len(want) {
+		t.Fatalf("expected %d ops, got %+v", len(want), ops)
+	}
+	for i, op := range ops {
+		if op.Type != want[i] {
+			t.Fatalf("op %d: expected %s, got %s", i, want[i], op.Type)
+		}
+		if op.Table != "mw_items" || op.Backend != "sqlite" {
+			t.Fatalf("op %d: unexpected table/backend %q/%q", i, op.Table, op.Backend)
+		}
+		if op.Rows != 1 || op.Err != nil || op.Duration <= 0 {
+			t.Fatalf("op %d: unexpected result %+v", i, op)
+		}
+	}
+	if filter, ok := ops[1].Filter.(map[string]any); !ok || len(filter) != 1 {
+		t.Fatalf("expected filter on find, got %+v", ops[1].Filter)
+	}
+
+	// 策略中间件可以拦截操作
+	db.Use(func(next types.Handler) types.Handler {
+		return func(ctx context.Context, op *types.Operation) error {
+			if op.Type == types.OpDelete {
+				return errReadOnly
+			}
+			return next(ctx, op)
+		}
+	})
+	err := db.Model(&mwItem{}).Where("name", "bar").Delete()
+	if !errors.Is(err, errReadOnly) {
+		t.Fatalf("expected delete to be blocked, got %v", err)
+	}
+	if n := db.Model(&mwItem{}).Count(); n != 1 {
+		t.Fatalf("expected record to remain, got count=%d", n)
+	}
+}
+
+func TestMiddlewareFilter(t *testing.T) {
+	// 中间件就地修改条件 两种后端都按修改后的条件执行
+	scope := func(next types.Handler) types.Handler {
+		return func(ctx context.Context, op *types.Operation) error {
+			switch filter := op.Filter.(type) {
+			case map[string]any:
+				delete(filter, "where name = ?")
+				filter["where tenant = ?"] = "t1"
+			case bson.M:
+				delete(filter, "name")
+				filter["tenant"] = "t1"
+			}
+			return next(ctx, op)
+		}
+	}
+
+	db := newSQLDB(t, &mwTenantItem{})
+	for _, item := range []mwTenantItem{{Name: "a", Tenant: "t1"}, {Name: "b", Tenant: "t2"}, {Name: "c", Tenant: "t1"}} {
+		if _, err := db.Model(&mwTenantItem{}).Create(&item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Use(scope)
+	var list []mwTenantItem
+	if err := db.Model(&mwTenantItem{}).Where("name", "b").Asc("id").All(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Name != "a" || list[1].Name != "c" {
+		t.Fatalf("expected rows of tenant t1, got %+v", list)
+	}
+	if n := db.Model(&mwTenantItem{}).Count(); n != 2 {
+		t.Fatalf("expected count of tenant t1, got %d", n)
+	}
+
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("mongodb", func(mt *mtest.T) {
+		conn := newMockMongo(mt)
+		conn.Use(scope)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "test.mw_tenant_items", mtest.FirstBatch))
+		var docs []bson.M
+		if err := conn.Model(&mwTenantItem{}).Where("name", "b").All(&docs); err != nil {
+			mt.Fatal(err)
+		}
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		if elems, _ := filter.Elements(); len(elems) != 1 || filter.Lookup("tenant").StringValue() != "t1" {
+			mt.Fatalf("expected tenant filter, got %v", filter)
+		}
+	})
+}
+
+// mwTenantItem 用于测试中间件修改条件
+type mwTenantItem struct {
+	ID     int    `gorm:"column:id;primaryKey;autoIncrement" bson:"_id,omitempty"`
+	Name   string `gorm:"column:name" bson:"name"`
+	Tenant string `gorm:"column:tenant" bson:"tenant"`
+}
+
+func (mwTenantItem) TableName() string { return "mw_tenant_items" }
@@ -0,0 +1,287 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lfhy/morm/migrate"
+	"github.com/lfhy/morm/types"
+)
+
+func testMigrations(calls *[]string) []migrate.Migration {
+	return []migrate.Migration{
+		{
+			Version: 2,
+			Name:    "add_age",
+			Up: func(ctx context.Context, db *migrate.DB) error {
+				*calls = append(*calls, "up 2")
+				return db.SQL.Exec("ALTER TABLE migrate_users ADD COLUMN age INTEGER").Error
+			},
+			Down: func(ctx context.Context, db *migrate.DB) error {
+				*calls = append(*calls, "down 2")
+				return db.SQL.Exec("ALTER TABLE migrate_users DROP COLUMN age").Error
+			},
+		},
+		{
+			Version: 1,
+			Name:    "create_users",
+			Up: func(ctx context.Context, db *migrate.DB) error {
+				*calls = append(*calls, "up 1")
+				return db.SQL.Exec("CREATE TABLE migrate_users (id INTEGER PRIMARY KEY, name TEXT)").Error
+			},
+			Down: func(ctx context.Context, db *migrate.DB) error {
+				*calls = append(*calls, "down 1")
+				return db.SQL.Exec("DROP TABLE migrate_users").Error
+			},
+		},
+	}
+}
+
+func TestMigrateUpDown(t *testing.T) {
+	db := newSQLDB(t)
+	ctx := context.Background()
+	var calls []string
+	m, err := migrate.New(db, migrate.Options{}, testMigrations(&calls)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done, err := m.Up(ctx)
+	if err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	if len(done) != 2 || strings.Join(calls, ",") != "up 1,up 2" {
+		t.Fatalf("unexpected up order: %v", calls)
+	}
+	if !db.Migrator().HasColumn("migrate_users", "age") {
+		t.Fatal("expected column age")
+	}
+	// 再次执行不会重复
+	if done, err := m.Up(ctx); err != nil || len(done) != 0 {
+		t.Fatalf("expected nothing to apply, got %v %v", done, err)
+	}
+
+	status, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if len(status) != 2 || !status[0].Applied || !status[1].Applied || status[0].AppliedAt.IsZero() {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	calls = nil
+	if done, err := m.Down(ctx); err != nil || len(done) != 1 || done[0].Version != 2 {
+		t.Fatalf("down: %v %v", done, err)
+	}
+	if db.Migrator().HasColumn("migrate_users", "age") {
+		t.Fatal("expected column age to be dropped")
+	}
+	if done, err := m.DownTo(ctx, 0); err != nil || len(done) != 1 || done[0].Version != 1 {
+		t.Fatalf("down to 0: %v %v", done, err)
+	}
+	if strings.Join(calls, ",") != "down 2,down 1" {
+		t.Fatalf("unexpected down order: %v", calls)
+	}
+	if done, err := m.UpTo(ctx, 1); err != nil || len(done) != 1 {
+		t.Fatalf("up to 1: %v %v", done, err)
+	}
+	status, _ = m.Status(ctx)
+	if !status[0].Applied || status[1].Applied {
+		t.Fatalf("expected only version 1 applied, got %+v", status)
+	}
+}
+
+func TestMigrateFailure(t *testing.T) {
+	db := newSQLDB(t)
+	ctx := context.Background()
+	m, err := migrate.New(db, migrate.Options{}, migrate.Migration{
+		Version: 1,
+		Name:    "broken",
+		Up: func(ctx context.Context, db *migrate.DB) error {
+			if err := db.SQL.Exec("CREATE TABLE broken_items (id INTEGER)").Error; err != nil {
+				return err
+			}
+			return errors.New("boom")
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected migration error, got %v", err)
+	}
+	// 事务回滚 不写入历史记录
+	if db.Migrator().HasTable("broken_items") {
+		t.Fatal("expected table to be rolled back")
+	}
+	status, _ := m.Status(ctx)
+	if len(status) != 1 || status[0].Applied {
+		t.Fatalf("expected pending migration, got %+v", status)
+	}
+	if _, err := m.Down(ctx); err != nil {
+		t.Fatalf("down without applied migrations: %v", err)
+	}
+}
+
+func TestMigrateLock(t *testing.T) {
+	db := newSQLDB(t)
+	ctx := context.Background()
+	var calls []string
+	migrations := testMigrations(&calls)
+
+	// 模拟其他实例持有锁
+	a, _ := migrate.New(db, migrate.Options{LockTimeout: 300 * time.Millisecond}, migrations...)
+	if _, err := a.Status(ctx); err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if err := db.Exec("INSERT INTO morm_migrations_lock (id, owner, locked_at) VALUES (1, 'other', ?)", time.Now()).Error; err != nil {
+		t.Fatalf("insert lock: %v", err)
+	}
+	if _, err := a.Up(ctx); !errors.Is(err, migrate.ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	if len(calls) != 0 {
+		t.Fatalf("expected no migration while locked, got %v", calls)
+	}
+
+	// 过期的锁可以被接管
+	b, _ := migrate.New(db, migrate.Options{LockTTL: time.Nanosecond}, migrations...)
+	if _, err := b.Up(ctx); err != nil {
+		t.Fatalf("expected stale lock to be taken over, got %v", err)
+	}
+	var count int64
+	db.Table("morm_migrations_lock").Count(&count)
+	if count != 0 {
+		t.Fatalf("expected lock to be released, got %d", count)
+	}
+}
+
+func TestMigrateLockHeartbeat(t *testing.T) {
+	db := newSQLDB(t)
+	ctx := context.Background()
+	// 迁移的事务占用一个连接 加锁使用另一个
+	sqlDB, _ := db.DB.DB()
+	sqlDB.SetMaxOpenConns(2)
+
+	started := make(chan struct{})
+	errSlow := errors.New("slow")
+	a, _ := migrate.New(db, migrate.Options{LockTTL: 300 * time.Millisecond}, migrate.Migration{
+		Version: 1,
+		Name:    "slow",
+		Up: func(ctx context.Context, db *migrate.DB) error {
+			close(started)
+			time.Sleep(900 * time.Millisecond)
+			// 返回错误使事务回滚 事务中不写入数据
+			return errSlow
+		},
+	})
+	result := make(chan error, 1)
+	go func() {
+		_, err := a.Up(ctx)
+		result <- err
+	}()
+
+	// 迁移执行时间超过 LockTTL 后锁仍被持有
+	<-started
+	time.Sleep(450 * time.Millisecond)
+	var calls []string
+	b, _ := migrate.New(db, migrate.Options{LockTimeout: 100 * time.Millisecond, LockTTL: 300 * time.Millisecond}, testMigrations(&calls)...)
+	if _, err := b.Up(ctx); !errors.Is(err, migrate.ErrLocked) {
+		t.Fatalf("expected ErrLocked while slow migration runs, got %v", err)
+	}
+	if err := <-result; !errors.Is(err, errSlow) {
+		t.Fatalf("expected slow migration error, got %v", err)
+	}
+	if len(calls) != 0 {
+		t.Fatalf("expected no migration while locked, got %v", calls)
+	}
+}
+
+func TestMigrateConcurrent(t *testing.T) {
+	db := newSQLDB(t)
+	ctx := context.Background()
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	migration := migrate.Migration{
+		Version: 1,
+		Name:    "once",
+		Up: func(ctx context.Context, db *migrate.DB) error {
+			mu.Lock()
+			calls = append(calls, "up")
+			mu.Unlock()
+			return db.SQL.Exec("CREATE TABLE once_items (id INTEGER)").Error
+		},
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		m, err := migrate.New(db, migrate.Options{}, migration)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = m.Up(ctx)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("up: %v", err)
+		}
+	}
+	if len(calls) != 1 {
+		t.Fatalf("expected migration to run once, got %v", calls)
+	}
+}
+
+func TestMigrateCreate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "migrations")
+	var out bytes.Buffer
+	err := migrate.RunCommand(context.Background(), []string{"-dir", dir, "create", "Add", "User Age"}, &out, func() (types.ORM, error) {
+		t.Fatal("create must not connect")
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*_add_user_age.go"))
+	if len(files) != 1 {
+		t.Fatalf("expected migration file, got %v (%s)", files, out.String())
+	}
+	src, _ := os.ReadFile(files[0])
+	f, err := parser.ParseFile(token.NewFileSet(), files[0], src, 0)
+	if err != nil {
+		t.Fatalf("generated file does not parse: %v", err)
+	}
+	if f.Name.Name != "migrations" || !strings.Contains(string(src), `Name:    "add_user_age"`) {
+		t.Fatalf("unexpected generated file:\n%s", src)
+	}
+}
+
+func TestMigrateCommandWithoutConnect(t *testing.T) {
+	// 没有编译迁移的命令只支持 create
+	dir := filepath.Join(t.TempDir(), "migrations")
+	var out bytes.Buffer
+	if err := migrate.RunCommand(context.Background(), []string{"-dir", dir, "create", "init"}, &out, nil); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	for _, cmd := range []string{"up", "down", "status"} {
+		err := migrate.RunCommand(context.Background(), []string{cmd}, &out, nil)
+		if err == nil || !strings.Contains(err.Error(), "migrate.RunCommand") {
+			t.Fatalf("expected %s to require an embedded command, got %v", cmd, err)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/lfhy/morm/db/mongodb"
+	"github.com/lfhy/morm/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+func TestMongoSlowMonitor(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { log.SetLogger(nil) })
+
+	monitor := mongodb.NewSlowMonitor(100 * time.Millisecond)
+	cmd, _ := bson.Marshal(bson.D{
+		{Key: "find", Value: "users"},
+		{Key: "filter", Value: bson.M{"password": "secret"}},
+		{Key: "sort", Value: bson.D{{Key: "age", Value: -1}}},
+		{Key: "limit", Value: 10},
+		{Key: "$db", Value: "app"},
+	})
+	ctx := context.Background()
+	// 未超过阈值
+	monitor.Started(ctx, &event.CommandStartedEvent{Command: cmd, CommandName: "find", RequestID: 1})
+	monitor.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: event.CommandFinishedEvent{RequestID: 1, Duration: 10 * time.Millisecond}})
+	// 超过阈值
+	monitor.Started(ctx, &event.CommandStartedEvent{Command: cmd, CommandName: "find", RequestID: 2})
+	monitor.Succeeded(ctx, &event.CommandSucceededEvent{CommandFinishedEvent: event.CommandFinishedEvent{RequestID: 2, Duration: 150 * time.Millisecond}})
+
+	logs := parseLogs(t, &buf)
+	slow := logs["slow query"]
+	if len(slow) != 1 {
+		t.Fatalf("expected 1 slow log, got %v", logs)
+	}
+	want := `users.find({"password":"?"}).sort({"age":-1}).limit(10)`
+	if slow[0]["statement"] != want || slow[0]["backend"] != "mongodb" || slow[0]["table"] != "users" {
+		t.Fatalf("unexpected slow log %v", slow[0])
+	}
+}
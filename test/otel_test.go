@@ -0,0 +1,104 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/lfhy/morm/otel"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// otelItem 用于测试链路追踪
+type otelItem struct {
+	ID   int    `gorm:"column:id;primaryKey;autoIncrement"`
+	Name string `gorm:"column:name"`
+}
+
+func (otelItem) TableName() string { return "otel_items" }
+
+func newTestTracer() (*tracetest.InMemoryExporter, *sdktrace.TracerProvider) {
+	exporter := tracetest.NewInMemoryExporter()
+	return exporter, sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+}
+
+func spanAttr(span tracetest.SpanStub, key string) string {
+	for _, kv := range span.Attributes {
+		if string(kv.Key) == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestOtelSQL(t *testing.T) {
+	db := newSQLDB(t, &otelItem{})
+	exporter, tp := newTestTracer()
+	if err := otel.Instrument(db, otel.WithTracerProvider(tp)); err != nil {
+		t.Fatalf("instrument: %v", err)
+	}
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	if _, err := db.Model(&otelItem{}).SetContext(ctx).Create(&otelItem{Name: "secret"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	var got otelItem
+	if err := db.Model(&otelItem{}).SetContext(ctx).Where("name", "secret").One(&got); err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	parent.End()
+
+	spans := exporter.GetSpans()
+	// 最后一个为 parent
+	if len(spans) < 3 || spans[0].Name != "INSERT otel_items" || spans[len(spans)-2].Name != "SELECT otel_items" {
+		t.Fatalf("unexpected spans %v", spans)
+	}
+	for i, span := range spans[:len(spans)-1] {
+		if span.Parent.SpanID() != parent.SpanContext().SpanID() {
+			t.Fatalf("span %d: expected parent from model context", i)
+		}
+		if spanAttr(span, "db.system") != "sqlite" || spanAttr(span, "db.sql.table") != "otel_items" {
+			t.Fatalf("span %d: unexpected attributes %v", i, span.Attributes)
+		}
+	}
+	if stmt := spanAttr(spans[len(spans)-2], "db.statement"); stmt == "" || strings.Contains(stmt, "secret") {
+		t.Fatalf("expected sanitized statement, got %q", stmt)
+	}
+}
+
+func TestOtelMongoMonitor(t *testing.T) {
+	exporter, tp := newTestTracer()
+	monitor := otel.NewCommandMonitor(otel.WithTracerProvider(tp), otel.WithAttributes(attribute.String("env", "test")))
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	cmd, _ := bson.Marshal(bson.D{
+		{Key: "find", Value: "users"},
+		{Key: "filter", Value: bson.M{"password": "secret"}},
+		{Key: "limit", Value: 1},
+	})
+	monitor.Started(ctx, &event.CommandStartedEvent{Command: cmd, DatabaseName: "app", CommandName: "find", RequestID: 1})
+	monitor.Failed(ctx, &event.CommandFailedEvent{CommandFinishedEvent: event.CommandFinishedEvent{CommandName: "find", RequestID: 1}, Failure: "boom"})
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "find users" || span.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("unexpected span %q", span.Name)
+	}
+	if spanAttr(span, "db.mongodb.collection") != "users" || spanAttr(span, "db.name") != "app" || spanAttr(span, "env") != "test" {
+		t.Fatalf("unexpected attributes %v", span.Attributes)
+	}
+	if stmt := spanAttr(span, "db.statement"); strings.Contains(stmt, "secret") || !strings.Contains(stmt, "password") {
+		t.Fatalf("expected sanitized statement, got %q", stmt)
+	}
+	if span.Status.Description != "boom" {
+		t.Fatalf("expected error status, got %+v", span.Status)
+	}
+}
@@ -0,0 +1,123 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lfhy/morm"
+	"github.com/lfhy/morm/db/mongodb"
+	"github.com/lfhy/morm/db/sqlorm"
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var (
+	_ types.Planner = (*sqlorm.DBConn)(nil)
+	_ types.Planner = (*mongodb.DBConn)(nil)
+)
+
+// planItem 数据库中为旧结构
+type planItem struct {
+	ID    int    `gorm:"column:id;primaryKey;autoIncrement"`
+	Name  string `gorm:"column:name;not null"`
+	Email string `morm:"column:email;index"`
+	Age   int    `gorm:"column:age"`
+}
+
+func (planItem) TableName() string { return "plan_items" }
+
+type planOther struct {
+	ID int `gorm:"column:id;primaryKey;autoIncrement"`
+}
+
+func (planOther) TableName() string { return "plan_others" }
+
+func TestPlanSQL(t *testing.T) {
+	db := newSQLDB(t)
+	if err := db.Exec("CREATE TABLE `plan_items` (`id` integer PRIMARY KEY AUTOINCREMENT, `name` text NOT NULL, `age` text, `legacy` text)").Error; err != nil {
+		t.Fatal(err)
+	}
+	plan, err := morm.Plan(context.Background(), db, &planItem{}, &planOther{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]types.SchemaChange)
+	for _, c := range plan.Changes {
+		got[c.Kind+" "+c.Table+"."+c.Name] = c
+	}
+	for _, key := range []string{
+		"add_column plan_items.email",
+		"alter_column plan_items.age",
+		"extra_column plan_items.legacy",
+		"create_index plan_items.idx_plan_items_email",
+		"create_table plan_others.",
+	} {
+		if _, ok := got[key]; !ok {
+			t.Fatalf("missing change %q in:\n%s", key, plan)
+		}
+	}
+	if len(plan.Statements) == 0 || plan.Empty(true) {
+		t.Fatalf("expected statements, got:\n%s", plan)
+	}
+	if text := plan.String(); !strings.Contains(text, "CREATE TABLE `plan_others`") || strings.Contains(text, "SAVEPOINT") {
+		t.Fatalf("expected create table statement, got:\n%s", plan)
+	}
+
+	// 生成计划不修改数据库
+	if db.Migrator().HasTable("plan_others") || db.Migrator().HasColumn(&planItem{}, "email") {
+		t.Fatal("plan must not apply changes")
+	}
+
+	if err := db.DB.AutoMigrate(&planItem{}, &planOther{}); err != nil {
+		t.Fatal(err)
+	}
+	plan, err = morm.Plan(context.Background(), db, &planItem{}, &planOther{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Empty(true) || plan.Empty(false) {
+		t.Fatalf("expected only the extra column, got:\n%s", plan)
+	}
+}
+
+func TestPlanCommand(t *testing.T) {
+	db := newSQLDB(t)
+	morm.RegisterModels(&planOther{})
+	connect := func() (morm.ORM, error) { return db, nil }
+
+	var out strings.Builder
+	err := morm.RunPlanCommand(context.Background(), nil, &out, connect)
+	if !errors.Is(err, morm.ErrSchemaDrift) || !strings.Contains(out.String(), "+ plan_others create table") {
+		t.Fatalf("expected drift, got %v:\n%s", err, out.String())
+	}
+	if err := db.DB.AutoMigrate(&planOther{}); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := morm.RunPlanCommand(context.Background(), nil, &out, connect); err != nil {
+		t.Fatalf("expected no drift, got %v:\n%s", err, out.String())
+	}
+}
+
+func TestPlanIndexes(t *testing.T) {
+	specs, _ := mongodb.ModelIndexes(&indexItem{})
+	existing := []mongodb.IndexInfo{
+		{Name: "name_1", Key: bson.D{{Key: "name", Value: int32(1)}}, Unique: true},
+		{Name: "old_index", Key: bson.D{{Key: "legacy", Value: int32(1)}}},
+	}
+	plan := &types.SchemaPlan{}
+	mongodb.PlanIndexes(plan, "index_items", mongodb.DiffIndexes(specs, existing))
+	text := plan.String()
+	for _, want := range []string{
+		"~ index_items.name_1 alter index",
+		"- index_items.old_index extra index",
+		`db.index_items.dropIndex("name_1")`,
+		`db.index_items.createIndex({"email":1}, {"name":"email_1","unique":true})`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("expected %q in:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lfhy/morm/db/mongodb"
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+type preloadCompany struct {
+	ID   int    `gorm:"column:id;primaryKey" bson:"_id"`
+	Name string `gorm:"column:name" bson:"name"`
+}
+
+func (preloadCompany) TableName() string { return "preload_companies" }
+
+type preloadProfile struct {
+	ID            int    `gorm:"column:id;primaryKey" bson:"_id"`
+	PreloadUserID int    `gorm:"column:preload_user_id" bson:"preload_user_id"`
+	Bio           string `gorm:"column:bio" bson:"bio"`
+}
+
+func (preloadProfile) TableName() string { return "preload_profiles" }
+
+type preloadItem struct {
+	ID      int    `gorm:"column:id;primaryKey" bson:"_id"`
+	OrderID int    `gorm:"column:order_id" bson:"order_id"`
+	SKU     string `gorm:"column:sku" bson:"sku"`
+}
+
+func (preloadItem) TableName() string { return "preload_items" }
+
+type preloadOrder struct {
+	ID      int           `gorm:"column:id;primaryKey" bson:"_id"`
+	OwnerID int           `gorm:"column:owner_id" bson:"owner_id"`
+	State   string        `gorm:"column:state" bson:"state"`
+	Items   []preloadItem `gorm:"foreignKey:OrderID" bson:"-"`
+}
+
+func (preloadOrder) TableName() string { return "preload_orders" }
+
+type preloadTag struct {
+	ID   int    `gorm:"column:id;primaryKey" bson:"_id"`
+	Name string `gorm:"column:name" bson:"name"`
+}
+
+func (preloadTag) TableName() string { return "preload_tags" }
+
+type preloadUser struct {
+	ID        int             `gorm:"column:id;primaryKey" bson:"_id"`
+	Name      string          `gorm:"column:name" bson:"name"`
+	CompanyID int             `gorm:"column:company_id" bson:"company_id"`
+	Company   *preloadCompany `bson:"-"`
+	Profile   *preloadProfile `bson:"-"`
+	Orders    []preloadOrder  `gorm:"foreignKey:OwnerID" bson:"-"`
+	Tags      []preloadTag    `gorm:"many2many:preload_user_tags" bson:"-"`
+}
+
+func (preloadUser) TableName() string { return "preload_users" }
+
+func TestPreloadSQL(t *testing.T) {
+	db := newSQLDB(t, &preloadCompany{}, &preloadTag{}, &preloadUser{}, &preloadProfile{}, &preloadOrder{}, &preloadItem{})
+	tags := []preloadTag{{ID: 1, Name: "vip"}, {ID: 2, Name: "new"}}
+	users := []preloadUser{
+		{
+			ID: 1, Name: "alice",
+			Company: &preloadCompany{ID: 10, Name: "acme"},
+			Profile: &preloadProfile{ID: 100, Bio: "hi"},
+			Orders: []preloadOrder{
+				{ID: 1000, State: "paid", Items: []preloadItem{{ID: 1, SKU: "a"}, {ID: 2, SKU: "b"}}},
+				{ID: 1001, State: "cancelled"},
+			},
+			Tags: tags,
+		},
+		{ID: 2, Name: "bob", Tags: tags[1:]},
+	}
+	if err := db.DB.Create(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	paid := func(m types.ORMModel) types.ORMModel { return m.Where("state", "paid") }
+	var list []preloadUser
+	err := db.Model(&preloadUser{}).
+		Preload("Company").
+		Preload("Profile").
+		Preload("Orders", paid).
+		Preload("Orders.Items").
+		Preload("Tags").
+		Asc("id").
+		All(&list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 users, got %d", len(list))
+	}
+	alice, bob := list[0], list[1]
+	if alice.Company == nil || alice.Company.Name != "acme" || bob.Company != nil {
+		t.Fatalf("unexpected belongs to %+v %+v", alice.Company, bob.Company)
+	}
+	if alice.Profile == nil || alice.Profile.Bio != "hi" {
+		t.Fatalf("unexpected has one %+v", alice.Profile)
+	}
+	if len(alice.Orders) != 1 || alice.Orders[0].ID != 1000 || len(alice.Orders[0].Items) != 2 {
+		t.Fatalf("unexpected has many %+v", alice.Orders)
+	}
+	if len(alice.Tags) != 2 || len(bob.Tags) != 1 || bob.Tags[0].Name != "new" {
+		t.Fatalf("unexpected many2many %+v %+v", alice.Tags, bob.Tags)
+	}
+
+	var one preloadUser
+	if err := db.Model(&preloadUser{}).Where("name", "alice").Preload("Orders").One(&one); err != nil {
+		t.Fatal(err)
+	}
+	if len(one.Orders) != 2 || one.Profile != nil {
+		t.Fatalf("unexpected one %+v", one)
+	}
+
+	// 游标占用一个连接 预加载需要另一个连接
+	sqlDB, _ := db.DB.DB()
+	sqlDB.SetMaxOpenConns(2)
+	cur, err := db.Model(&preloadUser{}).Preload("Tags").Asc("id").Cursor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cur.Close()
+	var counts []int
+	for cur.Next() {
+		var u preloadUser
+		if err := cur.Decode(&u); err != nil {
+			t.Fatal(err)
+		}
+		counts = append(counts, len(u.Tags))
+	}
+	if len(counts) != 2 || counts[0] != 2 || counts[1] != 1 {
+		t.Fatalf("unexpected cursor preload %v", counts)
+	}
+
+	err = db.Model(&preloadUser{}).Preload("Missing").All(&list)
+	if !errors.Is(err, types.ErrUnknownField) {
+		t.Fatalf("expected ErrUnknownField, got %v", err)
+	}
+}
+
+func TestParseRelation(t *testing.T) {
+	for _, c := range []struct {
+		field, typ, own, rel string
+	}{
+		{"Company", mongodb.RelationBelongsTo, "CompanyID", "ID"},
+		{"Profile", mongodb.RelationHasOne, "ID", "PreloadUserID"},
+		{"Orders", mongodb.RelationHasMany, "ID", "OwnerID"},
+		{"Tags", mongodb.RelationMany2Many, "ID", "ID"},
+	} {
+		rel, err := mongodb.ParseRelation(&preloadUser{}, c.field)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rel.Type != c.typ || rel.OwnKey.Name != c.own || rel.RelKey.Name != c.rel {
+			t.Fatalf("%s: unexpected relation %s %s %s", c.field, rel.Type, rel.OwnKey.Name, rel.RelKey.Name)
+		}
+	}
+	rel, _ := mongodb.ParseRelation(&preloadUser{}, "Tags")
+	if rel.JoinCollection != "preload_user_tags" || rel.JoinOwnKey != "preload_user_id" || rel.JoinRelKey != "preload_tag_id" {
+		t.Fatalf("unexpected join %+v", rel)
+	}
+	if _, err := mongodb.ParseRelation(&preloadUser{}, "Name"); !errors.Is(err, types.ErrUnknownField) {
+		t.Fatalf("expected ErrUnknownField, got %v", err)
+	}
+}
+
+type mongoAuthor struct {
+	ID    primitive.ObjectID `bson:"_id"`
+	Books []*mongoBook       `bson:"-" gorm:"foreignKey:AuthorID"`
+}
+
+type mongoBook struct {
+	ID       primitive.ObjectID `bson:"_id"`
+	AuthorID string             `bson:"author_id"`
+	Title    string             `bson:"title"`
+}
+
+func TestRelationAssign(t *testing.T) {
+	a, b := primitive.NewObjectID(), primitive.NewObjectID()
+	authors := []mongoAuthor{{ID: a}, {ID: b}, {ID: a}}
+	rel, err := mongodb.ParseRelation(&authors, "Books")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := rel.Keys(&authors); len(keys) != 2 {
+		t.Fatalf("expected deduplicated keys, got %v", keys)
+	}
+	// 外键为十六进制字符串时同样可以匹配
+	books := []mongoBook{{AuthorID: a.Hex(), Title: "x"}, {AuthorID: a.Hex(), Title: "y"}}
+	if err := rel.Assign(&authors, &books, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(authors[0].Books) != 2 || authors[0].Books[1].Title != "y" || len(authors[2].Books) != 2 {
+		t.Fatalf("unexpected books %+v", authors)
+	}
+	if authors[1].Books == nil || len(authors[1].Books) != 0 {
+		t.Fatalf("expected empty books, got %+v", authors[1].Books)
+	}
+
+	tags, _ := mongodb.ParseRelation(&preloadUser{}, "Tags")
+	user := preloadUser{ID: 1}
+	joins := []bson.M{{"preload_user_id": int32(1), "preload_tag_id": int32(2)}, {"preload_user_id": int32(9), "preload_tag_id": int32(1)}}
+	if err := tags.Assign(&user, []preloadTag{{ID: 1, Name: "vip"}, {ID: 2, Name: "new"}}, joins); err != nil {
+		t.Fatal(err)
+	}
+	if len(user.Tags) != 1 || user.Tags[0].Name != "new" {
+		t.Fatalf("unexpected tags %+v", user.Tags)
+	}
+}
+
+func TestPreloadMongo(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("hex foreign key", func(mt *mtest.T) {
+		a, b := primitive.NewObjectID(), primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "test.authors", mtest.FirstBatch, bson.D{{Key: "_id", Value: a}}, bson.D{{Key: "_id", Value: b}}),
+			mtest.CreateCursorResponse(0, "test.books", mtest.FirstBatch,
+				bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "author_id", Value: a.Hex()}, {Key: "title", Value: "x"}},
+				bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "author_id", Value: b.Hex()}, {Key: "title", Value: "y"}},
+			),
+		)
+		db := newMockMongo(mt)
+		var authors []mongoAuthor
+		if err := db.Model(&mongoAuthor{}).Preload("Books").All(&authors); err != nil {
+			mt.Fatal(err)
+		}
+		if len(authors) != 2 || len(authors[0].Books) != 1 || authors[0].Books[0].Title != "x" || authors[1].Books[0].Title != "y" {
+			mt.Fatalf("unexpected authors %+v", authors)
+		}
+
+		// 外键以字符串保存 查询条件同时包含 ObjectID 与十六进制字符串
+		mt.GetStartedEvent()
+		find := mt.GetStartedEvent()
+		if find == nil || find.CommandName != "find" {
+			mt.Fatalf("expected preload find, got %v", find)
+		}
+		in, err := find.Command.LookupErr("filter", "author_id", "$in")
+		if err != nil {
+			mt.Fatal(err)
+		}
+		values, _ := in.Array().Values()
+		var oids, hexes int
+		for _, v := range values {
+			switch v.Type {
+			case bson.TypeObjectID:
+				oids++
+			case bson.TypeString:
+				hexes++
+			}
+		}
+		if oids != 2 || hexes != 2 {
+			mt.Fatalf("expected both key forms in $in, got %v", in)
+		}
+	})
+}
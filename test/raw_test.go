@@ -0,0 +1,100 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lfhy/morm/db/mongodb"
+	"github.com/lfhy/morm/log"
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRawSQL(t *testing.T) {
+	db := newSQLDB(t, &zeroItem{})
+	var ops []*types.Operation
+	db.Use(func(next types.Handler) types.Handler {
+		return func(ctx context.Context, op *types.Operation) error {
+			ops = append(ops, op)
+			return next(ctx, op)
+		}
+	})
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err := db.Model(&zeroItem{}).Create(&zeroItem{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := db.Model(&zeroItem{}).Raw("UPDATE zero_items SET status = ? WHERE name <> ?", 2, "a").Exec()
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 rows affected, got %d %v", n, err)
+	}
+	if last := ops[len(ops)-1]; last.Type != types.OpExec || last.Rows != 2 || last.Table != "zero_items" {
+		t.Fatalf("unexpected operation %+v", last)
+	}
+
+	var list []zeroItem
+	if err := db.Model(&zeroItem{}).Raw("SELECT * FROM zero_items WHERE status = ? ORDER BY id", 2).All(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Name != "b" {
+		t.Fatalf("unexpected list %+v", list)
+	}
+
+	var one zeroItem
+	if err := db.Model(&zeroItem{}).Raw("SELECT * FROM zero_items WHERE name = ?", "c").One(&one); err != nil || one.Status != 2 {
+		t.Fatalf("unexpected one %+v %v", one, err)
+	}
+	err = db.Model(&zeroItem{}).Raw("SELECT * FROM zero_items WHERE name = ?", "x").One(&one)
+	if !log.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	cur, err := db.Model(&zeroItem{}).Raw("SELECT * FROM zero_items ORDER BY id DESC").Cursor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for cur.Next() {
+		var item zeroItem
+		if err := cur.Decode(&item); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, item.Name)
+	}
+	cur.Close()
+	if len(names) != 3 || names[0] != "c" {
+		t.Fatalf("unexpected cursor %v", names)
+	}
+
+	// 原始查询不受之前条件错误的影响
+	model := db.Model(&zeroItem{}).WhereFields(&zeroItem{}, "missing")
+	if err := model.Raw("SELECT * FROM zero_items ORDER BY id").All(&list); err != nil || len(list) != 3 {
+		t.Fatalf("expected raw query to ignore where error, got %d %v", len(list), err)
+	}
+	if err := model.All(&list); !errors.Is(err, types.ErrUnknownField) {
+		t.Fatalf("expected where error to remain, got %v", err)
+	}
+
+	if err := db.Model(&zeroItem{}).Pipeline(bson.A{}).All(&list); !errors.Is(err, types.ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestRawMongoCommand(t *testing.T) {
+	m := &mongodb.Model{Tx: &mongodb.DBConn{}, Collection: "users", WhereList: bson.M{}}
+	var list []bson.M
+	if err := m.Raw(`{"find":"users","filter":{"age":{"$gt":?}}}`, 18).All(&list); !errors.Is(err, types.ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+	if err := m.Raw(`{"find":`).All(&list); err == nil {
+		t.Fatal("expected parse error")
+	}
+
+	// 原始查询不受之前条件错误的影响 返回的是原始查询自身的错误
+	m.WhereFields(&zeroItem{}, "missing")
+	if err := m.Raw(`{"find":"users"}`, 1).All(&list); !errors.Is(err, types.ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+}
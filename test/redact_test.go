@@ -0,0 +1,149 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	stdlog "log"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/lfhy/morm/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"gorm.io/gorm/logger"
+)
+
+// redactItem 用于测试日志脱敏
+type redactItem struct {
+	ID       int    `gorm:"column:id;primaryKey;autoIncrement"`
+	Name     string `gorm:"column:name"`
+	Password string `gorm:"column:password" morm:"sensitive"`
+	Token    string `gorm:"column:token"`
+	Phone    string `gorm:"column:phone"`
+}
+
+func (redactItem) TableName() string { return "redact_items" }
+
+func newRedactLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetRedactPolicy(&log.RedactPolicy{
+		Fields:   []string{"token"},
+		Patterns: []*regexp.Regexp{regexp.MustCompile(`1[3-9]\d{9}`)},
+	})
+	log.SetDBLoger(logger.New(stdlog.New(&buf, "", 0), logger.Config{LogLevel: logger.Info}))
+	t.Cleanup(func() {
+		log.SetRedactPolicy(nil)
+		log.SetDBLoger(logger.Discard)
+	})
+	return &buf
+}
+
+func TestRedactSQL(t *testing.T) {
+	db := newSQLDB(t, &redactItem{})
+	buf := newRedactLog(t)
+	db.SetLogger(log.GetDBLoger())
+
+	item := &redactItem{Name: "foo", Password: "hunter2", Token: "tok-123", Phone: "13812345678"}
+	if _, err := db.Model(&redactItem{}).Create(item); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	var got redactItem
+	if err := db.Model(&redactItem{}).Where("password", "hunter2").One(&got); err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if got.Password != "hunter2" {
+		t.Fatalf("redaction must not change stored data, got %q", got.Password)
+	}
+
+	out := buf.String()
+	for _, secret := range []string{"hunter2", "tok-123", "13812345678"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("log contains %q:\n%s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "foo") || !strings.Contains(out, "***") {
+		t.Fatalf("expected masked sql log, got:\n%s", out)
+	}
+}
+
+func TestRedactMongoLog(t *testing.T) {
+	buf := newRedactLog(t)
+	log.RegisterSensitive(&redactItem{})
+	log.Debugf("Mongo查询条件: %+v 数据: %+v", bson.M{
+		"$or": bson.A{bson.M{"password": "hunter2"}, bson.M{"name": "foo"}},
+	}, &redactItem{Name: "foo", Token: "tok-123", Phone: "13812345678"})
+
+	out := buf.String()
+	for _, secret := range []string{"hunter2", "tok-123", "13812345678"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("log contains %q:\n%s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "foo") {
+		t.Fatalf("expected unmasked fields, got:\n%s", out)
+	}
+}
+
+func TestRedactParamsFilter(t *testing.T) {
+	p := &log.RedactPolicy{Fields: []string{"secret"}, Mask: "***"}
+	ctx := context.Background()
+	cases := []struct {
+		sql    string
+		params []any
+		want   []any
+	}{
+		{"INSERT INTO `t` (`name`,`secret`) VALUES (?,?),(?,?)", []any{"a", "s1", "b", "s2"}, []any{"a", "***", "b", "***"}},
+		{"SELECT * FROM `t` WHERE `t`.`secret` = ? AND name IN (?,?) LIMIT ?", []any{"s", "a", "b", 1}, []any{"***", "a", "b", 1}},
+		{"UPDATE `t` SET `secret`=?,`name`=? WHERE id = ?", []any{"s", "a", 1}, []any{"***", "a", 1}},
+	}
+	for _, c := range cases {
+		_, got := p.ParamsFilter(ctx, c.sql, c.params...)
+		for i := range c.want {
+			if got[i] != c.want[i] {
+				t.Fatalf("%s: expected %v, got %v", c.sql, c.want, got)
+			}
+		}
+	}
+}
+
+// redactOther 与 redactItem 有同名但不敏感的列
+type redactOther struct {
+	ID       int    `gorm:"column:id;primaryKey;autoIncrement"`
+	Password string `gorm:"column:password"`
+}
+
+func (redactOther) TableName() string { return "redact_others" }
+
+func TestRedactPerTable(t *testing.T) {
+	db := newSQLDB(t, &redactItem{}, &redactOther{})
+	buf := newRedactLog(t)
+	db.SetLogger(log.GetDBLoger())
+
+	if _, err := db.Model(&redactItem{}).Create(&redactItem{Name: "foo", Password: "hunter2"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := db.Model(&redactOther{}).Create(&redactOther{Password: "plain-pw"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	var other redactOther
+	if err := db.Model(&redactOther{}).Where("password", "plain-where").One(&other); !log.IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	log.Debugf("Mongo查询条件: %+v %+v",
+		log.Table("redact_items", bson.M{"password": "mongo-secret"}),
+		log.Table("redact_others", bson.M{"password": "mongo-plain"}),
+	)
+
+	out := buf.String()
+	for _, secret := range []string{"hunter2", "mongo-secret"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("log contains %q:\n%s", secret, out)
+		}
+	}
+	for _, plain := range []string{"plain-pw", "plain-where", "mongo-plain"} {
+		if !strings.Contains(out, plain) {
+			t.Fatalf("expected %q of an unrelated table in log:\n%s", plain, out)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lfhy/morm/db/mongodb"
+)
+
+// 多层匿名嵌套的模型
+type BenchBase struct {
+	ID        int       `gorm:"column:id;primaryKey;autoIncrement" bson:"_id,omitempty"`
+	CreatedAt time.Time `gorm:"column:created_at" bson:"created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at" bson:"updated_at"`
+}
+
+type BenchAudit struct {
+	BenchBase
+	CreatedBy string `gorm:"column:created_by" bson:"created_by"`
+	UpdatedBy string `gorm:"column:updated_by" bson:"updated_by"`
+}
+
+type benchItem struct {
+	BenchAudit
+	Name   string  `gorm:"column:name" bson:"name"`
+	Email  string  `gorm:"column:email" bson:"email"`
+	Age    int     `gorm:"column:age" bson:"age"`
+	Score  float64 `gorm:"column:score" bson:"score"`
+	Status int     `gorm:"column:status" bson:"status"`
+	Remark string  `gorm:"column:remark" bson:"remark"`
+}
+
+func (benchItem) TableName() string { return "bench_items" }
+
+func newBenchItem() *benchItem {
+	item := &benchItem{Name: "foo", Email: "foo@bar.com", Age: 18, Score: 9.5, Status: 1, Remark: "remark"}
+	item.CreatedBy = "admin"
+	item.UpdatedBy = "admin"
+	return item
+}
+
+func BenchmarkWhereSQL(b *testing.B) {
+	db := newSQLDB(b, &benchItem{})
+	item := newBenchItem()
+	item.ID = 1
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		db.Model(&benchItem{}).Where(item)
+	}
+}
+
+func BenchmarkWhereMongo(b *testing.B) {
+	conn := &mongodb.DBConn{}
+	item := newBenchItem()
+	item.ID = 1
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		conn.Model(&benchItem{}).Where(item)
+	}
+}
+
+func BenchmarkCreateSQL(b *testing.B) {
+	db := newSQLDB(b, &benchItem{})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.Model(&benchItem{}).Create(newBenchItem()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Mongo 写入前的数据转换
+func BenchmarkCreateMongo(b *testing.B) {
+	item := newBenchItem()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := mongodb.ConvertToBSONM(item); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUpdateSQL(b *testing.B) {
+	db := newSQLDB(b, &benchItem{})
+	item := newBenchItem()
+	if _, err := db.Model(&benchItem{}).Create(item); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		item.Age = i
+		if err := db.Model(&benchItem{}).Where("id", item.ID).Update(item); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lfhy/morm/db/mongodb"
+	"github.com/lfhy/morm/schema"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// tagItem 只使用 morm 标签
+type tagItem struct {
+	UID   string `morm:"column:uid;pk"`
+	Name  string `morm:"column:user_name;index"`
+	Email string `morm:"column:email;unique"`
+	Age   int    `morm:"column:age;must"`
+	Skip  string `gorm:"-" bson:"-"`
+}
+
+func (tagItem) TableName() string { return "tag_items" }
+
+// legacyTagItem 使用 gorm 与 bson 标签
+type legacyTagItem struct {
+	ID   string `gorm:"primaryKey;column:id" bson:"_id,omitempty"`
+	Name string `gorm:"name" bson:"name,omitempty"`
+	Age  int    `gorm:"column:age" bson:"age,must"`
+	Note string
+}
+
+func TestSchemaParse(t *testing.T) {
+	sch := schema.Parse(reflect.TypeOf(&tagItem{}))
+	uid := sch.Fields[0]
+	if uid.Column != "uid" || uid.BSON != "_id" || !uid.PrimaryKey {
+		t.Fatalf("unexpected pk field: %+v", uid)
+	}
+	if f := sch.LookUp("user_name"); f == nil || f.Name != "Name" || !f.Indexed {
+		t.Fatalf("unexpected name field: %+v", f)
+	}
+	if f := sch.LookUp("email"); f == nil || !f.Unique {
+		t.Fatalf("unexpected email field: %+v", f)
+	}
+	if !sch.Fields[3].Must || sch.Fields[4].Column != "" || sch.Fields[4].BSON != "" {
+		t.Fatalf("unexpected fields: %+v %+v", sch.Fields[3], sch.Fields[4])
+	}
+	// 选项只以 ; 分隔 值中的 , 保持不变
+	opts := schema.ParseTag("column:code;pattern:^[0-9]{1,3}$;enum:a,b")
+	if len(opts) != 3 || opts["pattern"] != "^[0-9]{1,3}$" || opts["enum"] != "a,b" {
+		t.Fatalf("unexpected tag options: %v", opts)
+	}
+	if schema.Parse(reflect.TypeOf(tagItem{})) != sch {
+		t.Fatal("expected cached schema")
+	}
+
+	legacy := schema.Parse(reflect.TypeOf(legacyTagItem{}))
+	want := []struct {
+		column, bson string
+		pk, must     bool
+	}{
+		{"id", "_id", true, false},
+		{"name", "name", false, false},
+		{"age", "age", false, true},
+		{"", "", false, false},
+	}
+	for i, w := range want {
+		f := legacy.Fields[i]
+		if f.Column != w.column || f.BSON != w.bson || f.PrimaryKey != w.pk || f.Must != w.must {
+			t.Fatalf("field %s: unexpected %+v", f.Name, f)
+		}
+	}
+}
+
+func TestSchemaSQL(t *testing.T) {
+	db := newSQLDB(t)
+	db.AutoMigrate = true
+	model := db.Model(&tagItem{})
+	for _, column := range []string{"uid", "user_name", "email", "age"} {
+		if !db.Migrator().HasColumn(&tagItem{}, column) {
+			t.Fatalf("expected column %s", column)
+		}
+	}
+	if !db.Migrator().HasIndex(&tagItem{}, "idx_tag_items_name") {
+		t.Fatal("expected index on user_name")
+	}
+
+	id, err := model.Create(&tagItem{UID: "u1", Name: "foo", Email: "foo@bar.com"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if id != "u1" {
+		t.Fatalf("expected id u1, got %q", id)
+	}
+	if _, err := db.Model(&tagItem{}).Create(&tagItem{UID: "u2", Name: "bar", Email: "foo@bar.com"}); err == nil {
+		t.Fatal("expected unique constraint error")
+	}
+
+	var got tagItem
+	if err := db.Model(&tagItem{}).Where(&tagItem{Name: "foo"}).One(&got); err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if got.UID != "u1" || got.Email != "foo@bar.com" {
+		t.Fatalf("unexpected row: %+v", got)
+	}
+	if err := db.Model(&tagItem{}).Where("uid", "u1").Update(&tagItem{Age: 3}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	got = tagItem{}
+	if err := db.Model(&tagItem{}).Where("uid", "u1").One(&got); err != nil || got.Age != 3 {
+		t.Fatalf("expected age 3, got %+v %v", got, err)
+	}
+}
+
+func TestSchemaBSON(t *testing.T) {
+	m, err := mongodb.ConvertToBSONM(&tagItem{UID: "u1", Name: "foo", Skip: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["_id"] != "u1" || m["user_name"] != "foo" {
+		t.Fatalf("unexpected bson: %+v", m)
+	}
+	if v, ok := m["age"]; !ok || v != 0 {
+		t.Fatalf("expected must field age, got %+v", m)
+	}
+	if _, ok := m["email"]; ok {
+		t.Fatalf("expected zero email to be skipped, got %+v", m)
+	}
+
+	// 驱动编解码同样识别 morm 标签
+	reg := mongodb.NewRegistry()
+	data, err := bson.MarshalWithRegistry(reg, &tagItem{UID: "u1", Name: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := bson.Raw(data)
+	if raw.Lookup("_id").StringValue() != "u1" || raw.Lookup("user_name").StringValue() != "foo" {
+		t.Fatalf("unexpected document: %s", raw)
+	}
+	var decoded tagItem
+	if err := bson.UnmarshalWithRegistry(reg, data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.UID != "u1" || decoded.Name != "foo" {
+		t.Fatalf("unexpected decoded: %+v", decoded)
+	}
+}
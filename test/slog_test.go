@@ -0,0 +1,111 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lfhy/morm/conf"
+	"github.com/lfhy/morm/log"
+	"github.com/lfhy/morm/types"
+	"github.com/spf13/viper"
+	"gorm.io/gorm/logger"
+)
+
+// slogItem 用于测试结构化日志
+type slogItem struct {
+	ID   int    `gorm:"column:id;primaryKey;autoIncrement"`
+	Name string `gorm:"column:name"`
+}
+
+func (slogItem) TableName() string { return "slog_items" }
+
+// 解析 JSON 日志 按 msg 分组
+func parseLogs(t *testing.T, buf *bytes.Buffer) map[string][]map[string]any {
+	t.Helper()
+	logs := make(map[string][]map[string]any)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("parse log %q: %v", line, err)
+		}
+		msg, _ := record["msg"].(string)
+		logs[msg] = append(logs[msg], record)
+	}
+	return logs
+}
+
+func TestSlogLogger(t *testing.T) {
+	db := newSQLDB(t, &slogItem{})
+	var buf bytes.Buffer
+	log.SetLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { log.SetLogger(nil) })
+	db.DB.Logger = log.GetDBLoger()
+
+	if _, err := db.Model(&slogItem{}).Create(&slogItem{Name: "foo"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	db.Model(&slogItem{}).Where("name", "bar").One(&slogItem{})
+
+	logs := parseLogs(t, &buf)
+	ops := logs["morm operation"]
+	if len(ops) != 1 {
+		t.Fatalf("expected 1 operation log, got %v", logs)
+	}
+	op := ops[0]
+	if op["backend"] != "sqlite" || op["table"] != "slog_items" || op["op"] != "create" || op["rows"] != float64(1) {
+		t.Fatalf("unexpected operation log %v", op)
+	}
+	if _, ok := op["duration"]; !ok {
+		t.Fatalf("expected duration in %v", op)
+	}
+	failed := logs["morm operation failed"]
+	if len(failed) != 1 || failed[0]["op"] != "find_one" || failed[0]["error"] != "record not found" {
+		t.Fatalf("expected not found to be logged, got %v", failed)
+	}
+	if len(logs["sql"]) == 0 || len(logs["sql failed"]) != 1 {
+		t.Fatalf("expected sql logs from gorm adapter, got %v", logs)
+	}
+}
+
+func TestSlogGormLoggerIgnoreNotFound(t *testing.T) {
+	db := newSQLDB(t, &slogItem{})
+	var buf bytes.Buffer
+	l := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	db.DB.Logger = log.NewGormLogger(l, logger.Config{
+		LogLevel:                  logger.Warn,
+		IgnoreRecordNotFoundError: true,
+	})
+
+	db.Model(&slogItem{}).Where("name", "bar").One(&slogItem{})
+	if buf.Len() != 0 {
+		t.Fatalf("expected no logs, got %s", buf.String())
+	}
+}
+
+func TestSlogOperationConfigCached(t *testing.T) {
+	v := viper.New()
+	v.Set("db.slow_threshold", "1h")
+	conf.SetViperConfig(v)
+	var buf bytes.Buffer
+	log.SetLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() {
+		conf.SetViperConfig(nil)
+		log.SetLogger(nil)
+	})
+
+	// 设置日志后修改配置不影响已缓存的阈值
+	v.Set("db.slow_threshold", "1ms")
+	log.Operation(context.Background(), &types.Operation{Backend: types.SQLite, Table: "slog_items", Type: types.OpFindOne, Duration: 10 * time.Millisecond}, nil)
+	logs := parseLogs(t, &buf)
+	if len(logs["morm operation"]) != 1 || len(logs["morm slow operation"]) != 0 {
+		t.Fatalf("expected cached slow threshold, got %v", logs)
+	}
+}
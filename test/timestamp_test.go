@@ -0,0 +1,106 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// timestampItem 用于测试 MongoDB 自动时间戳
+type timestampItem struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Name      string             `bson:"name"`
+	CreatedAt time.Time          `bson:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at"`
+	Stamp     int64              `bson:"stamp" gorm:"autoUpdateTime:milli"`
+}
+
+func TestTimestampMongo(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	before := time.Now().Add(-time.Second)
+	isNow := func(v bson.RawValue) bool {
+		switch v.Type {
+		case bson.TypeDateTime:
+			return v.Time().After(before)
+		case bson.TypeInt64:
+			return v.Int64() >= before.UnixMilli()
+		}
+		return false
+	}
+
+	mt.Run("create", func(mt *mtest.T) {
+		db := newMockMongo(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		item := &timestampItem{ID: primitive.NewObjectID(), Name: "a"}
+		if _, err := db.Model(&timestampItem{}).Create(item); err != nil {
+			mt.Fatal(err)
+		}
+		doc := mt.GetStartedEvent().Command.Lookup("documents").Array().Index(0).Value().Document()
+		for _, name := range []string{"created_at", "updated_at", "stamp"} {
+			if !isNow(doc.Lookup(name)) {
+				mt.Fatalf("expected %s filled, got %v", name, doc)
+			}
+		}
+		if item.CreatedAt.Before(before) || item.UpdatedAt.Before(before) || item.Stamp == 0 {
+			mt.Fatalf("expected times written back, got %+v", item)
+		}
+	})
+
+	mt.Run("update", func(mt *mtest.T) {
+		db := newMockMongo(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: int32(1)}, bson.E{Key: "nModified", Value: int32(1)}))
+		item := &timestampItem{Name: "b"}
+		if err := db.Model(&timestampItem{}).Where("name", "a").Update(item); err != nil {
+			mt.Fatal(err)
+		}
+		u := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u").Document()
+		if !isNow(u.Lookup("$set", "updated_at")) || !isNow(u.Lookup("$set", "stamp")) {
+			mt.Fatalf("expected updated_at in $set, got %v", u)
+		}
+		if _, err := u.LookupErr("$setOnInsert"); err == nil {
+			mt.Fatalf("unexpected $setOnInsert on update, got %v", u)
+		}
+		if item.UpdatedAt.Before(before) {
+			mt.Fatalf("expected UpdatedAt written back, got %+v", item)
+		}
+	})
+
+	mt.Run("upsert", func(mt *mtest.T) {
+		db := newMockMongo(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: int32(1)}, bson.E{Key: "nModified", Value: int32(1)}))
+		if err := db.Model(&timestampItem{}).Where("name", "a").Save(&timestampItem{ID: primitive.NewObjectID(), Name: "a"}); err != nil {
+			mt.Fatal(err)
+		}
+		u := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		if !u.Lookup("upsert").Boolean() {
+			mt.Fatalf("expected upsert, got %v", u)
+		}
+		// 创建时间只在插入时通过 $setOnInsert 写入
+		set := u.Lookup("u", "$set").Document()
+		if !isNow(set.Lookup("updated_at")) || set.Lookup("created_at").Type != 0 {
+			mt.Fatalf("unexpected $set %v", set)
+		}
+		if !isNow(u.Lookup("u", "$setOnInsert", "created_at")) {
+			mt.Fatalf("expected created_at in $setOnInsert, got %v", u)
+		}
+	})
+
+	mt.Run("current date", func(mt *mtest.T) {
+		db := newMockMongo(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: int32(1)}, bson.E{Key: "nModified", Value: int32(1)}))
+		if err := db.Model(&timestampItem{}).Where("name", "a").Incr("count", 1); err != nil {
+			mt.Fatal(err)
+		}
+		// 时间类型由服务端通过 $currentDate 写入 整数时间戳使用 $set
+		u := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u").Document()
+		if !u.Lookup("$currentDate", "updated_at").Boolean() || !isNow(u.Lookup("$set", "stamp")) {
+			mt.Fatalf("unexpected update %v", u)
+		}
+		if u.Lookup("$inc", "count").Int64() != 1 {
+			mt.Fatalf("expected $inc, got %v", u)
+		}
+	})
+}
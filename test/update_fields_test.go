@@ -0,0 +1,67 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lfhy/morm/types"
+)
+
+func TestUpdateFields(t *testing.T) {
+	db := newSQLDB(t, &zeroItem{})
+	remark := "x"
+	item := &zeroItem{Name: "a", IsDelete: 1, Status: 2, Score: intPtr(5), Remark: &remark}
+	if _, err := db.Model(&zeroItem{}).Create(item); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	// Update 跳过零值 UpdateFields 写入零值
+	if err := db.Model(&zeroItem{}).Where("id", item.ID).UpdateFields(&zeroItem{Name: "b"}, "IsDelete", "score", "Remark"); err != nil {
+		t.Fatalf("update fields: %v", err)
+	}
+	var got zeroItem
+	if err := db.Model(&zeroItem{}).Where("id", item.ID).One(&got); err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if got.IsDelete != 0 || got.Score != nil || got.Remark != nil {
+		t.Fatalf("expected zero values to be written, got %+v", got)
+	}
+	if got.Name != "a" || got.Status != 2 {
+		t.Fatalf("expected unselected fields to be kept, got %+v", got)
+	}
+
+	err := db.Model(&zeroItem{}).Where("id", item.ID).UpdateFields(&zeroItem{}, "Name", "Missing")
+	if !errors.Is(err, types.ErrUnknownField) {
+		t.Fatalf("expected ErrUnknownField, got %v", err)
+	}
+	got = zeroItem{}
+	if err := db.Model(&zeroItem{}).Where("id", item.ID).One(&got); err != nil || got.Name != "a" {
+		t.Fatalf("expected no update on error, got %+v %v", got, err)
+	}
+	if err := db.Model(&zeroItem{}).Where("id", item.ID).UpdateFields(&zeroItem{}); err == nil {
+		t.Fatal("expected error without fields")
+	}
+}
+
+func TestUpdateFieldsVersion(t *testing.T) {
+	db := newSQLDB(t, &versionItem{})
+	item := &versionItem{Name: "foo"}
+	if _, err := db.Model(&versionItem{}).Create(item); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	update := &versionItem{Version: item.Version}
+	if err := db.Model(&versionItem{}).Where("id", item.ID).UpdateFields(update, "name"); err != nil {
+		t.Fatalf("update fields: %v", err)
+	}
+	if update.Version != 2 {
+		t.Fatalf("expected version 2, got %d", update.Version)
+	}
+	var got versionItem
+	if err := db.Model(&versionItem{}).Where("id", item.ID).One(&got); err != nil || got.Name != "" || got.Version != 2 {
+		t.Fatalf("expected empty name at version 2, got %+v %v", got, err)
+	}
+	// 旧版本号更新失败
+	if err := db.Model(&versionItem{}).Where("id", item.ID).UpdateFields(&versionItem{Version: 1}, "name"); !errors.Is(err, types.ErrStaleVersion) {
+		t.Fatalf("expected ErrStaleVersion, got %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lfhy/morm/db/mongodb"
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var _ types.ValidatorSyncer = (*mongodb.DBConn)(nil)
+
+type validatedAddress struct {
+	City string `bson:"city" morm:"required"`
+}
+
+// validatedItem 通过 morm 标签声明校验规则
+type validatedItem struct {
+	ID      primitive.ObjectID `bson:"_id"`
+	Name    string             `bson:"name" morm:"required;min:1;max:64"`
+	Age     int                `bson:"age" morm:"min:0;max:150"`
+	Score   float64            `bson:"score" morm:"max:9.5"`
+	Status  string             `bson:"status" morm:"enum:active|disabled,archived"`
+	Level   int                `bson:"level" morm:"enum:1|2|3"`
+	Phone   string             `bson:"phone" morm:"pattern:^1[0-9]{9,10}$"`
+	Tags    []string           `bson:"tags" morm:"max:10"`
+	Remark  *string            `bson:"remark"`
+	Created time.Time          `bson:"created"`
+	Address validatedAddress   `bson:"address"`
+	Extra   any                `bson:"extra"`
+}
+
+func (validatedItem) TableName() string { return "validated_items" }
+
+func TestJSONSchema(t *testing.T) {
+	if !mongodb.HasValidationRules(&validatedItem{}) || mongodb.HasValidationRules(&indexItem{}) {
+		t.Fatal("unexpected HasValidationRules result")
+	}
+	js, err := mongodb.JSONSchema(&validatedItem{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := bson.MarshalExtJSON(js, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	for _, want := range []string{
+		`"required":["name"]`,
+		`"_id":{"bsonType":"objectId"}`,
+		`"name":{"bsonType":"string","minLength":1,"maxLength":64}`,
+		`"age":{"bsonType":["int","long"],"minimum":0,"maximum":150}`,
+		`"score":{"bsonType":"number","maximum":9.5}`,
+		`"status":{"bsonType":"string","enum":["active","disabled,archived"]}`,
+		`"level":{"bsonType":["int","long"],"enum":[1,2,3]}`,
+		`"phone":{"bsonType":"string","pattern":"^1[0-9]{9,10}$"}`,
+		`"tags":{"bsonType":["array","null"],"items":{"bsonType":"string"},"maxItems":10}`,
+		`"remark":{"bsonType":["string","null"]}`,
+		`"created":{"bsonType":"date"}`,
+		`"address":{"bsonType":"object","required":["city"],"properties":{"city":{"bsonType":"string"}}}`,
+		`"extra":{}`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %s in %s", want, got)
+		}
+	}
+}
+
+type invalidValidatedItem struct {
+	Created time.Time `bson:"created" morm:"pattern:^a"`
+}
+
+type invalidPatternItem struct {
+	Code string `bson:"code" morm:"pattern:^[0-9{1,3}"`
+}
+
+// stringIDItem 字符串主键与加密字段
+type stringIDItem struct {
+	ID     string `bson:"_id"`
+	Secret string `bson:"secret" morm:"encrypt;required;max:8;pattern:^[0-9]+$"`
+}
+
+func TestJSONSchemaStringID(t *testing.T) {
+	js, err := mongodb.JSONSchema(&stringIDItem{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := bson.MarshalExtJSON(js, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	for _, want := range []string{
+		`"required":["secret"]`,
+		`"_id":{"bsonType":["objectId","string"]}`,
+		`"secret":{"bsonType":"string"}`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %s in %s", want, got)
+		}
+	}
+}
+
+func TestJSONSchemaInvalidTag(t *testing.T) {
+	for _, data := range []any{&invalidValidatedItem{}, &invalidPatternItem{}} {
+		if _, err := mongodb.JSONSchema(data); err == nil || !strings.Contains(err.Error(), "invalid pattern") {
+			t.Fatalf("expected invalid pattern error for %T, got %v", data, err)
+		}
+	}
+}
+
+func TestPlanValidator(t *testing.T) {
+	validator, err := mongodb.Validator(&validatedItem{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	strict := types.ValidatorOptions{Level: types.ValidationLevelStrict}
+
+	plan := &types.SchemaPlan{}
+	mongodb.PlanValidator(plan, "validated_items", validator, mongodb.CollectionValidator{}, strict)
+	if len(plan.Statements) != 1 || !strings.HasPrefix(plan.Statements[0], `db.createCollection("validated_items", {"validator":{"$jsonSchema"`) ||
+		!strings.Contains(plan.Statements[0], `"validationLevel":"strict"`) {
+		t.Fatalf("unexpected plan:\n%s", plan)
+	}
+
+	// 与集合当前的规则一致
+	raw, _ := bson.Marshal(validator)
+	current := mongodb.CollectionValidator{Exists: true, Validator: raw}
+	if !current.Same(validator, strict) {
+		t.Fatal("expected same validator")
+	}
+	plan = &types.SchemaPlan{}
+	mongodb.PlanValidator(plan, "validated_items", validator, current, types.ValidatorOptions{Action: types.ValidationActionWarn})
+	if len(plan.Changes) != 1 || !strings.Contains(plan.Statements[0], `"collMod":"validated_items"`) || !strings.Contains(plan.String(), "level strict action error") {
+		t.Fatalf("unexpected plan:\n%s", plan)
+	}
+
+	old, _ := bson.Marshal(bson.D{{Key: "$jsonSchema", Value: bson.D{{Key: "bsonType", Value: "object"}}}})
+	plan = &types.SchemaPlan{}
+	mongodb.PlanValidator(plan, "validated_items", validator, mongodb.CollectionValidator{Exists: true, Validator: old}, strict)
+	if len(plan.Changes) != 1 || plan.Changes[0].Detail != "validator differs" {
+		t.Fatalf("unexpected plan:\n%s", plan)
+	}
+}
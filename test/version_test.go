@@ -0,0 +1,224 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// versionItem 用于测试乐观锁
+type versionItem struct {
+	ID      int    `gorm:"column:id;primaryKey;autoIncrement"`
+	Name    string `gorm:"column:name"`
+	Version int64  `gorm:"column:version" morm:"version"`
+}
+
+func (versionItem) TableName() string { return "version_items" }
+
+func TestVersionCreate(t *testing.T) {
+	db := newSQLDB(t, &versionItem{})
+	item := &versionItem{Name: "foo"}
+	if _, err := db.Model(&versionItem{}).Create(item); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if item.Version != 1 {
+		t.Fatalf("expected version=1 after create, got %d", item.Version)
+	}
+}
+
+func TestVersionUpdate(t *testing.T) {
+	db := newSQLDB(t, &versionItem{})
+	item := &versionItem{Name: "foo"}
+	if _, err := db.Model(&versionItem{}).Create(item); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	// 两个管理员读到同一版本
+	var a, b versionItem
+	db.Model(&versionItem{}).Where("id", item.ID).One(&a)
+	db.Model(&versionItem{}).Where("id", item.ID).One(&b)
+
+	a.Name = "a"
+	if err := db.Model(&versionItem{}).Update(&a); err != nil {
+		t.Fatalf("update a: %v", err)
+	}
+	if a.Version != 2 {
+		t.Fatalf("expected version=2 after update, got %d", a.Version)
+	}
+
+	b.Name = "b"
+	err := db.Model(&versionItem{}).Update(&b)
+	if !errors.Is(err, types.ErrStaleVersion) {
+		t.Fatalf("expected ErrStaleVersion, got %v", err)
+	}
+
+	var got versionItem
+	db.Model(&versionItem{}).Where("id", item.ID).One(&got)
+	if got.Name != "a" || got.Version != 2 {
+		t.Fatalf("expected name=a version=2, got name=%s version=%d", got.Name, got.Version)
+	}
+}
+
+func TestVersionSessionRetry(t *testing.T) {
+	db := newSQLDB(t, &versionItem{})
+	db.StaleRetry = 2
+	item := &versionItem{Name: "foo"}
+	if _, err := db.Model(&versionItem{}).Create(item); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	attempts := 0
+	err := db.Model(&versionItem{}).Session(func(s types.Session) error {
+		attempts++
+		var cur versionItem
+		if err := s.SwitchModel(&versionItem{}).Where("id", item.ID).One(&cur); err != nil {
+			return err
+		}
+		if attempts == 1 {
+			// 模拟并发写入导致第一次读取的版本与库中不一致
+			cur.Version += 5
+		}
+		cur.Name = "retried"
+		return s.SwitchModel(&versionItem{}).Update(&cur)
+	})
+	if err != nil {
+		t.Fatalf("session: %v", err)
+	}
+	if attempts != 2 {
+		t.Fatalf("expected 2 attempts, got %d", attempts)
+	}
+}
+
+// mongoVersionItem 用于测试 MongoDB 乐观锁
+type mongoVersionItem struct {
+	ID      primitive.ObjectID `bson:"_id"`
+	Name    string             `bson:"name"`
+	Version int64              `bson:"version" morm:"version"`
+}
+
+func TestVersionMongo(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	updated := func(n int32) bson.D {
+		return mtest.CreateSuccessResponse(bson.E{Key: "n", Value: n}, bson.E{Key: "nModified", Value: n})
+	}
+	upserted := func(id primitive.ObjectID) bson.D {
+		return mtest.CreateSuccessResponse(
+			bson.E{Key: "n", Value: int32(1)},
+			bson.E{Key: "nModified", Value: int32(0)},
+			bson.E{Key: "upserted", Value: bson.A{bson.D{{Key: "index", Value: int32(0)}, {Key: "_id", Value: id}}}},
+		)
+	}
+	counted := func(n int32) bson.D {
+		if n == 0 {
+			return mtest.CreateCursorResponse(0, "test.mongo_version_items", mtest.FirstBatch)
+		}
+		return mtest.CreateCursorResponse(0, "test.mongo_version_items", mtest.FirstBatch, bson.D{{Key: "n", Value: n}})
+	}
+
+	mt.Run("create", func(mt *mtest.T) {
+		db := newMockMongo(mt)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		item := &mongoVersionItem{ID: primitive.NewObjectID(), Name: "a"}
+		if _, err := db.Model(&mongoVersionItem{}).Create(item); err != nil {
+			mt.Fatal(err)
+		}
+		if item.Version != 1 {
+			mt.Fatalf("expected version=1 after create, got %d", item.Version)
+		}
+		doc := mt.GetStartedEvent().Command.Lookup("documents").Array().Index(0).Value().Document()
+		if v := doc.Lookup("version").Int64(); v != 1 {
+			mt.Fatalf("expected version 1 in document, got %v", doc)
+		}
+	})
+
+	mt.Run("update", func(mt *mtest.T) {
+		db := newMockMongo(mt)
+		item := &mongoVersionItem{ID: primitive.NewObjectID(), Name: "a", Version: 1}
+		mt.AddMockResponses(updated(1), updated(0))
+		if err := db.Model(&mongoVersionItem{}).Where("_id", item.ID).Update(item); err != nil {
+			mt.Fatal(err)
+		}
+		if item.Version != 2 {
+			mt.Fatalf("expected version=2 after update, got %d", item.Version)
+		}
+		q := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		if v := q.Lookup("q", "version").Int64(); v != 1 {
+			mt.Fatalf("expected version in filter, got %v", q)
+		}
+		if err := db.Model(&mongoVersionItem{}).Where("_id", item.ID).Update(item); !errors.Is(err, types.ErrStaleVersion) {
+			mt.Fatalf("expected ErrStaleVersion, got %v", err)
+		}
+	})
+
+	mt.Run("save stale", func(mt *mtest.T) {
+		db := newMockMongo(mt)
+		item := &mongoVersionItem{ID: primitive.NewObjectID(), Name: "a", Version: 3}
+		mt.AddMockResponses(updated(0), counted(1))
+		if err := db.Model(&mongoVersionItem{}).Save(item); !errors.Is(err, types.ErrStaleVersion) {
+			mt.Fatalf("expected ErrStaleVersion, got %v", err)
+		}
+		// 没有条件时按数据的 _id 更新与判断是否存在
+		q := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("q").Document()
+		if q.Lookup("_id").ObjectID() != item.ID || q.Lookup("version").Int64() != 3 {
+			mt.Fatalf("unexpected save filter %v", q)
+		}
+		match := mt.GetStartedEvent().Command.Lookup("pipeline").Array().Index(0).Value().Document().Lookup("$match").Document()
+		if elems, _ := match.Elements(); len(elems) != 1 || match.Lookup("_id").ObjectID() != item.ID {
+			mt.Fatalf("expected count by _id, got %v", match)
+		}
+	})
+
+	mt.Run("save insert", func(mt *mtest.T) {
+		db := newMockMongo(mt)
+		// 带版本号的数据不存在时插入
+		item := &mongoVersionItem{ID: primitive.NewObjectID(), Name: "a", Version: 3}
+		mt.AddMockResponses(updated(0), counted(0), upserted(item.ID))
+		if err := db.Model(&mongoVersionItem{}).Save(item); err != nil {
+			mt.Fatal(err)
+		}
+		if item.Version != 1 {
+			mt.Fatalf("expected version=1 after insert, got %d", item.Version)
+		}
+
+		// 版本号为零值时直接插入
+		item = &mongoVersionItem{ID: primitive.NewObjectID(), Name: "b"}
+		mt.AddMockResponses(upserted(item.ID))
+		if err := db.Model(&mongoVersionItem{}).Save(item); err != nil {
+			mt.Fatal(err)
+		}
+		if item.Version != 1 {
+			mt.Fatalf("expected version=1 after upsert, got %d", item.Version)
+		}
+	})
+
+	mt.Run("session retry in transaction", func(mt *mtest.T) {
+		db := newMockMongo(mt)
+		db.StaleRetry = 1
+		item := &mongoVersionItem{ID: primitive.NewObjectID(), Name: "a", Version: 1}
+		ok := mtest.CreateSuccessResponse()
+		mt.AddMockResponses(updated(0), ok, updated(1), ok, ok)
+		err := db.Model(&mongoVersionItem{}).Session(func(s types.Session) error {
+			return s.Where("_id", item.ID).Update(item)
+		})
+		if err != nil {
+			mt.Fatal(err)
+		}
+		// 两次更新都在事务中执行 第一次失败后事务被回滚
+		var commands []string
+		for _, e := range mt.GetAllStartedEvents() {
+			commands = append(commands, e.CommandName)
+			if e.CommandName == "update" {
+				if _, err := e.Command.LookupErr("txnNumber"); err != nil {
+					mt.Fatalf("expected update in transaction, got %v", e.Command)
+				}
+			}
+		}
+		if len(commands) < 4 || commands[0] != "update" || commands[1] != "abortTransaction" || commands[2] != "update" || commands[3] != "commitTransaction" {
+			mt.Fatalf("unexpected commands %v", commands)
+		}
+	})
+}
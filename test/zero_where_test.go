@@ -0,0 +1,113 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lfhy/morm/db/mongodb"
+	"github.com/lfhy/morm/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// zeroItem 用于测试零值条件
+type zeroItem struct {
+	ID       int     `gorm:"column:id;primaryKey;autoIncrement" bson:"_id"`
+	Name     string  `gorm:"column:name" bson:"name"`
+	IsDelete int     `gorm:"column:is_delete" bson:"is_delete"`
+	Status   int     `gorm:"column:status" bson:"status" morm:"must"`
+	Score    *int    `gorm:"column:score" bson:"score"`
+	Remark   *string `gorm:"column:remark" bson:"remark"`
+}
+
+func (zeroItem) TableName() string { return "zero_items" }
+
+func intPtr(v int) *int { return &v }
+
+func TestWhereZeroValue(t *testing.T) {
+	db := newSQLDB(t, &zeroItem{})
+	remark := "x"
+	for _, item := range []*zeroItem{
+		{Name: "a", IsDelete: 0, Status: 0, Score: intPtr(0)},
+		{Name: "b", IsDelete: 1, Status: 1, Score: intPtr(5), Remark: &remark},
+		{Name: "c", IsDelete: 0, Status: 1},
+	} {
+		if _, err := db.Model(&zeroItem{}).Create(item); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+
+	// 普通零值字段仍然忽略
+	if n := db.Model(&zeroItem{}).Where(&zeroItem{IsDelete: 0}).Count(); n != 1 {
+		t.Fatalf("expected must field status=0 to match 1 row, got %d", n)
+	}
+	// 指向零值的指针作为条件
+	if n := db.Model(&zeroItem{}).Where(&zeroItem{Status: 1, Score: intPtr(0)}).Count(); n != 0 {
+		t.Fatalf("expected no row with status=1 and score=0, got %d", n)
+	}
+	var got zeroItem
+	if err := db.Model(&zeroItem{}).Where(&zeroItem{Score: intPtr(0)}).One(&got); err != nil || got.Name != "a" {
+		t.Fatalf("expected row a by zero pointer, got %+v %v", got, err)
+	}
+	// must 字段的零值用于 WhereNot
+	if n := db.Model(&zeroItem{}).WhereNot(&zeroItem{}).Count(); n != 2 {
+		t.Fatalf("expected 2 rows with status<>0, got %d", n)
+	}
+}
+
+func TestWhereFields(t *testing.T) {
+	db := newSQLDB(t, &zeroItem{})
+	remark := "x"
+	for _, item := range []*zeroItem{
+		{Name: "a", IsDelete: 0, Status: 1},
+		{Name: "b", IsDelete: 1, Status: 1, Remark: &remark},
+	} {
+		if _, err := db.Model(&zeroItem{}).Create(item); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+
+	// 字段名 列名均可 零值作为条件
+	if n := db.Model(&zeroItem{}).WhereFields(&zeroItem{Name: "ignored"}, "IsDelete", "status").Count(); n != 0 {
+		t.Fatalf("expected status=0 to match nothing, got %d", n)
+	}
+	if n := db.Model(&zeroItem{}).WhereFields(&zeroItem{Status: 1}, "IsDelete", "status").Count(); n != 1 {
+		t.Fatalf("expected 1 row with is_delete=0, got %d", n)
+	}
+	// nil 指针匹配 NULL
+	var got zeroItem
+	if err := db.Model(&zeroItem{}).WhereFields(&zeroItem{}, "Remark").One(&got); err != nil || got.Name != "a" {
+		t.Fatalf("expected row a by null remark, got %+v %v", got, err)
+	}
+
+	// 字段不存在时返回错误 不会扩大查询范围
+	err := db.Model(&zeroItem{}).WhereFields(&zeroItem{}, "Missing").Delete()
+	if !errors.Is(err, types.ErrUnknownField) {
+		t.Fatalf("expected ErrUnknownField, got %v", err)
+	}
+	if n := db.Model(&zeroItem{}).Count(); n != 2 {
+		t.Fatalf("expected rows to be kept, got %d", n)
+	}
+}
+
+func TestWhereZeroValueMongo(t *testing.T) {
+	m := &mongodb.Model{WhereList: bson.M{}}
+	m.Where(&zeroItem{Score: intPtr(0)})
+	if v, ok := m.WhereList["score"].(bson.M); !ok || v["$eq"] != 0 {
+		t.Fatalf("expected score=0 condition, got %+v", m.WhereList)
+	}
+	if v, ok := m.WhereList["status"].(bson.M); !ok || v["$eq"] != 0 {
+		t.Fatalf("expected must field status=0 condition, got %+v", m.WhereList)
+	}
+	if _, ok := m.WhereList["is_delete"]; ok {
+		t.Fatalf("expected zero is_delete to be skipped, got %+v", m.WhereList)
+	}
+
+	m = &mongodb.Model{WhereList: bson.M{}}
+	m.WhereFields(&zeroItem{}, "is_delete", "Remark")
+	if v, ok := m.WhereList["is_delete"].(bson.M); !ok || v["$eq"] != 0 {
+		t.Fatalf("expected is_delete=0 condition, got %+v", m.WhereList)
+	}
+	if v, ok := m.WhereList["remark"].(bson.M); !ok || v["$eq"] != nil {
+		t.Fatalf("expected remark=null condition, got %+v", m.WhereList)
+	}
+}
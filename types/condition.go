@@ -0,0 +1,30 @@
+package types
+
+// 字段条件的运算符
+type CondOp int
+
+const (
+	CondEq CondOp = iota
+	CondNe
+	CondGt
+	CondGte
+	CondLt
+	CondLte
+	CondIn
+	CondNotIn
+	// SQL LIKE 语法 % 匹配任意字符 _ 匹配单个字符 MongoDB 转换为 $regex
+	CondLike
+)
+
+// 字段条件 通常由 morm gen fields 生成的字段描述构造
+// 同时记录 SQL 列名与 MongoDB 字段名 由后端选择使用哪一个
+//
+//	m.Where(UserFields.Age.Gt(18), UserFields.Name.Like("a%"))
+type Condition struct {
+	// SQL 列名
+	Column string
+	// MongoDB 字段名
+	BSON  string
+	Op    CondOp
+	Value any
+}
@@ -0,0 +1,18 @@
+package types
+
+import "errors"
+
+// 乐观锁版本不一致
+// 带有 morm:"version" 字段的模型在 Update / Save 时没有匹配到对应版本的数据时返回
+// 可以通过 errors.Is(err, ErrStaleVersion) 判断
+var ErrStaleVersion = errors.New("morm: stale version")
+
+// 字段不存在
+// WhereFields 传入的字段名在模型中找不到时 后续操作返回该错误
+// 可以通过 errors.Is(err, ErrUnknownField) 判断
+var ErrUnknownField = errors.New("morm: unknown field")
+
+// 后端不支持的操作
+// 如在 SQL 后端使用 Pipeline
+// 可以通过 errors.Is(err, ErrNotSupported) 判断
+var ErrNotSupported = errors.New("morm: not supported")
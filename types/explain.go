@@ -0,0 +1,14 @@
+package types
+
+// 查询计划 由 Explain 返回
+type QueryPlan struct {
+	// 查询语句 SQL 为完整的 SQL MongoDB 为 Extended JSON 格式的命令
+	Statement string
+	// 后端返回的原始计划
+	// SQLite 与 MySQL 为 EXPLAIN 的每一行 []map[string]any MongoDB 为 explain 命令的结果 bson.M
+	Raw any
+	// 查询用到的索引
+	Indexes []string
+	// 是否有全表扫描 可用于在测试中发现缺少的索引
+	FullScan bool
+}
@@ -0,0 +1,92 @@
+package types
+
+import (
+	"context"
+	"reflect"
+)
+
+// 生命周期钩子
+// 模型实现以下接口后 SQL 与 Mongo 后端都会在对应操作前后调用
+// Before 钩子返回错误时会中止操作 After 钩子返回的错误会作为操作的错误返回
+// m 为绑定到当前连接（事务中为当前事务）的新模型 可以在钩子中继续操作数据库
+// Incr 与 UpdateColumns 和 gorm 的 UpdateColumn 一样不会触发钩子
+
+// 插入前
+type BeforeCreateHook interface {
+	BeforeCreate(ctx context.Context, m ORMModel) error
+}
+
+// 插入后
+type AfterCreateHook interface {
+	AfterCreate(ctx context.Context, m ORMModel) error
+}
+
+// 更新前
+type BeforeUpdateHook interface {
+	BeforeUpdate(ctx context.Context, m ORMModel) error
+}
+
+// 更新后
+type AfterUpdateHook interface {
+	AfterUpdate(ctx context.Context, m ORMModel) error
+}
+
+// 删除前
+type BeforeDeleteHook interface {
+	BeforeDelete(ctx context.Context, m ORMModel) error
+}
+
+// 查询后
+type AfterFindHook interface {
+	AfterFind(ctx context.Context, m ORMModel) error
+}
+
+// CallHooks 对 value 调用钩子
+// value 可以是结构体指针 也可以是结构体切片或其指针 切片时会逐个调用
+func CallHooks[T any](value any, call func(hook T) error) error {
+	if value == nil {
+		return nil
+	}
+	if hook, ok := value.(T); ok {
+		return call(hook)
+	}
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if hook, ok := hookOf[T](rv.Index(i)); ok {
+				if err := call(hook); err != nil {
+					return err
+				}
+			}
+		}
+	case reflect.Struct:
+		if hook, ok := hookOf[T](rv); ok {
+			return call(hook)
+		}
+	}
+	return nil
+}
+
+func hookOf[T any](rv reflect.Value) (hook T, ok bool) {
+	if rv.Kind() == reflect.Interface {
+		rv = rv.Elem()
+	}
+	if rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return hook, false
+	}
+	if rv.Kind() != reflect.Ptr && rv.CanAddr() {
+		rv = rv.Addr()
+	}
+	if !rv.CanInterface() {
+		return hook, false
+	}
+	hook, ok = rv.Interface().(T)
+	return
+}
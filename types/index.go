@@ -0,0 +1,66 @@
+package types
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 索引定义
+// 模型可以实现 Indexer 返回索引 也可以在字段上使用 morm 标签
+//
+//	Name  string    `morm:"index"`                 单字段索引
+//	Email string    `morm:"unique"`                唯一索引
+//	Org   string    `morm:"index:idx_org_user"`    同名的字段组成联合索引 按字段顺序
+//	User  string    `morm:"index:idx_org_user"`
+//	Code  string    `morm:"unique:uk_org_code"`    联合唯一索引
+//	Until time.Time `morm:"ttl:24h"`               TTL 索引
+//	Title string    `morm:"text"`                  文本索引 所有 text 字段组成一个文本索引
+type IndexSpec struct {
+	// 索引名 为空时与 MongoDB 默认规则一致 如 name_1_age_-1
+	Name string
+	// 索引字段 值为 1 -1 或 "text" 等索引类型
+	Keys bson.D
+	// 唯一索引
+	Unique bool
+	// 稀疏索引
+	Sparse bool
+	// 过期时间 大于 0 时为 TTL 索引
+	TTL time.Duration
+	// 部分索引的过滤条件 如 bson.M{"deleted": false}
+	PartialFilter any
+	// 排序规则
+	Collation *options.Collation
+}
+
+// 通过方法声明索引的模型
+type Indexer interface {
+	Indexes() []IndexSpec
+}
+
+// 同步索引的选项
+type SyncIndexOptions struct {
+	// 删除模型没有声明的索引 并重建定义不一致的索引
+	// 为 false 时只在结果中报告
+	Drop bool
+}
+
+// 单个集合的索引同步结果
+type IndexReport struct {
+	Collection string
+	// 新建的索引
+	Created []string
+	// 定义与模型不一致的索引
+	Changed []string
+	// 模型没有声明的索引
+	Extra []string
+	// 已删除的索引
+	Dropped []string
+}
+
+// 支持同步索引的连接
+type IndexSyncer interface {
+	SyncIndexes(ctx context.Context, models ...any) ([]IndexReport, error)
+}
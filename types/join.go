@@ -0,0 +1,43 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 连接条件不合法
+// on 不是 表名.字段 = 表名.字段 的格式或没有引用连接的表时返回
+var ErrInvalidJoin = errors.New("morm: invalid join")
+
+// 连接条件中的一侧
+type JoinField struct {
+	Table string
+	Field string
+}
+
+// 解析连接条件 格式为 表名.字段 = 表名.字段
+// table 为连接的表或集合 返回的 joined 为其中属于 table 的一侧 other 为另一侧
+func ParseJoinOn(on, table string) (joined, other JoinField, err error) {
+	left, right, ok := strings.Cut(on, "=")
+	if !ok {
+		return joined, other, fmt.Errorf("%w: %q", ErrInvalidJoin, on)
+	}
+	parse := func(s string) (JoinField, bool) {
+		t, f, ok := strings.Cut(strings.TrimSpace(s), ".")
+		t, f = strings.Trim(t, "`"), strings.Trim(f, "`")
+		return JoinField{Table: t, Field: f}, ok && t != "" && f != ""
+	}
+	a, okA := parse(left)
+	b, okB := parse(right)
+	if !okA || !okB {
+		return joined, other, fmt.Errorf("%w: %q", ErrInvalidJoin, on)
+	}
+	switch table {
+	case a.Table:
+		return a, b, nil
+	case b.Table:
+		return b, a, nil
+	}
+	return joined, other, fmt.Errorf("%w: %q does not reference %s", ErrInvalidJoin, on, table)
+}
@@ -0,0 +1,85 @@
+package types
+
+import (
+	"context"
+	"time"
+)
+
+// 操作类型
+type OpType string
+
+const (
+	OpCreate        OpType = "create"
+	OpSave          OpType = "save"
+	OpUpdate        OpType = "update"
+	OpDelete        OpType = "delete"
+	OpFindOne       OpType = "find_one"
+	OpFindAll       OpType = "find_all"
+	OpCount         OpType = "count"
+	OpCursor        OpType = "cursor"
+	OpBulk          OpType = "bulk"
+	OpSession       OpType = "session"
+	OpIncr          OpType = "incr"
+	OpUpdateColumns OpType = "update_columns"
+	OpExec          OpType = "exec"
+)
+
+// 操作描述
+// 每次数据库操作都会生成一个 Operation 依次传给中间件
+type Operation struct {
+	// 操作类型
+	Type OpType
+	// 数据库类型
+	Backend DBType
+	// 表名或集合名
+	Table string
+	// 过滤条件
+	// SQL 为 map[string]any 形式的条件表达式和参数 Mongo 为最终的 bson.M
+	// 中间件在 next 之前就地修改条件时 两种后端都按修改后的条件执行 替换整个 Filter 不生效
+	// SQL 条件的键为 "where name = ?" 这样的条件表达式 原始查询的条件只用于观察
+	Filter any
+	// 写入的数据
+	Update any
+	// 查询选项 如 limit offset sort
+	Options any
+	// 影响或返回的行数 执行后填充
+	Rows int64
+	// 执行耗时 执行后填充
+	Duration time.Duration
+	// 执行错误 执行后填充
+	Err error
+}
+
+// 执行操作的处理函数
+type Handler func(ctx context.Context, op *Operation) error
+
+// 中间件
+// 在 next 前后加入逻辑即可观察或修改操作 不调用 next 则操作不会执行
+//
+//	orm.Use(func(next types.Handler) types.Handler {
+//		return func(ctx context.Context, op *types.Operation) error {
+//			err := next(ctx, op)
+//			fmt.Println(op.Type, op.Table, op.Duration, err)
+//			return err
+//		}
+//	})
+type Middleware func(next Handler) Handler
+
+// 组合中间件 先注册的在外层
+func Chain(handler Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// 执行操作 并在执行后填充耗时与错误
+func Invoke(ctx context.Context, op *Operation, middlewares []Middleware, fn func(ctx context.Context) error) error {
+	handler := func(ctx context.Context, op *Operation) error {
+		start := time.Now()
+		op.Err = fn(ctx)
+		op.Duration = time.Since(start)
+		return op.Err
+	}
+	return Chain(handler, middlewares...)(ctx, op)
+}
@@ -19,6 +19,19 @@ const (
 type ORM interface {
 	// Model用于返回Orm模型 用于之后对模型的操作
 	Model(data any) ORMModel
+
+	// 注册中间件 之后的每次操作都会经过中间件
+	// 先注册的中间件在外层
+	Use(middlewares ...Middleware)
+
+	// 检查数据库连接 可用于就绪探针
+	Ping(ctx context.Context) error
+
+	// 连接池状态
+	Stats() PoolStats
+
+	// 关闭连接池 关闭后不能再使用
+	Close(ctx context.Context) error
 }
 
 type Session interface {
@@ -70,6 +83,12 @@ type ORMModel interface {
 	// Update(map[string]any{"ID":"123"}) 也会生成 UPDATE User SET ID = 123
 	Update(data any, value ...any) error
 
+	// 按字段更新
+	// Update会跳过零值 需要写入零值时使用UpdateFields
+	// 字段名可以是结构体字段名 列名或bson名 字段不存在时返回ErrUnknownField
+	// UpdateFields(&User{Count:0,IsDelete:false},"Count","IsDelete") 会生成 UPDATE User SET Count = 0, IsDelete = false
+	UpdateFields(data any, fields ...string) error
+
 	// 批量写入
 	// 在mongo中datas为[]MongoBulkWriteOperation
 	// 在sql中datas为[]BulkWriteOperation
@@ -101,11 +120,19 @@ type ORMModel interface {
 	// 过滤条件
 	// Where只能传入结构体
 	// 会根据每个结构体的赋值情况进行查询
+	// 零值字段不作为条件 指针字段不为nil时作为条件 带有must标签的字段零值也作为条件
 	// Where(&User{ID:123}) 会生成 WHERE User.ID = 123
 	// Where("ID",123) 也会生成 WHERE User.ID = 123
 	// Where(map[string]any{"ID":"123"}) 也会生成 WHERE User.ID = 123
+	// 也可以传入字段描述生成的条件 Where(UserFields.Age.Gt(18), UserFields.Name.Like("a%"))
 	Where(condition any, value ...any) ORMModel
 
+	// WhereFields按字段名选择条件 零值同样作为条件
+	// 字段名可以是结构体字段名 列名或bson名
+	// WhereFields(&User{IsDelete:0},"IsDelete") 会生成 WHERE User.IsDelete = 0
+	// 字段不存在时后续操作返回ErrUnknownField
+	WhereFields(data any, fields ...string) ORMModel
+
 	// Equal等同Where
 	Equal(key any, value ...any) ORMModel
 
@@ -210,6 +237,36 @@ type ORMModel interface {
 	// 分页
 	Page(page, limit int) ORMModel
 
+	// 预加载关联数据 查询时填充结构体中的关联字段
+	// 关联使用 gorm 的标签声明 支持 has one has many belongs to 与 many2many
+	// field 为结构体字段名 嵌套关联使用 . 分隔 如 Preload("Orders.Items")
+	// scopes 对关联查询追加条件 Preload("Orders", func(m ORMModel) ORMModel { return m.Where("state", "paid") })
+	// SQL 后端使用 gorm 的 Preload MongoDB 后端按关联字段批量使用 $in 查询
+	Preload(field string, scopes ...PreloadScope) ORMModel
+
+	// 连接查询 按 on 连接 model 对应的表或集合 只作用于查询与计数
+	// model 可以是带有 TableName() string 方法的对象 也可以是表名
+	// on 的格式为 表名.字段 = 表名.字段 字段可以是结构体字段名 列名或 bson 名
+	// 连接的表上的条件使用 表名.字段 如
+	// Join(&Customer{}, "customers.id = orders.customer_id").Where("customers.region", "X")
+	// SQL 后端生成 JOIN MongoDB 后端使用 $lookup $unwind 与 $match 组成的聚合管道
+	// on 不合法时后续操作返回 ErrInvalidJoin
+	Join(model any, on string) ORMModel
+
+	// 左连接 没有关联数据的记录同样返回
+	LeftJoin(model any, on string) ORMModel
+
+	// 原始查询 不使用当前的条件
+	// SQL 后端为 SQL 语句与参数 如 Raw("SELECT * FROM users WHERE age > ?", 18).All(&list)
+	// MongoDB 后端为 Extended JSON 格式的数据库命令 不支持参数
+	// 如 Raw(`{"find":"users","filter":{"age":{"$gt":18}}}`).All(&list)
+	// 写入使用 Exec 如 Raw("UPDATE users SET age = age + 1").Exec()
+	Raw(query string, args ...any) RawQuery
+
+	// 在当前集合上执行聚合管道 stages 可以是 mongo.Pipeline bson.A 或 []bson.M
+	// SQL 后端返回 ErrNotSupported
+	Pipeline(stages any) RawQuery
+
 	// 查询匹配到的一条数据
 	One(data any) error
 
@@ -219,6 +276,10 @@ type ORMModel interface {
 	// 返回查询个数
 	Count() int64
 
+	// 按当前的条件返回查询计划 不返回数据
+	// SQLite 使用 EXPLAIN QUERY PLAN MySQL 使用 EXPLAIN MongoDB 使用 explain 命令
+	Explain(ctx context.Context) (*QueryPlan, error)
+
 	// 游标
 	// 在查询大量数据时可以减少内存占用
 	// 使用时需要及时使用Close 避免内存泄漏
@@ -237,6 +298,9 @@ type ORMModel interface {
 	UpdateColumns(data any) error
 }
 
+// 预加载时对关联查询追加条件
+type PreloadScope func(m ORMModel) ORMModel
+
 type ORMQuery interface {
 	// 查询匹配到的一条数据
 	One(data any) error
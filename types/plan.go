@@ -0,0 +1,109 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// 模型与数据库结构不一致
+var ErrSchemaDrift = errors.New("morm: schema drift detected")
+
+// 结构变更类型
+const (
+	ChangeCreateTable  = "create_table"
+	ChangeAddColumn    = "add_column"
+	ChangeAlterColumn  = "alter_column"
+	ChangeCreateIndex  = "create_index"
+	ChangeAlterIndex   = "alter_index"
+	ChangeExtraColumn  = "extra_column"
+	ChangeExtraIndex   = "extra_index"
+	ChangeSetValidator = "set_validator"
+)
+
+// 单项结构差异
+type SchemaChange struct {
+	// 表或集合
+	Table string
+	// 变更类型
+	Kind string
+	// 列名或索引名
+	Name string
+	// 差异说明 如 varchar(100) -> varchar(255)
+	Detail string
+}
+
+// 数据库中存在但模型没有声明 计划中不会删除
+func (c SchemaChange) Extra() bool {
+	return c.Kind == ChangeExtraColumn || c.Kind == ChangeExtraIndex
+}
+
+func (c SchemaChange) String() string {
+	var b strings.Builder
+	switch {
+	case c.Extra():
+		b.WriteString("- ")
+	case c.Kind == ChangeCreateTable || c.Kind == ChangeAddColumn || c.Kind == ChangeCreateIndex:
+		b.WriteString("+ ")
+	default:
+		b.WriteString("~ ")
+	}
+	b.WriteString(c.Table)
+	if c.Name != "" {
+		b.WriteString(".")
+		b.WriteString(c.Name)
+	}
+	b.WriteString(" ")
+	b.WriteString(strings.ReplaceAll(c.Kind, "_", " "))
+	if c.Detail != "" {
+		b.WriteString(": ")
+		b.WriteString(c.Detail)
+	}
+	return b.String()
+}
+
+// 结构对比结果
+type SchemaPlan struct {
+	Changes []SchemaChange
+	// 同步结构需要执行的 SQL 或 MongoDB 命令 生成计划时不会执行
+	Statements []string
+}
+
+// 模型与数据库一致
+// ignoreExtra 为 true 时忽略数据库中多出的列与索引
+func (p *SchemaPlan) Empty(ignoreExtra bool) bool {
+	for _, c := range p.Changes {
+		if !ignoreExtra || !c.Extra() {
+			return false
+		}
+	}
+	return true
+}
+
+// 可读的差异与语句
+func (p *SchemaPlan) String() string {
+	if len(p.Changes) == 0 {
+		return "no changes\n"
+	}
+	var b strings.Builder
+	for _, c := range p.Changes {
+		b.WriteString(c.String())
+		b.WriteString("\n")
+	}
+	if len(p.Statements) > 0 {
+		b.WriteString("\n")
+		for _, s := range p.Statements {
+			b.WriteString(s)
+			if !strings.HasSuffix(s, ";") {
+				b.WriteString(";")
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// 支持生成结构计划的连接
+type Planner interface {
+	Plan(ctx context.Context, models ...any) (*SchemaPlan, error)
+}
@@ -0,0 +1,15 @@
+package types
+
+// 原始查询 由 Raw 或 Pipeline 生成
+// 与普通查询一样经过上下文 中间件 日志 解密与 AfterFind
+type RawQuery interface {
+	// 查询一条数据 没有数据时与 One 一样返回未找到的错误
+	One(data any) error
+	// 查询全部数据
+	All(data any) error
+	// 游标
+	// 使用时需要及时使用Close 避免内存泄漏
+	Cursor() (Cursor, error)
+	// 执行写入 返回影响的行数
+	Exec() (int64, error)
+}
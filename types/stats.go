@@ -0,0 +1,23 @@
+package types
+
+import "time"
+
+// 连接池状态
+type PoolStats struct {
+	// 数据库类型
+	Backend DBType
+	// 连接池最大连接数 0 为不限制
+	MaxOpenConnections int
+	// 已建立的连接数
+	OpenConnections int
+	// 正在使用的连接数
+	InUse int
+	// 空闲的连接数
+	Idle int
+	// 等待获取连接的总次数 Mongo 不统计
+	WaitCount int64
+	// 等待获取连接的总耗时 Mongo 不统计
+	WaitDuration time.Duration
+	// 获取连接的总次数 包含不需要等待的 SQL 不统计
+	Checkouts int64
+}
@@ -0,0 +1,40 @@
+package types
+
+import "context"
+
+// MongoDB 文档校验级别
+const (
+	// 校验全部写入 默认
+	ValidationLevelStrict = "strict"
+	// 只校验已经符合规则的文档
+	ValidationLevelModerate = "moderate"
+	// 关闭校验
+	ValidationLevelOff = "off"
+)
+
+// MongoDB 文档校验失败时的处理
+const (
+	// 拒绝写入 默认
+	ValidationActionError = "error"
+	// 允许写入并记录日志
+	ValidationActionWarn = "warn"
+)
+
+// 文档校验规则由模型字段生成 字段可以使用 morm 标签声明规则
+//
+//	Name   string   `morm:"required;min:1;max:64"`  必填 字符串长度
+//	Age    int      `morm:"min:0;max:150"`          数值范围
+//	Status string   `morm:"enum:active|disabled"`   枚举值以 | 分隔
+//	Phone  string   `morm:"pattern:^1[0-9]+$"`      正则 不能包含 ; 与 ,
+//	Tags   []string `morm:"max:10"`                 数组长度
+type ValidatorOptions struct {
+	// 校验级别 为空时使用配置 mongodb.validation_level
+	Level string
+	// 校验失败时的处理 为空时使用配置 mongodb.validation_action
+	Action string
+}
+
+// 支持同步文档校验规则的连接
+type ValidatorSyncer interface {
+	SyncValidators(ctx context.Context, opts ValidatorOptions, models ...any) error
+}